@@ -0,0 +1,374 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Role is what a caller is allowed to do, each role can do everything the
+// roles before it can
+type Role int
+
+// this const block holds the Role enum values, a route with RolePublic can
+// be called without any credentials at all
+const (
+	RolePublic Role = iota
+	RoleReader
+	RoleLibrarian
+	RoleAdmin
+)
+
+var (
+	// ErrUnauthorized is returned when a route needs credentials and the
+	// request didn't have any
+	ErrUnauthorized = errors.New("The request needs an api key or a bearer token")
+
+	// ErrForbidden is returned when the request's credentials don't have a
+	// role that's allowed to call the route
+	ErrForbidden = errors.New("The api key or token doesn't have a role that's allowed to do this")
+
+	// ErrInvalidAPIKey is returned when a request has an api key that isn't
+	// one of the configured keys
+	ErrInvalidAPIKey = errors.New("The api key is invalid")
+
+	// ErrInvalidRole is returned when reading a role that isn't reader,
+	// librarian or admin
+	ErrInvalidRole = errors.New("The role must be reader, librarian or admin")
+
+	// errNoCredentials is returned by an Authenticator when the request
+	// doesn't have the kind of credentials it checks
+	errNoCredentials = errors.New("The request has no credentials")
+)
+
+// String returns the name of the role, the same name that's used in api key
+// files and tokens
+func (r Role) String() string {
+	switch r {
+	case RolePublic:
+		return "public"
+	case RoleReader:
+		return "reader"
+	case RoleLibrarian:
+		return "librarian"
+	case RoleAdmin:
+		return "admin"
+	}
+
+	return ""
+}
+
+// ParseRole reads a role from its name
+func ParseRole(s string) (Role, error) {
+	for _, role := range []Role{RoleReader, RoleLibrarian, RoleAdmin} {
+		if strings.EqualFold(s, role.String()) {
+			return role, nil
+		}
+	}
+
+	return RolePublic, ErrInvalidRole
+}
+
+// Principal is who made a request, and the role they have
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+}
+
+// Authenticator works out who made a request from its credentials
+type Authenticator interface {
+	// Authenticate returns the principal for the request's credentials,
+	// errNoCredentials if it doesn't have the credentials the Authenticator
+	// checks, and any other error if the credentials are invalid
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+var (
+	authenticator   Authenticator
+	authenticatorMu sync.RWMutex
+)
+
+// SetAuthenticator turns on authentication, every route that needs more than
+// RolePublic then needs credentials that the authenticator accepts. nil
+// turns authentication back off, letting anyone call every route, which is
+// how the api starts out
+func SetAuthenticator(a Authenticator) {
+	authenticatorMu.Lock()
+	defer authenticatorMu.Unlock()
+
+	authenticator = a
+}
+
+// getAuthenticator returns the authenticator given to SetAuthenticator
+func getAuthenticator() Authenticator {
+	authenticatorMu.RLock()
+	defer authenticatorMu.RUnlock()
+
+	return authenticator
+}
+
+// Authenticators tries each Authenticator in turn, using the first one that
+// finds its kind of credentials in the request
+type Authenticators []Authenticator
+
+// Authenticate returns the principal from the first Authenticator that finds
+// credentials
+func (as Authenticators) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range as {
+		principal, err := a.Authenticate(r)
+		if err != errNoCredentials {
+			return principal, err
+		}
+	}
+
+	return Principal{}, errNoCredentials
+}
+
+// principalKey is the context key of the request's Principal
+const principalKey contextKey = "principal"
+
+// CurrentPrincipal returns who made the request a context belongs to, and
+// false if the request wasn't authenticated
+func CurrentPrincipal(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// authorize returns a middleware that only lets requests through if they
+// have credentials with at least the given role. It does nothing while
+// authentication is turned off
+func authorize(role Role) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a := getAuthenticator()
+			if a == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := a.Authenticate(r)
+			if err == nil {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey, principal))
+			}
+
+			if role == RolePublic {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch {
+			case err == errNoCredentials:
+				unauthorized(w, ErrUnauthorized)
+			case err != nil:
+				unauthorized(w, err)
+			case principal.Role < role:
+				writeJSONFail(w, http.StatusForbidden, ErrForbidden.Error())
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// unauthorized writes a 401, with the WWW-Authenticate header saying which
+// credentials can be used
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Add("WWW-Authenticate", `Bearer realm="books-api"`)
+	w.Header().Add("WWW-Authenticate", `Basic realm="books-api"`)
+	writeJSONFail(w, http.StatusUnauthorized, err.Error())
+}
+
+// APIKeys authenticates requests with static api keys, given either in the
+// X-API-Key header or as the password of basic auth, which is what e-reader
+// apps can send to the OPDS catalog. Only a hash of each key is kept
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// NewAPIKeys returns an APIKeys without any keys
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{keys: make(map[[sha256.Size]byte]Principal)}
+}
+
+// Add lets requests with the key in as the principal
+func (k *APIKeys) Add(key string, principal Principal) {
+	k.keys[sha256.Sum256([]byte(key))] = principal
+}
+
+// Len returns how many keys there are
+func (k *APIKeys) Len() int {
+	return len(k.keys)
+}
+
+// LoadAPIKeys reads api keys with one key on each line, formatted like
+// "role key [subject]", blank lines and lines starting with # are skipped.
+// The subject defaults to the role
+func LoadAPIKeys(r io.Reader) (*APIKeys, error) {
+	keys := NewAPIKeys()
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("Line %v of the api keys must be formatted like role key [subject]", line)
+		}
+
+		role, err := ParseRole(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Line %v of the api keys: %v", line, err)
+		}
+
+		subject := role.String()
+		if len(fields) == 3 {
+			subject = fields[2]
+		}
+
+		keys.Add(fields[1], Principal{Subject: subject, Role: role})
+	}
+
+	return keys, scanner.Err()
+}
+
+// Authenticate returns the principal for the request's api key
+func (k *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		_, password, ok := r.BasicAuth()
+		if !ok {
+			return Principal{}, errNoCredentials
+		}
+		key = password
+	}
+
+	principal, found := k.keys[sha256.Sum256([]byte(key))]
+	if !found {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	return principal, nil
+}
+
+// JWTVerifier authenticates requests with a JSON Web Token given as a bearer
+// token. Tokens can be signed with HMAC (HS256, HS384 or HS512) or RSA
+// (RS256, RS384 or RS512), and are verified with local keys picked by the
+// token's kid header, or the key without an id if there's no kid. The role
+// is the token's role claim and the subject its sub claim, every token has
+// to have an expiry
+type JWTVerifier struct {
+	hmacKeys map[string][]byte
+	rsaKeys  map[string]*rsa.PublicKey
+
+	// Issuer and Audience, if they're set, have to match the token's iss and
+	// aud claims
+	Issuer   string
+	Audience string
+
+	// Leeway is how far off the clocks of the api and whatever issued the
+	// token can be
+	Leeway time.Duration
+}
+
+// jwtClaims are the claims read from a token
+type jwtClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// NewJWTVerifier returns a JWTVerifier without any keys
+func NewJWTVerifier() *JWTVerifier {
+	return &JWTVerifier{
+		hmacKeys: make(map[string][]byte),
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+	}
+}
+
+// AddHMACKey adds a secret that HMAC signed tokens with the key id are
+// verified with, "" for tokens without a kid
+func (v *JWTVerifier) AddHMACKey(kid string, secret []byte) {
+	v.hmacKeys[kid] = secret
+}
+
+// AddRSAKey adds a public key that RSA signed tokens with the key id are
+// verified with, "" for tokens without a kid
+func (v *JWTVerifier) AddRSAKey(kid string, key *rsa.PublicKey) {
+	v.rsaKeys[kid] = key
+}
+
+// AddRSAKeyPEM adds a PEM encoded RSA public key, like AddRSAKey
+func (v *JWTVerifier) AddRSAKeyPEM(kid string, pem []byte) error {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return fmt.Errorf("Unable to read the RSA public key: %v", err)
+	}
+
+	v.AddRSAKey(kid, key)
+	return nil
+}
+
+// key returns the key a token is verified with, the kind of key has to match
+// the token's algorithm so that, for example, an RSA public key can't be used
+// as an HMAC secret
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if secret, found := v.hmacKeys[kid]; found {
+			return secret, nil
+		}
+	case *jwt.SigningMethodRSA:
+		if key, found := v.rsaKeys[kid]; found {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("There's no %v key with the id %q", token.Method.Alg(), kid)
+}
+
+// Authenticate returns the principal for the request's bearer token
+func (v *JWTVerifier) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return Principal{}, errNoCredentials
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.Leeway),
+	}
+	if v.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.Issuer))
+	}
+	if v.Audience != "" {
+		options = append(options, jwt.WithAudience(v.Audience))
+	}
+
+	var claims jwtClaims
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(header[7:]), &claims, v.key, options...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("The bearer token is invalid: %v", err)
+	}
+
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("The bearer token's role claim is invalid: %v", err)
+	}
+
+	return Principal{Subject: claims.Subject, Role: role}, nil
+}
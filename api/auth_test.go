@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	uuid "github.com/satori/go.uuid"
+)
+
+// signToken returns a token with the role and expiry signed with the key
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, role string, expires time.Time) string {
+	token := jwt.NewWithClaims(method, jwtClaims{
+		Role:             role,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "someone", ExpiresAt: jwt.NewNumericDate(expires)},
+	})
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Errorf("Got error when signing the token: %v", err)
+		t.FailNow()
+	}
+
+	return signed
+}
+
+// authStatus returns the status a request with the headers gets from the
+// route, and the error in its body
+func authStatus(t *testing.T, url, method string, headers map[string]string) (int, string) {
+	res, err := sendRequestWithHeaders(url, method, "", headers)
+	if err != nil {
+		t.Errorf("Got error when sending request for %v %v: %v", method, url, err)
+		t.FailNow()
+	}
+	defer res.Body.Close()
+
+	var body apiError
+	json.NewDecoder(res.Body).Decode(&body)
+
+	return res.StatusCode, body.Error
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	keys, err := LoadAPIKeys(strings.NewReader("# keys\n\nreader r3ad3r\nadmin 4dm1n ops\n"))
+	if err != nil {
+		t.Errorf("Got error when loading the api keys: %v", err)
+		t.FailNow()
+	}
+
+	if keys.Len() != 2 {
+		t.Errorf("Expected 2 api keys, got %v", keys.Len())
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("anyone", "4dm1n")
+	principal, err := keys.Authenticate(r)
+	if err != nil || principal != (Principal{Subject: "ops", Role: RoleAdmin}) {
+		t.Errorf("Expected the basic auth password to be the admin key, got %v %v", principal, err)
+	}
+
+	_, err = LoadAPIKeys(strings.NewReader("owner k3y\n"))
+	if err == nil || !strings.Contains(err.Error(), "Line 1") {
+		t.Errorf("Expected an unknown role to fail on line 1, got %v", err)
+	}
+}
+
+func TestAuthorizeAPIKeys(t *testing.T) {
+	keys := NewAPIKeys()
+	keys.Add("r3ad3r", Principal{Subject: "reader", Role: RoleReader})
+	keys.Add("l1br", Principal{Subject: "librarian", Role: RoleLibrarian})
+	SetAuthenticator(keys)
+	defer SetAuthenticator(nil)
+
+	missing, _ := uuid.NewV4()
+	url := "/books/" + missing.String()
+
+	status, message := authStatus(t, url, "DELETE", nil)
+	if status != http.StatusUnauthorized || message != ErrUnauthorized.Error() {
+		t.Errorf("Expected a 401 without an api key, got %v %q", status, message)
+	}
+
+	status, message = authStatus(t, url, "DELETE", map[string]string{"X-API-Key": "wrong"})
+	if status != http.StatusUnauthorized || message != ErrInvalidAPIKey.Error() {
+		t.Errorf("Expected a 401 with the wrong api key, got %v %q", status, message)
+	}
+
+	status, message = authStatus(t, url, "DELETE", map[string]string{"X-API-Key": "r3ad3r"})
+	if status != http.StatusForbidden || message != ErrForbidden.Error() {
+		t.Errorf("Expected a reader deleting a book to get a 403, got %v %q", status, message)
+	}
+
+	// the librarian gets as far as the handler, which can't find the book
+	status, _ = authStatus(t, url, "DELETE", map[string]string{"X-API-Key": "l1br"})
+	if status != http.StatusNotFound {
+		t.Errorf("Expected a librarian deleting a missing book to get a 404, got %v", status)
+	}
+
+	status, _ = authStatus(t, "/openapi.json", "GET", nil)
+	if status != http.StatusOK {
+		t.Errorf("Expected /openapi.json to be public, got %v", status)
+	}
+}
+
+func TestAuthorizeJWT(t *testing.T) {
+	secret := []byte("s3cr3t")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Errorf("Got error when generating the RSA key: %v", err)
+		t.FailNow()
+	}
+
+	verifier := NewJWTVerifier()
+	verifier.AddHMACKey("", secret)
+	verifier.AddRSAKey("", &rsaKey.PublicKey)
+	SetAuthenticator(Authenticators{NewAPIKeys(), verifier})
+	defer SetAuthenticator(nil)
+
+	hour := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"HMAC reader", signToken(t, jwt.SigningMethodHS256, secret, "reader", hour), http.StatusOK},
+		{"RSA admin", signToken(t, jwt.SigningMethodRS256, rsaKey, "admin", hour), http.StatusOK},
+		{"expired", signToken(t, jwt.SigningMethodHS256, secret, "admin", time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+		{"wrong secret", signToken(t, jwt.SigningMethodHS512, []byte("guess"), "admin", hour), http.StatusUnauthorized},
+		{"no role", signToken(t, jwt.SigningMethodHS256, secret, "", hour), http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		status, message := authStatus(t, "/books", "GET", map[string]string{"Authorization": "Bearer " + test.token})
+		if status != test.status {
+			t.Errorf("Expected the %v token to get a %v, got %v %q", test.name, test.status, status, message)
+		}
+	}
+
+	// an HMAC token signed with the RSA public key mustn't be accepted
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	verifier.AddRSAKeyPEM("", publicKey)
+	token := signToken(t, jwt.SigningMethodHS256, publicKey, "admin", hour)
+	status, _ := authStatus(t, "/books", "GET", map[string]string{"Authorization": "Bearer " + token})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected a token signed with the public key to get a 401, got %v", status)
+	}
+
+	reader := signToken(t, jwt.SigningMethodHS256, secret, "reader", hour)
+	status, _ = authStatus(t, "/books/import", "POST", map[string]string{"Authorization": "Bearer " + reader})
+	if status != http.StatusForbidden {
+		t.Errorf("Expected a reader importing books to get a 403, got %v", status)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// the modes a batch can be applied in
+const (
+	batchAtomic     = "atomic"
+	batchBestEffort = "best_effort"
+)
+
+// batchRequest is the body of a POST /books/batch request
+type batchRequest struct {
+	Mode       string                    `json:"mode"`
+	Operations []managers.BatchOperation `json:"operations"`
+}
+
+// batchItem is the result of a single operation in a batch, Status is the
+// status code the operation would have had as its own request
+type batchItem struct {
+	Index  int              `json:"index"`
+	Op     managers.BatchOp `json:"op"`
+	ID     uuid.UUID        `json:"id"`
+	Status int              `json:"status"`
+	Book   *model.Book      `json:"book,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// batchResponse is the body of the response to a POST /books/batch request
+type batchResponse struct {
+	Mode    string      `json:"mode"`
+	Applied int         `json:"applied"`
+	Failed  int         `json:"failed"`
+	Results []batchItem `json:"results"`
+}
+
+// batchSuccessStatuses are the status codes for each kind of operation that
+// succeeds
+var batchSuccessStatuses = map[managers.BatchOp]int{
+	managers.BatchCreate: http.StatusCreated,
+	managers.BatchUpdate: http.StatusOK,
+	managers.BatchDelete: http.StatusNoContent,
+}
+
+// PostBatch is the handler for the POST /books/batch api call,
+// it creates, updates and deletes many books at once and returns the result
+// of each operation. In atomic mode, the default, either every operation is
+// applied or none of them are, while in best_effort mode every operation
+// that can be applied is
+func PostBatch(w http.ResponseWriter, r *http.Request) {
+	var request batchRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	switch request.Mode {
+	case "":
+		request.Mode = batchAtomic
+	case batchAtomic, batchBestEffort:
+	default:
+		writeJSONFail(w, http.StatusBadRequest, "The mode must be either atomic or best_effort")
+		return
+	}
+
+	results, err := managers.ApplyBatch(request.Operations, request.Mode == batchAtomic)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	response := batchResponse{Mode: request.Mode, Results: make([]batchItem, len(results))}
+	for i, result := range results {
+		item := batchItem{
+			Index:  i,
+			Op:     result.Op,
+			ID:     result.ID,
+			Status: batchSuccessStatuses[result.Op],
+			Book:   result.Book,
+		}
+
+		if result.Error != nil {
+			item.Status = managerStatus(result.Error)
+			item.Error = result.Error.Error()
+			response.Failed++
+		} else {
+			response.Applied++
+		}
+
+		response.Results[i] = item
+	}
+
+	// an atomic batch with any failures wasn't applied at all, and a best
+	// effort one with failures was only partly applied
+	status := http.StatusOK
+	if response.Failed > 0 && request.Mode == batchAtomic {
+		status = http.StatusUnprocessableEntity
+	} else if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	err = writeJSONSuccess(w, response, status)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// postBatch sends a POST /books/batch request and decodes the response
+func postBatch(t *testing.T, body string) (int, batchResponse) {
+	res, err := sendRequest("/books/batch", "POST", body)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/batch: %v", err)
+		t.FailNow()
+	}
+
+	var response batchResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		t.Errorf("Error trying to read the body from POST /books/batch: %v", err)
+		t.FailNow()
+	}
+
+	return res.StatusCode, response
+}
+
+func TestPostBatch(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyBatchBook"
+	book.Rating = 2
+	managers.GetLibrary().AddBook(book)
+
+	status, response := postBatch(t, fmt.Sprintf(`{"operations": [
+		{"op": "create", "book": {"title": "MyNewBook", "rating": 1}},
+		{"op": "update", "id": "%v", "book": {"title": "MyUpdatedBook", "rating": 3}}
+	]}`, book.ID))
+
+	if status != 200 || response.Mode != "atomic" || response.Applied != 2 || response.Failed != 0 {
+		t.Errorf("Expected the whole batch to be applied, got %v %+v", status, response)
+		t.FailNow()
+	}
+
+	if response.Results[0].Status != 201 || response.Results[0].Book == nil || response.Results[1].Status != 200 {
+		t.Errorf("Wrong results from POST /books/batch, got %+v", response.Results)
+	}
+
+	if countBooks(t) != 2 {
+		t.Errorf("Expected 2 books after the batch, got %v", countBooks(t))
+	}
+}
+
+func TestPostBatchFailures(t *testing.T) {
+	defer cleanLibrary()
+
+	body := func(mode string) string {
+		return `{"mode": "` + mode + `", "operations": [
+			{"op": "create", "book": {"title": "MyNewBook", "rating": 1}},
+			{"op": "create", "book": {"title": "MyBadBook", "rating": 9}}
+		]}`
+	}
+
+	status, response := postBatch(t, body("atomic"))
+	if status != 422 || response.Applied != 0 || response.Failed != 2 {
+		t.Errorf("Expected an atomic batch with a bad book to fail, got %v %+v", status, response)
+	}
+
+	if response.Results[0].Status != 424 || response.Results[1].Status != 400 {
+		t.Errorf("Wrong results from a failed atomic batch, got %+v", response.Results)
+	}
+
+	if countBooks(t) != 0 {
+		t.Errorf("A failed atomic batch added books")
+	}
+
+	status, response = postBatch(t, body("best_effort"))
+	if status != 207 || response.Applied != 1 || response.Failed != 1 {
+		t.Errorf("Expected a best effort batch to partly apply, got %v %+v", status, response)
+	}
+
+	if countBooks(t) != 1 {
+		t.Errorf("A best effort batch didn't add the good book")
+	}
+
+	res, err := sendRequest("/books/batch", "POST", `{"mode": "sometimes", "operations": []}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/batch: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /books/batch with a bad mode, got %v", res.Status)
+	}
+}
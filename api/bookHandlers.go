@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"mime"
 	"net/http"
 
 	"github.com/askewseth/kubernetes/managers"
@@ -19,17 +21,38 @@ var (
 )
 
 // GetBooks is the handler for the GET /books api call,
-// it just returns a list of all of the books in the library
+// it returns the books in the library, filtered, sorted and paged by the
+// query parameters
 func GetBooks(w http.ResponseWriter, r *http.Request) {
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	library := managers.GetLibrary()
-	err := writeJSONSuccess(w, library.GetBooks(), http.StatusOK)
+	books, err := library.GetBooks()
+	if err != nil {
+		writeJSONFail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	books, total, err := managers.QueryBooks(books, query)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writePageHeaders(w, r, query, total)
+
+	err = writeJSONSuccess(w, books, http.StatusOK)
 	if err != nil {
 		log.Error(err)
 	}
 }
 
 // PostBook is the handler for the POST /books api call,
-// it will add a new book to the library
+// it will add a new book to the library with a new id and return it
 func PostBook(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 
@@ -43,20 +66,39 @@ func PostBook(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	// validate that the books attributes are in the appropriate bounds
-	err = book.Validate()
+	err = managers.ValidateBook(book)
 	if err != nil {
 		writeJSONFail(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// the status comes from the book's loans, so new books start out
+	// CheckedIn, and any id in the body is replaced so that an existing
+	// book, with its loans and revisions, can't be written over
+	book.ID = model.NewBook().ID
+	book.Status = model.CheckedIn
+
 	library := managers.GetLibrary()
-	library.AddBook(book)
+	err = library.AddBook(book)
+	if err != nil {
+		writeJSONFail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	writeJSONSuccess(w, "", http.StatusCreated)
+	created, err := library.GetBookByID(book.ID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/books/"+created.ID.String())
+	w.Header().Set("ETag", bookETag(created))
+	writeJSONSuccess(w, created, http.StatusCreated)
 }
 
 // PutBook is the handler for the PUT /books/{id} api call,
-// it will modify the given fields in the API
+// it will replace the book with the one in the body, any field that isn't
+// given is cleared
 func PutBook(w http.ResponseWriter, r *http.Request) {
 	parameters := mux.Vars(r)
 	id, err := uuid.FromString(parameters["id"])
@@ -65,7 +107,7 @@ func PutBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	book := model.NewDefaultBook()
+	var book model.Book
 	err = json.NewDecoder(r.Body).Decode(&book)
 	if err != nil {
 		writeJSONFail(w, http.StatusBadRequest, err.Error())
@@ -73,23 +115,139 @@ func PutBook(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	// validate that the books attributes are in the appropriate bounds
-	err = book.Validate()
+	replaced, err := managers.UpdateBook(id, func(current *model.Book) error {
+		if err := checkIfMatch(r, *current); err != nil {
+			return err
+		}
+		return replaceBook(current, book)
+	})
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(replaced))
+	writeJSONSuccess(w, "", http.StatusAccepted)
+}
+
+// PatchBook is the handler for the PATCH /books/{id} api call,
+// it applies either a JSON Merge Patch or a JSON Patch, depending on the
+// Content-Type, to the book and returns the patched book
+func PatchBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		writeJSONFail(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	defer r.Body.Close()
 
-	book.ID = id
+	// apply turns the book, as a decoded json document, into the patched
+	// version of it
+	var apply func(doc interface{}) (interface{}, error)
 
-	library := managers.GetLibrary()
-	err = library.ModifyBook(book)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case mergePatchType:
+		var patch interface{}
+		if err = json.Unmarshal(body, &patch); err != nil {
+			writeJSONFail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		apply = func(doc interface{}) (interface{}, error) {
+			return mergePatch(doc, patch), nil
+		}
+
+	case jsonPatchType:
+		operations, err := parseJSONPatch(body)
+		if err != nil {
+			writeJSONFail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		apply = func(doc interface{}) (interface{}, error) {
+			return applyJSONPatch(doc, operations)
+		}
+
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		writeJSONFail(w, http.StatusUnsupportedMediaType, "The Content-Type must be "+mergePatchType+" or "+jsonPatchType)
+		return
+	}
+
+	patched, err := managers.UpdateBook(id, func(current *model.Book) error {
+		if err := checkIfMatch(r, *current); err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+
+		var doc interface{}
+		if err = json.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+
+		doc, err = apply(doc)
+		if err == ErrPatchTestFailed {
+			return err
+		}
+		if err != nil {
+			return badRequestError{err}
+		}
+
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return badRequestError{errors.New("The patched book must be a json object")}
+		}
+
+		b, err = json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		var book model.Book
+		if err = json.Unmarshal(b, &book); err != nil {
+			return badRequestError{err}
+		}
+
+		return replaceBook(current, book)
+	})
 	if err != nil {
-		writeJSONFail(w, http.StatusNotFound, err.Error())
+		writeManagerFail(w, err)
 		return
 	}
 
-	writeJSONSuccess(w, "", http.StatusAccepted)
+	w.Header().Set("ETag", bookETag(patched))
+	err = writeJSONSuccess(w, patched, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// replaceBook replaces the current version of a book with a new one given
+// by a client. The status comes from the book's loans so it's kept from the
+// current book rather than taken from the client, and the revision is
+// managed by the book store
+func replaceBook(current *model.Book, book model.Book) error {
+	book.ID = current.ID
+	book.Status = current.Status
+	book.Revision = current.Revision
+
+	// validate that the books attributes are in the appropriate bounds
+	err := managers.ValidateBook(book)
+	if err != nil {
+		return badRequestError{err}
+	}
+
+	*current = book
+	return nil
 }
 
 // DeleteBook is the handler for the DELETE /books/{id} call
@@ -102,10 +260,13 @@ func DeleteBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	library := managers.GetLibrary()
-	err = library.DeleteBook(id)
+	// books that are checked out can't be removed until they're returned,
+	// and books that patrons are waiting for can't be removed at all
+	err = managers.GetCirculation().RemoveBook(id, func(book model.Book) error {
+		return checkIfMatch(r, book)
+	})
 	if err != nil {
-		writeJSONFail(w, http.StatusNotFound, err.Error())
+		writeManagerFail(w, err)
 		return
 	}
 
@@ -113,7 +274,8 @@ func DeleteBook(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetBookByID is the handler for the GET /books/{id} call
-// it will return a specific book from the library given it's uuid
+// it will return a specific book from the library given it's uuid, along
+// with its ETag
 func GetBookByID(w http.ResponseWriter, r *http.Request) {
 	// parse the uuid
 	parameters := mux.Vars(r)
@@ -127,7 +289,15 @@ func GetBookByID(w http.ResponseWriter, r *http.Request) {
 	library := managers.GetLibrary()
 	book, err := library.GetBookByID(id)
 	if err != nil {
-		writeJSONFail(w, http.StatusNotFound, err.Error())
+		writeManagerFail(w, err)
+		return
+	}
+
+	// the client already has this revision of the book
+	etag := bookETag(book)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/askewseth/kubernetes/managers"
 	model "github.com/askewseth/kubernetes/models"
@@ -48,15 +49,26 @@ func sendRequest(url, method, data string) (*http.Response, error) {
 }
 
 func cleanLibrary() {
-	library := managers.GetLibrary()
-	library.Books = make(map[uuid.UUID]model.Book)
+	managers.SetLibrary(managers.NewLibrary())
+	managers.SetCirculation(managers.NewCirculation())
+}
+
+// countBooks returns the number of books in the global library
+func countBooks(t *testing.T) int {
+	books, err := managers.GetLibrary().GetBooks()
+	if err != nil {
+		t.Errorf("Error getting the books from the library: %v", err)
+		t.FailNow()
+	}
+
+	return len(books)
 }
 
 func TestGetBooksAPI(t *testing.T) {
 	defer cleanLibrary()
 
 	library := managers.GetLibrary()
-	library.Books[uuid.UUID{}] = model.Book{Title: "MyBook"}
+	library.AddBook(model.Book{Title: "MyBook"})
 
 	res, err := sendRequest("/books", "GET", "")
 	if err != nil {
@@ -83,7 +95,7 @@ func TestGetBookByID(t *testing.T) {
 	defer cleanLibrary()
 
 	library := managers.GetLibrary()
-	library.Books[uuid.UUID{}] = model.Book{Title: "MyBook"}
+	library.AddBook(model.Book{Title: "MyBook"})
 
 	res, err := sendRequest("/books/"+uuid.UUID{}.String(), "GET", "")
 	if err != nil {
@@ -139,7 +151,7 @@ func TestDeleteBook(t *testing.T) {
 	defer cleanLibrary()
 
 	library := managers.GetLibrary()
-	library.Books[uuid.UUID{}] = model.Book{Title: "MyBook"}
+	library.AddBook(model.Book{Title: "MyBook"})
 
 	res, err := sendRequest("/books/"+uuid.UUID{}.String(), "DELETE", "")
 	if err != nil {
@@ -152,8 +164,7 @@ func TestDeleteBook(t *testing.T) {
 		fmt.Println(res.Status)
 	}
 
-	if len(library.Books) != 0 {
-		fmt.Printf("Had books %+v\n", library.Books)
+	if countBooks(t) != 0 {
 		t.Errorf("DELETE /books/{id} didn't actually delete book from library")
 	}
 }
@@ -191,9 +202,15 @@ func TestDeleteBookBadUUID(t *testing.T) {
 func TestPostBook(t *testing.T) {
 	defer cleanLibrary()
 
-	library := managers.GetLibrary()
+	// the id of an existing book is ignored rather than written over
+	existing := model.NewBook()
+	existing.Title = "MyCheckedOutBook"
+	existing.Rating = 1
+	existing.Status = model.CheckedOut
+	managers.GetLibrary().AddBook(existing)
 
 	book := map[string]interface{}{
+		"id":     existing.ID.String(),
 		"title":  "MyPostBook",
 		"rating": 1,
 	}
@@ -212,8 +229,23 @@ func TestPostBook(t *testing.T) {
 		t.Errorf("Expected status 201 from POST /books, got %v", res.Status)
 	}
 
-	if len(library.Books) != 1 {
-		t.Errorf("Didn't have 1 book in the library after calling POST /books")
+	var created model.Book
+	err = json.NewDecoder(res.Body).Decode(&created)
+	if err != nil || uuid.Equal(created.ID, existing.ID) || created.Title != "MyPostBook" || created.Revision != 1 {
+		t.Errorf("Expected the new book with a new id from POST /books, got %+v (%v)", created, err)
+	}
+
+	if location := res.Header.Get("Location"); location != "/books/"+created.ID.String() {
+		t.Errorf("Expected the new book's Location, got %v", location)
+	}
+
+	stored, _ := managers.GetLibrary().GetBookByID(existing.ID)
+	if stored.Title != existing.Title || stored.Status != model.CheckedOut {
+		t.Errorf("Expected POST /books not to change the existing book, got %+v", stored)
+	}
+
+	if countBooks(t) != 2 {
+		t.Errorf("Didn't have 2 books in the library after calling POST /books")
 	}
 }
 
@@ -224,7 +256,7 @@ func TestPutBook(t *testing.T) {
 
 	id, _ := uuid.NewV4()
 	book := model.Book{Title: "MyPutBook", ID: id}
-	library.Books[id] = book
+	library.AddBook(book)
 
 	newBook := map[string]interface{}{
 		"title":  "MyNewPutBook",
@@ -246,11 +278,174 @@ func TestPutBook(t *testing.T) {
 		t.Errorf("Didn't get status accepted on good PUT request, got status %v", res.StatusCode)
 	}
 
-	if len(library.Books) != 1 && library.Books[id].Title != newBook["title"] {
+	modified, err := library.GetBookByID(id)
+	if err != nil || modified.Title != newBook["title"] {
 		t.Errorf("Didn't PUT /book/{id} correctly")
 	}
 }
 
+func TestPutBookReplaces(t *testing.T) {
+	defer cleanLibrary()
+
+	library := managers.GetLibrary()
+
+	publishDate := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	book := model.NewBook()
+	book.Title = "MyPutBook"
+	book.Author = "Me"
+	book.PublishDate = &publishDate
+	book.Rating = 2
+	library.AddBook(book)
+
+	res, err := sendRequest("/books/"+book.ID.String(), "PUT", `{"title": "-1", "rating": 1}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for PUT /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 {
+		t.Errorf("Didn't get status accepted on good PUT request, got status %v", res.StatusCode)
+	}
+
+	modified, _ := library.GetBookByID(book.ID)
+	if modified.Title != "-1" || modified.Author != "" || modified.PublishDate != nil || modified.Rating != 1 {
+		t.Errorf("PUT /books/{id} didn't replace the whole book, got %+v", modified)
+	}
+
+	res, err = sendRequest("/books/"+book.ID.String(), "PUT", `{"title": "NoRating"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for PUT /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from PUT /books/{id} without a rating, got %v", res.Status)
+	}
+}
+
+// sendRequestWithHeaders sends a request like sendRequest with the given
+// headers set on it
+func sendRequestWithHeaders(url, method, data string, headers map[string]string) (*http.Response, error) {
+	request, err := http.NewRequest(method, server.URL+url, strings.NewReader(data))
+	if err != nil {
+		return &http.Response{}, fmt.Errorf("Error making request: %v", err)
+	}
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	return http.DefaultClient.Do(request)
+}
+
+// sendPatch sends a PATCH request with the given content type
+func sendPatch(url, contentType, data string) (*http.Response, error) {
+	return sendRequestWithHeaders(url, "PATCH", data, map[string]string{"Content-Type": contentType})
+}
+
+func TestPatchBook(t *testing.T) {
+	defer cleanLibrary()
+
+	library := managers.GetLibrary()
+
+	publishDate := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	book := model.NewBook()
+	book.Title = "MyPatchBook"
+	book.Author = "Me"
+	book.PublishDate = &publishDate
+	book.Rating = 2
+	library.AddBook(book)
+
+	url := "/books/" + book.ID.String()
+
+	// a merge patch changes what's given, clears what's null and leaves
+	// everything else alone, including the status
+	res, err := sendPatch(url, "application/merge-patch+json", `{"title": "-1", "publish_date": null, "status": "Lost"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for PATCH /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	var patched model.Book
+	err = json.NewDecoder(res.Body).Decode(&patched)
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("Error getting PATCH /books/{id}: %v %v", res.Status, err)
+		t.FailNow()
+	}
+
+	if patched.Title != "-1" || patched.PublishDate != nil || patched.Author != "Me" || patched.Rating != 2 || patched.Status != model.CheckedIn {
+		t.Errorf("PATCH /books/{id} didn't merge the patch, got %+v", patched)
+	}
+
+	stored, _ := library.GetBookByID(book.ID)
+	if stored != patched {
+		t.Errorf("PATCH /books/{id} returned %+v but stored %+v", patched, stored)
+	}
+
+	res, err = sendPatch(url, "application/json-patch+json", `[
+		{"op": "test", "path": "/author", "value": "Me"},
+		{"op": "replace", "path": "/title", "value": "MyJSONPatchBook"},
+		{"op": "remove", "path": "/author"}
+	]`)
+	if err != nil {
+		t.Errorf("Got error when sending request for PATCH /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from PATCH /books/{id} with a JSON Patch, got %v", res.Status)
+	}
+
+	stored, _ = library.GetBookByID(book.ID)
+	if stored.Title != "MyJSONPatchBook" || stored.Author != "" {
+		t.Errorf("PATCH /books/{id} didn't apply the JSON Patch, got %+v", stored)
+	}
+}
+
+func TestPatchBookErrors(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyPatchBook"
+	book.Rating = 2
+	managers.GetLibrary().AddBook(book)
+	book, _ = managers.GetLibrary().GetBookByID(book.ID)
+
+	url := "/books/" + book.ID.String()
+	missing, _ := uuid.NewV4()
+
+	tests := []struct {
+		url, contentType, patch string
+		status                  int
+	}{
+		{url, "application/json", `{"title": "x"}`, 415},
+		{url, "application/merge-patch+json", `{"title": `, 400},
+		{url, "application/merge-patch+json", `{"rating": null}`, 400},
+		{url, "application/merge-patch+json", `{"rating": "high"}`, 400},
+		{url, "application/json-patch+json", `[{"op": "remove", "path": "/author"}]`, 400},
+		{url, "application/json-patch+json", `[{"op": "test", "path": "/title", "value": "Other"}]`, 409},
+		{"/books/" + missing.String(), "application/merge-patch+json", `{"title": "x"}`, 404},
+		{"/books/4", "application/merge-patch+json", `{"title": "x"}`, 400},
+	}
+
+	for _, test := range tests {
+		res, err := sendPatch(test.url, test.contentType, test.patch)
+		if err != nil {
+			t.Errorf("Got error when sending request for PATCH /books/{id}: %v", err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != test.status {
+			t.Errorf("Expected status %v from PATCH /books/{id} with %v %v, got %v", test.status, test.contentType, test.patch, res.Status)
+		}
+	}
+
+	stored, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if stored != book {
+		t.Errorf("A failed PATCH /books/{id} changed the book to %+v", stored)
+	}
+}
+
 func TestPutBadBook(t *testing.T) {
 	defer cleanLibrary()
 
@@ -276,6 +471,20 @@ func TestPutBadBook(t *testing.T) {
 	}
 }
 
+func TestPostBookControlCharacters(t *testing.T) {
+	defer cleanLibrary()
+
+	res, err := sendRequest("/books", "POST", `{"title": "My\u001fBook", "rating": 1}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /books with a MARC delimiter in the title, got %v", res.Status)
+	}
+}
+
 func TestPutBadUUID(t *testing.T) {
 	defer cleanLibrary()
 
@@ -289,3 +498,211 @@ func TestPutBadUUID(t *testing.T) {
 		t.Errorf("Didn't get status not found on good PUT request")
 	}
 }
+
+func TestGetBooksQuery(t *testing.T) {
+	defer cleanLibrary()
+
+	library := managers.GetLibrary()
+	for _, title := range []string{"A", "B", "C", "D", "E"} {
+		book := model.NewBook()
+		book.Title = title
+		book.Author = "me"
+		book.Rating = 2
+		library.AddBook(book)
+	}
+
+	res, err := sendRequest("/books?author=me&sort=title&order=desc&limit=2&offset=2", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /books, got %v", res.Status)
+	}
+
+	var books []map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&books)
+	if err != nil {
+		t.Errorf("Error trying to read the body from GET /books: %v", err)
+		t.FailNow()
+	}
+
+	if len(books) != 2 || books[0]["title"] != "C" || books[1]["title"] != "B" {
+		t.Errorf("Didn't get the third page of books in descending order, got %v", books)
+	}
+
+	if res.Header.Get("X-Total-Count") != "5" {
+		t.Errorf("Expected an X-Total-Count of 5, got %v", res.Header.Get("X-Total-Count"))
+	}
+
+	links := strings.Join(res.Header["Link"], ", ")
+	if !strings.Contains(links, "offset=4") || !strings.Contains(links, `rel="next"`) ||
+		!strings.Contains(links, "offset=0") || !strings.Contains(links, `rel="prev"`) {
+		t.Errorf("Expected next and prev links, got %v", links)
+	}
+}
+
+func TestGetBooksHugeLimit(t *testing.T) {
+	defer cleanLibrary()
+
+	for _, title := range []string{"A", "B"} {
+		book := model.NewBook()
+		book.Title = title
+		managers.GetLibrary().AddBook(book)
+	}
+
+	res, err := sendRequest("/books?offset=1&limit=9223372036854775807", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /books with the largest limit, got %v", res.Status)
+	}
+
+	var books []model.Book
+	json.NewDecoder(res.Body).Decode(&books)
+	if len(books) != 1 || books[0].Title != "B" {
+		t.Errorf("Expected the books after the offset, got %v", books)
+	}
+
+	if links := strings.Join(res.Header["Link"], ", "); strings.Contains(links, `rel="next"`) {
+		t.Errorf("Expected no next link when the limit reaches past the end, got %v", links)
+	}
+}
+
+func TestGetBooksPublishedBefore(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyAfternoonBook"
+	published := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	book.PublishDate = &published
+	managers.GetLibrary().AddBook(book)
+
+	for query, expected := range map[string]int{
+		"published_before=2018-01-02":           1,
+		"published_before=2018-01-01":           0,
+		"published_before=2018-01-02T12:00:00Z": 0,
+	} {
+		res, err := sendRequest("/books?"+query, "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET /books: %v", err)
+			t.FailNow()
+		}
+
+		var books []model.Book
+		json.NewDecoder(res.Body).Decode(&books)
+		if len(books) != expected {
+			t.Errorf("Expected %v books from GET /books?%v, got %v", expected, query, len(books))
+		}
+	}
+}
+
+func TestGetBooksBadQuery(t *testing.T) {
+	defer cleanLibrary()
+
+	for _, query := range []string{"min_rating=4", "status=Missing", "sort=id", "order=up", "limit=-1", "published_after=yesterday"} {
+		res, err := sendRequest("/books?"+query, "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET /books: %v", err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status 400 from GET /books?%v, got %v", query, res.Status)
+		}
+	}
+}
+
+func TestBookETags(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyETagBook"
+	book.Rating = 2
+	managers.GetLibrary().AddBook(book)
+
+	url := "/books/" + book.ID.String()
+
+	res, err := sendRequest(url, "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	etag := res.Header.Get("ETag")
+	if etag != `"1"` {
+		t.Errorf("Expected the ETag of a new book to be \"1\", got %v", etag)
+	}
+
+	res, err = sendRequestWithHeaders(url, "GET", "", map[string]string{"If-None-Match": etag})
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 304 {
+		t.Errorf("Expected status 304 from GET /books/{id} with a matching If-None-Match, got %v", res.Status)
+	}
+
+	// the first clerk's change goes through and changes the ETag
+	res, err = sendRequestWithHeaders(url, "PUT", `{"title": "First", "rating": 1}`, map[string]string{"If-Match": etag})
+	if err != nil {
+		t.Errorf("Got error when sending request for PUT /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 || res.Header.Get("ETag") != `"2"` {
+		t.Errorf("Expected status 202 and ETag \"2\" from PUT /books/{id}, got %v and %v", res.Status, res.Header.Get("ETag"))
+	}
+
+	// the second clerk's changes are all made against the stale ETag
+	stale := []struct {
+		method, contentType, body string
+	}{
+		{"PUT", "application/json", `{"title": "Second", "rating": 1}`},
+		{"PATCH", "application/merge-patch+json", `{"title": "Second"}`},
+		{"DELETE", "", ""},
+	}
+
+	for _, request := range stale {
+		headers := map[string]string{"If-Match": etag, "Content-Type": request.contentType}
+		res, err = sendRequestWithHeaders(url, request.method, request.body, headers)
+		if err != nil {
+			t.Errorf("Got error when sending request for %v /books/{id}: %v", request.method, err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != 412 {
+			t.Errorf("Expected status 412 from %v /books/{id} with a stale If-Match, got %v", request.method, res.Status)
+		}
+	}
+
+	stored, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if stored.Title != "First" || stored.Revision != 2 {
+		t.Errorf("A request with a stale If-Match changed the book to %+v", stored)
+	}
+
+	res, err = sendRequestWithHeaders(url, "GET", "", map[string]string{"If-None-Match": etag})
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /books/{id} with a stale If-None-Match, got %v", res.Status)
+	}
+
+	res, err = sendRequestWithHeaders(url, "DELETE", "", map[string]string{"If-Match": `"2"`})
+	if err != nil {
+		t.Errorf("Got error when sending request for DELETE /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 {
+		t.Errorf("Expected status 202 from DELETE /books/{id} with a matching If-Match, got %v", res.Status)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/askewseth/kubernetes/config"
+)
+
+var (
+	// effectiveConfig is the redacted config the server is running with
+	effectiveConfig   = config.Default()
+	effectiveConfigMu sync.RWMutex
+)
+
+// SetConfig sets the config that GET /admin/config shows, its secrets are
+// redacted before it's stored
+func SetConfig(c config.Config) {
+	effectiveConfigMu.Lock()
+	defer effectiveConfigMu.Unlock()
+
+	effectiveConfig = c.Redact()
+}
+
+// GetConfig is the handler for the GET /admin/config api call,
+// it returns the config the server is running with, after the defaults,
+// config file, environment and flags have all been applied, with its
+// secrets redacted
+func GetConfig(w http.ResponseWriter, r *http.Request) {
+	effectiveConfigMu.RLock()
+	c := effectiveConfig
+	effectiveConfigMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err := writeJSONSuccess(w, c, http.StatusOK)
+	if err != nil {
+		requestLog(r).Errorf("Error writing the config: %v", err)
+	}
+}
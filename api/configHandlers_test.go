@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/askewseth/kubernetes/config"
+)
+
+func TestGetConfig(t *testing.T) {
+	defer SetConfig(config.Default())
+
+	c := config.Default()
+	c.Auth.JWTHMACKey = "s3cr3t"
+	SetConfig(c)
+
+	res, err := sendRequest("/admin/config", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /admin/config: %v", err)
+		t.FailNow()
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Auth  map[string]interface{} `json:"auth"`
+		Loans map[string]interface{} `json:"loans"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Errorf("Unable to decode the config: %v", err)
+		t.FailNow()
+	}
+
+	if body.Auth["jwt_hmac_key"] != config.Redacted {
+		t.Errorf("Expected the HMAC key to be redacted, got %v", body.Auth["jwt_hmac_key"])
+	}
+	if body.Loans["loan_period"] != "336h0m0s" {
+		t.Errorf("Expected the loan period as a duration, got %v", body.Loans["loan_period"])
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// the most a catalog upload can be, anything bigger is cut off by the
+// route's limitBody
+const maxImportSize = 64 << 20
+
+// ExportBooksCSV is the handler for the GET /books/export.csv api call,
+// it writes every book matching the same filters as GET /books as a csv
+// with the columns in managers.CSVColumns
+func ExportBooksCSV(w http.ResponseWriter, r *http.Request) {
+	exportBooks(w, r, "text/csv; charset=utf-8", "books.csv", managers.WriteCSV)
+}
+
+// exportBooks writes every book matching the same filters as GET /books as
+// a file download, using write to format the books
+func exportBooks(w http.ResponseWriter, r *http.Request, contentType, filename string, write func(io.Writer, []model.Book) error) {
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := managers.GetLibrary().GetBooks()
+	if err != nil {
+		writeJSONFail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	books, _, err = managers.QueryBooks(books, query)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	// the status has already been sent, so all that can be done about an
+	// error is to log it
+	err = write(w, books)
+	if err != nil {
+		log.Errorf("Error writing the %v export: %v", filename, err)
+	}
+}
+
+// importers holds the function that reads each format POST /books/import
+// accepts
+var importers = map[string]func(io.Reader, managers.ImportOptions) (managers.ImportReport, error){
+	"csv":     managers.ImportCSV,
+	"marc":    managers.ImportMARC,
+	"marcxml": managers.ImportMARCXML,
+}
+
+// ImportBooks is the handler for the POST /books/import api call,
+// it imports the books in a csv, MARC21 or MARCXML file, given either as
+// the body or as the file field of a multipart form, and returns a report
+// with the rows that couldn't be imported. The format is the format query
+// parameter, or if that isn't given it's worked out from the content type
+// or the uploaded file's name, and is csv if it can't be
+func ImportBooks(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	options := managers.ImportOptions{Mapping: make(map[string]string)}
+
+	var err error
+	if options.DryRun, err = parseBool(values.Get("dry_run")); err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The dry_run must be true or false")
+		return
+	}
+	if options.Atomic, err = parseBool(values.Get("atomic")); err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The atomic must be true or false")
+		return
+	}
+	if options.DefaultRating, err = parseRating(values, "default_rating"); err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := values.Get("format")
+	if _, found := importers[format]; format != "" && !found {
+		writeJSONFail(w, http.StatusBadRequest, "The format must be csv, marc or marcxml")
+		return
+	}
+
+	// each map is given as header=column
+	for _, m := range values["map"] {
+		i := strings.LastIndex(m, "=")
+		if i < 0 {
+			writeJSONFail(w, http.StatusBadRequest, "Each map must be formatted like header=column")
+			return
+		}
+		options.Mapping[m[:i]] = m[i+1:]
+	}
+
+	defer r.Body.Close()
+
+	var upload io.Reader = r.Body
+	filename := ""
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeJSONFail(w, http.StatusBadRequest, "The catalog must be uploaded as the file field")
+			return
+		}
+		defer file.Close()
+		upload = file
+		filename = header.Filename
+		contentType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+	}
+
+	if format == "" {
+		format = importFormat(contentType, filename)
+	}
+
+	report, err := importers[format](upload, options)
+	switch err.(type) {
+	case nil:
+	case *csv.ParseError, *xml.SyntaxError:
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	case *http.MaxBytesError:
+		writeJSONFail(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	default:
+		writeManagerFail(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if options.Atomic && report.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	err = writeJSONSuccess(w, report, status)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// importFormat returns the import format for an upload's content type, or
+// failing that its file name
+func importFormat(contentType, filename string) string {
+	switch contentType {
+	case "application/marc":
+		return "marc"
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		return "marcxml"
+	}
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".mrc", ".marc":
+		return "marc"
+	case ".xml":
+		return "marcxml"
+	}
+
+	return "csv"
+}
+
+// parseBool reads an optional boolean query parameter
+func parseBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(s)
+}
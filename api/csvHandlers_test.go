@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestExportBooksCSV(t *testing.T) {
+	defer cleanLibrary()
+
+	for _, title := range []string{"B", "A"} {
+		book := model.NewBook()
+		book.Title = title
+		book.Rating = 1
+		managers.GetLibrary().AddBook(book)
+	}
+
+	res, err := sendRequest("/books/export.csv", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/export.csv: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Errorf("Expected a csv from GET /books/export.csv, got %v %v", res.Status, res.Header.Get("Content-Type"))
+	}
+
+	records, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Errorf("Error reading the csv from GET /books/export.csv: %v", err)
+		t.FailNow()
+	}
+
+	if len(records) != 3 || records[1][1] != "A" || records[2][1] != "B" {
+		t.Errorf("Expected a header and the 2 books sorted by title, got %v", records)
+	}
+}
+
+// decodeImportReport reads the report from a POST /books/import response
+func decodeImportReport(t *testing.T, url, contentType, body string) (int, managers.ImportReport) {
+	res, err := sendRequestWithHeaders(url, "POST", body, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/import: %v", err)
+		t.FailNow()
+	}
+
+	var report managers.ImportReport
+	err = json.NewDecoder(res.Body).Decode(&report)
+	if err != nil {
+		t.Errorf("Error trying to read the body from POST /books/import: %v", err)
+		t.FailNow()
+	}
+
+	return res.StatusCode, report
+}
+
+func TestImportBooks(t *testing.T) {
+	defer cleanLibrary()
+
+	body := "Name,rating\nMyImportedBook,2\nMyBadBook,0\n"
+
+	status, report := decodeImportReport(t, "/books/import?dry_run=true&map=Name=title", "text/csv", body)
+	if status != 200 || !report.DryRun || report.Created != 1 || report.Failed != 1 || countBooks(t) != 0 {
+		t.Errorf("Wrong dry run from POST /books/import, got %v %+v", status, report)
+	}
+
+	status, report = decodeImportReport(t, "/books/import?atomic=true&map=Name=title", "text/csv", body)
+	if status != 422 || report.Applied || countBooks(t) != 0 {
+		t.Errorf("Expected a 422 from an atomic POST /books/import with a bad row, got %v %+v", status, report)
+	}
+
+	// upload the same csv as a form
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "books.csv")
+	part.Write([]byte(body))
+	writer.Close()
+
+	status, report = decodeImportReport(t, "/books/import?map=Name=title", writer.FormDataContentType(), form.String())
+	if status != 200 || !report.Applied || len(report.Errors) != 1 || report.Errors[0].Row != 3 {
+		t.Errorf("Wrong report from POST /books/import, got %v %+v", status, report)
+	}
+
+	books, _ := managers.GetLibrary().GetBooks()
+	if len(books) != 1 || books[0].Title != "MyImportedBook" {
+		t.Errorf("Expected the good row to be imported, got %+v", books)
+	}
+
+	res, err := sendRequest("/books/import?map=Name=shelf", "POST", body)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/import: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /books/import with a bad mapping, got %v", res.Status)
+	}
+}
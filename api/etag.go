@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+var (
+	// ErrPreconditionFailed is returned when the If-Match header of a request
+	// doesn't match the book's current ETag, which means someone else has
+	// changed the book since the client last read it
+	ErrPreconditionFailed = errors.New("The book has been changed since it was read, get it again and retry")
+)
+
+// bookETag returns the ETag for a revision of a book
+func bookETag(book model.Book) string {
+	return `"` + strconv.FormatUint(book.Revision, 10) + `"`
+}
+
+// etagMatches returns true if the list of ETags from an If-Match or
+// If-None-Match header has the given ETag in it, or is *. Weak ETags only
+// match when weak comparison is allowed
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch returns ErrPreconditionFailed if the request has an If-Match
+// header that doesn't match the current revision of the book
+func checkIfMatch(r *http.Request, book model.Book) error {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, bookETag(book), false) {
+		return nil
+	}
+
+	return ErrPreconditionFailed
+}
@@ -0,0 +1,24 @@
+package api
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		match  bool
+	}{
+		{`"3"`, false, true},
+		{`"2", "3"`, false, true},
+		{`"2"`, false, false},
+		{`*`, false, true},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+	}
+
+	for _, test := range tests {
+		if etagMatches(test.header, `"3"`, test.weak) != test.match {
+			t.Errorf("Expected matching %v against \"3\" with weak %v to be %v", test.header, test.weak, test.match)
+		}
+	}
+}
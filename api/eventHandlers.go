@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+// eventStreamType is the media type of a Server-Sent Events stream
+const eventStreamType = "text/event-stream"
+
+// eventRetry is how long clients are told to wait before reconnecting
+const eventRetry = 3 * time.Second
+
+// eventKeepAlive is how often a comment is sent on an idle stream, so that
+// proxies don't time the connection out
+var eventKeepAlive = 15 * time.Second
+
+// resetEvent is sent instead of the missed events when a client can't be
+// resumed from its Last-Event-ID, it should read GET /books again
+const resetEvent = "reset"
+
+// writeEvent writes a single Server-Sent Event
+func writeEvent(w http.ResponseWriter, id uint64, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal the event: %v", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", id, event, b)
+	return err
+}
+
+// lastEventID returns the id of the last event a client got, from the
+// Last-Event-ID header that EventSource sends when it reconnects, or the
+// lastEventId query parameter for clients that can't set headers, and false
+// if the client didn't give one
+func lastEventID(r *http.Request) (uint64, bool, error) {
+	s := r.Header.Get("Last-Event-ID")
+	if s == "" {
+		s = r.URL.Query().Get("lastEventId")
+	}
+	if s == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("The Last-Event-ID %q must be an event id", s)
+	}
+
+	return id, true, nil
+}
+
+// GetBookEvents is the handler for the GET /books/events api call,
+// it streams the changes made to the books as Server-Sent Events. A client
+// that reconnects with the Last-Event-ID it got is sent the events it missed
+// first, or a reset event if they're no longer known
+func GetBookEvents(w http.ResponseWriter, r *http.Request) {
+	lastID, resume, err := lastEventID(r)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the stream stays open far longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	events := managers.GetEvents()
+	var sub *managers.Subscription
+	var missed []managers.Event
+	resumed := true
+	if resume {
+		sub, missed, resumed = events.Resume(lastID)
+	} else {
+		sub = events.Subscribe()
+	}
+	defer events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", eventStreamType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %v\n\n", eventRetry.Milliseconds())
+
+	if !resumed {
+		err = writeEvent(w, events.LastID(), resetEvent, struct{}{})
+	}
+	for _, event := range missed {
+		if err != nil {
+			break
+		}
+		err = writeEvent(w, event.ID, string(event.Type), event)
+	}
+	if err != nil {
+		return
+	}
+	http.NewResponseController(w).Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			// the stream fell too far behind, or the server is shutting
+			// down, either way the client can reconnect and resume
+			if !ok {
+				return
+			}
+			err = writeEvent(w, event.ID, string(event.Type), event)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+
+		if err == nil {
+			err = http.NewResponseController(w).Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openEventStream opens GET /books/events, sending the Last-Event-ID if it's
+// given, and returns the stream's lines as they're read
+func openEventStream(t *testing.T, lastID string) (*http.Response, <-chan string) {
+	request, _ := http.NewRequest("GET", server.URL+"/books/events", nil)
+	if lastID != "" {
+		request.Header.Set("Last-Event-ID", lastID)
+	}
+
+	res, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/events: %v", err)
+		t.FailNow()
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	return res, lines
+}
+
+// nextEvent returns the id and type of the next event on the stream
+func nextEvent(t *testing.T, lines <-chan string) (string, string) {
+	var id, event string
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Errorf("The event stream ended while waiting for an event")
+				t.FailNow()
+			}
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case line == "" && event != "":
+				return id, event
+			}
+		case <-timeout:
+			t.Errorf("Timed out waiting for an event")
+			t.FailNow()
+		}
+	}
+}
+
+func TestGetBookEvents(t *testing.T) {
+	defer cleanLibrary()
+
+	res, lines := openEventStream(t, "")
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != eventStreamType {
+		t.Errorf("Expected a 200 event stream from GET /books/events, got %v %v", res.Status, res.Header.Get("Content-Type"))
+		t.FailNow()
+	}
+
+	sendRequest("/books", "POST", `{"title": "MyEventBook", "rating": 1}`)
+	createdID, event := nextEvent(t, lines)
+	if event != "created" {
+		t.Errorf("Expected a created event, got %v", event)
+	}
+	res.Body.Close()
+
+	// reconnecting from the event before the book was created sends the
+	// created event again
+	created, _ := strconv.ParseUint(createdID, 10, 64)
+	res, lines = openEventStream(t, strconv.FormatUint(created-1, 10))
+	defer res.Body.Close()
+
+	id, event := nextEvent(t, lines)
+	if id != createdID || event != "created" {
+		t.Errorf("Expected to resume with event %v, got %v %v", createdID, id, event)
+	}
+}
+
+func TestGetBookEventsReset(t *testing.T) {
+	// ids from the future, and from before the server restarted, can't
+	// be resumed from
+	for _, lastID := range []string{"18446744073709551615", "2"} {
+		res, lines := openEventStream(t, lastID)
+
+		_, event := nextEvent(t, lines)
+		if event != resetEvent {
+			t.Errorf("Expected a reset event for the unknown Last-Event-ID %v, got %v", lastID, event)
+		}
+		res.Body.Close()
+	}
+
+	res, _ := openEventStream(t, "yesterday")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 for an invalid Last-Event-ID, got %v", res.Status)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetLedger is the handler for the GET /patrons/{id}/ledger api call,
+// it returns every charge and credit for the patron along with what they owe
+func GetLedger(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	patronID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	ledger, err := managers.GetCirculation().GetLedger(patronID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, ledger, http.StatusOK)
+}
+
+// PostPayment is the handler for the POST /patrons/{id}/payments api call,
+// it credits a payment to the patron's ledger
+func PostPayment(w http.ResponseWriter, r *http.Request) {
+	postCredit(w, r, model.Payment)
+}
+
+// PostWaiver is the handler for the POST /patrons/{id}/waivers api call,
+// it credits a waiver to the patron's ledger
+func PostWaiver(w http.ResponseWriter, r *http.Request) {
+	postCredit(w, r, model.Waiver)
+}
+
+// creditRequest is the body of a payment or waiver, the amount is in cents
+type creditRequest struct {
+	Amount int64  `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// postCredit reads the amount and note out of the body and credits them to
+// the patron's ledger as either a payment or a waiver
+func postCredit(w http.ResponseWriter, r *http.Request, entryType model.LedgerEntryType) {
+	parameters := mux.Vars(r)
+	patronID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	var body creditRequest
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The Post Body was invalid")
+		return
+	}
+	defer r.Body.Close()
+
+	circulation := managers.GetCirculation()
+
+	var entry model.LedgerEntry
+	if entryType == model.Waiver {
+		entry, err = circulation.Waive(patronID, body.Amount, body.Note)
+	} else {
+		entry, err = circulation.Pay(patronID, body.Amount, body.Note)
+	}
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, entry, http.StatusCreated)
+}
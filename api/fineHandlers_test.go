@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestLostBookAndPayment(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/lost", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/lost: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from POST /books/{id}/lost, got %v", res.Status)
+	}
+
+	res, err = sendRequest("/patrons/"+patron.ID.String()+"/payments", "POST", `{"amount": 500, "note": "cash"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /patrons/{id}/payments: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 201 {
+		t.Errorf("Expected status 201 from POST /patrons/{id}/payments, got %v", res.Status)
+	}
+
+	res, err = sendRequest("/patrons/"+patron.ID.String()+"/ledger", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /patrons/{id}/ledger: %v", err)
+		t.FailNow()
+	}
+
+	var ledger map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&ledger)
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("Error getting GET /patrons/{id}/ledger: %v %v", res.Status, err)
+		t.FailNow()
+	}
+
+	if ledger["balance"] != float64(managers.DefaultLostItemCharge-500) || len(ledger["entries"].([]interface{})) != 2 {
+		t.Errorf("Expected the lost item charge less the payment, got %v", ledger)
+	}
+}
+
+func TestPostWaiverOverBalance(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, _ := addTestPatronAndBook()
+
+	res, err := sendRequest("/patrons/"+patron.ID.String()+"/waivers", "POST", `{"amount": 500}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /patrons/{id}/waivers: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 waiving more than the balance, got %v", res.Status)
+	}
+}
+
+func TestGetLedgerBadPatron(t *testing.T) {
+	defer cleanLibrary()
+
+	id, _ := uuid.NewV4()
+	res, err := sendRequest("/patrons/"+id.String()+"/ledger", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /patrons/{id}/ledger: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status 404 from GET /patrons/{id}/ledger with a bad id, got %v", res.Status)
+	}
+}
+
+func TestFoundBook(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+	managers.GetCirculation().ReportLost(book.ID)
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/found?credit=true", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/found: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from POST /books/{id}/found, got %v", res.Status)
+	}
+
+	ledger, _ := managers.GetCirculation().GetLedger(patron.ID)
+	if ledger.Balance != 0 {
+		t.Errorf("Expected the lost item charge to be refunded, got %+v", ledger)
+	}
+
+	res, err = sendRequest("/books/"+book.ID.String()+"/found", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/found: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 finding a book that isn't lost, got %v", res.Status)
+	}
+}
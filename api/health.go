@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+// ServerState is what the server is doing, which decides whether it's ready
+// to be sent traffic
+type ServerState int32
+
+// this const block holds the ServerState enum values, the server is Serving
+// unless it's told otherwise, so the api can be used without ever setting
+// the state
+const (
+	Serving ServerState = iota
+	Starting
+	ShuttingDown
+)
+
+var (
+	// ErrStarting is returned while the server is still loading its books
+	ErrStarting = errors.New("The server is still starting up")
+
+	// ErrShuttingDown is returned by GET /readyz once the server has started
+	// shutting down
+	ErrShuttingDown = errors.New("The server is shutting down")
+)
+
+// serverState holds the ServerState
+var serverState int32
+
+// SetServerState changes what the server is doing. While it's Starting only
+// the Probe routes are served, and every other route responds with a 503.
+// Once it's ShuttingDown every route is still served, so requests that were
+// already on their way are answered, but GET /readyz fails so no new ones
+// are sent
+func SetServerState(state ServerState) {
+	atomic.StoreInt32(&serverState, int32(state))
+}
+
+// GetServerState returns what the server is doing
+func GetServerState() ServerState {
+	return ServerState(atomic.LoadInt32(&serverState))
+}
+
+// health is the body of the probe responses that succeed
+type health struct {
+	Status string `json:"status"`
+}
+
+// waitForStartup responds with a 503 until the server has finished starting
+func waitForStartup(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetServerState() == Starting {
+			w.Header().Set("Retry-After", "1")
+			writeJSONFail(w, http.StatusServiceUnavailable, ErrStarting.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetHealthz is the handler for the GET /healthz api call,
+// it's the liveness probe and always succeeds as long as the server can
+// answer requests at all
+func GetHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONSuccess(w, health{Status: "ok"}, http.StatusOK)
+}
+
+// GetReadyz is the handler for the GET /readyz api call,
+// it's the readiness probe and only succeeds once the books have been
+// loaded, while the server isn't shutting down and while the book store is
+// available
+func GetReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch GetServerState() {
+	case Starting:
+		writeJSONFail(w, http.StatusServiceUnavailable, ErrStarting.Error())
+		return
+	case ShuttingDown:
+		writeJSONFail(w, http.StatusServiceUnavailable, ErrShuttingDown.Error())
+		return
+	}
+
+	err := managers.PingLibrary()
+	if err != nil {
+		requestLog(r).Warnf("The book store is unavailable: %v", err)
+		writeJSONFail(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSONSuccess(w, health{Status: "ready"}, http.StatusOK)
+}
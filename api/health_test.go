@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+// unavailableStore is a book store that's always unavailable
+type unavailableStore struct {
+	*managers.Library
+}
+
+func (unavailableStore) Ping() error {
+	return errors.New("MyUnavailableStore")
+}
+
+// probeStatus returns the status of a GET request to the route
+func probeStatus(t *testing.T, url string) int {
+	res, err := sendRequest(url, "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET %v: %v", url, err)
+		t.FailNow()
+	}
+	res.Body.Close()
+
+	return res.StatusCode
+}
+
+func TestProbes(t *testing.T) {
+	defer SetServerState(Serving)
+
+	tests := []struct {
+		state   ServerState
+		healthz int
+		readyz  int
+		books   int
+	}{
+		{Serving, 200, 200, 200},
+		{Starting, 200, 503, 503},
+		{ShuttingDown, 200, 503, 200},
+	}
+
+	for _, test := range tests {
+		SetServerState(test.state)
+
+		if status := probeStatus(t, "/healthz"); status != test.healthz {
+			t.Errorf("Expected GET /healthz to be %v in state %v, got %v", test.healthz, test.state, status)
+		}
+		if status := probeStatus(t, "/readyz"); status != test.readyz {
+			t.Errorf("Expected GET /readyz to be %v in state %v, got %v", test.readyz, test.state, status)
+		}
+		if status := probeStatus(t, "/books"); status != test.books {
+			t.Errorf("Expected GET /books to be %v in state %v, got %v", test.books, test.state, status)
+		}
+	}
+}
+
+func TestReadyzUnavailableStore(t *testing.T) {
+	defer cleanLibrary()
+	managers.SetLibrary(unavailableStore{managers.NewLibrary()})
+
+	if status := probeStatus(t, "/readyz"); status != 503 {
+		t.Errorf("Expected GET /readyz to be 503 while the book store is unavailable, got %v", status)
+	}
+}
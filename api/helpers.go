@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
 )
 
 func writeJSONSuccess(w http.ResponseWriter, i interface{}, status int) error {
@@ -24,14 +27,92 @@ func writeJSONSuccess(w http.ResponseWriter, i interface{}, status int) error {
 	return nil
 }
 
+// apiError is the body of every response for a request that failed
+type apiError struct {
+	Error string `json:"error"`
+}
+
 func writeJSONFail(w http.ResponseWriter, code int, s string) {
 	w.WriteHeader(code)
-	data := struct {
-		Error string `json:"error"`
-	}{
+	data := apiError{
 		s,
 	}
 
 	b, _ := json.Marshal(data)
 	w.Write([]byte(b))
 }
+
+// errorStatuses maps the errors returned by the managers to the status code
+// they're written with, any other error is the backend failing
+var errorStatuses = map[error]int{
+	managers.ErrNoBookWithThatID:   http.StatusNotFound,
+	managers.ErrNoPatronWithThatID: http.StatusNotFound,
+	managers.ErrBookCheckedOut:     http.StatusConflict,
+	managers.ErrBookNotCheckedOut:  http.StatusConflict,
+	managers.ErrNoHoldWithThatID:   http.StatusNotFound,
+	managers.ErrBookAvailable:      http.StatusConflict,
+	managers.ErrAlreadyOnHold:      http.StatusConflict,
+	managers.ErrPatronHasBook:      http.StatusConflict,
+	managers.ErrBookReserved:       http.StatusConflict,
+	managers.ErrBookHasHolds:       http.StatusConflict,
+
+	managers.ErrMaxRenewals:           http.StatusConflict,
+	managers.ErrRenewalBlockedByHolds: http.StatusConflict,
+
+	managers.ErrInvalidAmount:     http.StatusBadRequest,
+	managers.ErrAmountOverBalance: http.StatusBadRequest,
+	managers.ErrPatronBlocked:     http.StatusConflict,
+	managers.ErrBookLost:          http.StatusConflict,
+	managers.ErrBookNotLost:       http.StatusConflict,
+
+	managers.ErrBatchTooLarge:    http.StatusRequestEntityTooLarge,
+	managers.ErrUnknownBatchOp:   http.StatusBadRequest,
+	managers.ErrBookExists:       http.StatusConflict,
+	managers.ErrRevisionMismatch: http.StatusPreconditionFailed,
+	managers.ErrBatchAborted:     http.StatusFailedDependency,
+
+	managers.ErrEmptyCSV:      http.StatusBadRequest,
+	managers.ErrUnknownColumn: http.StatusBadRequest,
+	managers.ErrInvalidMARC:   http.StatusBadRequest,
+
+	managers.ErrNoWebhookWithThatID:  http.StatusNotFound,
+	managers.ErrNoDeliveryWithThatID: http.StatusNotFound,
+	managers.ErrDeliveryNotDead:      http.StatusConflict,
+	managers.ErrInvalidWebhookURL:    http.StatusBadRequest,
+	managers.ErrInvalidWebhookEvent:  http.StatusBadRequest,
+
+	model.ErrInvalidRating: http.StatusBadRequest,
+	model.ErrInvalidStatus: http.StatusBadRequest,
+	model.ErrInvalidISBN:   http.StatusBadRequest,
+	model.ErrInvalidText:   http.StatusBadRequest,
+
+	ErrPatchTestFailed:    http.StatusConflict,
+	ErrPreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// badRequestError wraps an error that was caused by what the client sent,
+// for when the error has to make its way back out through the managers
+type badRequestError struct {
+	error
+}
+
+// managerStatus returns the status code for an error returned from one of
+// the managers, from errorStatuses
+func managerStatus(err error) int {
+	if _, ok := err.(badRequestError); ok {
+		return http.StatusBadRequest
+	}
+
+	status, found := errorStatuses[err]
+	if !found {
+		return http.StatusInternalServerError
+	}
+
+	return status
+}
+
+// writeManagerFail writes an error returned from one of the managers with
+// the status code from errorStatuses
+func writeManagerFail(w http.ResponseWriter, err error) {
+	writeJSONFail(w, managerStatus(err), err.Error())
+}
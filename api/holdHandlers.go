@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidHoldUUID is the error returned whenever a user gives a hold id
+	// that isn't a valid UUID
+	ErrInvalidHoldUUID = errors.New("The given hold id was not a valid UUID")
+)
+
+// parseHoldIDs reads the book id and the hold id out of the url
+func parseHoldIDs(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		return bookID, bookID, ErrInvalidUUID
+	}
+
+	holdID, err := uuid.FromString(parameters["holdID"])
+	if err != nil {
+		return bookID, holdID, ErrInvalidHoldUUID
+	}
+
+	return bookID, holdID, nil
+}
+
+// PostHold is the handler for the POST /books/{id}/holds api call,
+// it puts the patron given in the body at the end of the book's holds queue
+func PostHold(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	var body patronRequest
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The Post Body was invalid")
+		return
+	}
+	defer r.Body.Close()
+
+	hold, err := managers.GetCirculation().PlaceHold(bookID, body.PatronID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, hold, http.StatusCreated)
+}
+
+// GetHolds is the handler for the GET /books/{id}/holds api call,
+// it returns the book's holds queue in order
+func GetHolds(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	if _, err = managers.GetLibrary().GetBookByID(bookID); err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	holds, err := managers.GetCirculation().GetHolds(bookID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	err = writeJSONSuccess(w, holds, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetHold is the handler for the GET /books/{id}/holds/{holdID} api call,
+// it returns a single hold along with its position in the queue
+func GetHold(w http.ResponseWriter, r *http.Request) {
+	bookID, holdID, err := parseHoldIDs(r)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hold, err := managers.GetCirculation().GetHold(bookID, holdID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, hold, http.StatusOK)
+}
+
+// DeleteHold is the handler for the DELETE /books/{id}/holds/{holdID} api
+// call, it takes the hold out of the book's queue
+func DeleteHold(w http.ResponseWriter, r *http.Request) {
+	bookID, holdID, err := parseHoldIDs(r)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = managers.GetCirculation().CancelHold(bookID, holdID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, "", http.StatusAccepted)
+}
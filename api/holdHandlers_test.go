@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestPostAndGetHold(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	waiting := model.NewPatron()
+	waiting.Name = "Waiting"
+	managers.GetCirculation().AddPatron(waiting)
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/holds", "POST", checkoutBody(waiting))
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/holds: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 201 {
+		t.Errorf("Expected status 201 from POST /books/{id}/holds, got %v", res.Status)
+	}
+
+	var hold map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&hold)
+	if err != nil {
+		t.Errorf("Error trying to read the body from POST /books/{id}/holds: %v", err)
+		t.FailNow()
+	}
+
+	res, err = sendRequest("/books/"+book.ID.String()+"/holds/"+hold["id"].(string), "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/{id}/holds/{holdID}: %v", err)
+		t.FailNow()
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&hold)
+	if err != nil || res.StatusCode != 200 || hold["position"] != 1.0 {
+		t.Errorf("Expected the hold at position 1 from GET /books/{id}/holds/{holdID}, got %v %v", res.Status, hold)
+	}
+
+	// the book can't be deleted while patrons are waiting for it
+	managers.GetCirculation().Return(book.ID)
+	res, err = sendRequest("/books/"+book.ID.String(), "DELETE", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for DELETE /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 deleting a book with holds, got %v", res.Status)
+	}
+
+	res, err = sendRequest("/books/"+book.ID.String()+"/holds/"+hold["id"].(string), "DELETE", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for DELETE /books/{id}/holds/{holdID}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 {
+		t.Errorf("Expected status 202 from DELETE /books/{id}/holds/{holdID}, got %v", res.Status)
+	}
+}
+
+func TestPostHoldAvailableBook(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/holds", "POST", checkoutBody(patron))
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/holds: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 placing a hold on an available book, got %v", res.Status)
+	}
+}
+
+func TestGetHoldBadHoldUUID(t *testing.T) {
+	defer cleanLibrary()
+
+	_, book := addTestPatronAndBook()
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/holds/4", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/{id}/holds/{holdID}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from GET /books/{id}/holds/{holdID} with a bad uuid, got %v", res.Status)
+	}
+}
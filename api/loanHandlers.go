@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// patronRequest is the body of the requests that act on a book for a patron,
+// checking it out to them or placing a hold for them
+type patronRequest struct {
+	PatronID uuid.UUID `json:"patron_id"`
+}
+
+// CheckoutBook is the handler for the POST /books/{id}/checkout api call,
+// it lends the book to the patron given in the body and returns the loan
+func CheckoutBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	var body patronRequest
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The Post Body was invalid")
+		return
+	}
+	defer r.Body.Close()
+
+	loan, err := managers.GetCirculation().Checkout(bookID, body.PatronID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, loan, http.StatusCreated)
+}
+
+// ReturnBook is the handler for the POST /books/{id}/return api call,
+// it ends the book's active loan and returns the finished loan
+func ReturnBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	loan, err := managers.GetCirculation().Return(bookID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, loan, http.StatusOK)
+}
+
+// RenewBook is the handler for the POST /books/{id}/renew api call,
+// it pushes back the due date of the book's active loan and returns the loan
+func RenewBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	loan, err := managers.GetCirculation().Renew(bookID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, loan, http.StatusOK)
+}
+
+// ReportLostBook is the handler for the POST /books/{id}/lost api call,
+// it ends the book's active loan because the patron lost it, charging them
+// for it, and returns the finished loan
+func ReportLostBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	loan, err := managers.GetCirculation().ReportLost(bookID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, loan, http.StatusOK)
+}
+
+// FoundBook is the handler for the POST /books/{id}/found api call, it
+// checks a lost book back in, refunding the lost item charge when the
+// credit query parameter is true, and returns the book
+func FoundBook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	credit, err := parseBool(r.URL.Query().Get("credit"))
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The credit must be true or false")
+		return
+	}
+
+	book, err := managers.GetCirculation().Found(bookID, credit)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, book, http.StatusOK)
+}
+
+// GetOverdueLoans is the handler for the GET /loans/overdue api call,
+// it returns every active loan that's past its due date
+func GetOverdueLoans(w http.ResponseWriter, r *http.Request) {
+	err := writeJSONSuccess(w, managers.GetCirculation().OverdueLoans(), http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetBookLoans is the handler for the GET /books/{id}/loans api call,
+// it returns every loan of the book, current and past, oldest first
+func GetBookLoans(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	bookID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	loans := managers.GetCirculation().BookLoans(bookID)
+
+	// books that have been deleted still have their history, so it's only
+	// a 404 if the book has never existed as far as we can tell
+	if len(loans) == 0 {
+		if _, err = managers.GetLibrary().GetBookByID(bookID); err != nil {
+			writeManagerFail(w, err)
+			return
+		}
+	}
+
+	err = writeJSONSuccess(w, loans, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetPatronLoans is the handler for the GET /patrons/{id}/loans api call,
+// it returns every loan to the patron, current and past, oldest first
+func GetPatronLoans(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	patronID, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	loans, err := managers.GetCirculation().PatronLoans(patronID)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	err = writeJSONSuccess(w, loans, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
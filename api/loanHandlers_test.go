@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// addTestPatronAndBook adds a patron and a book to the global managers
+func addTestPatronAndBook() (model.Patron, model.Book) {
+	patron := model.NewPatron()
+	patron.Name = "Me"
+	managers.GetCirculation().AddPatron(patron)
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	managers.GetLibrary().AddBook(book)
+
+	return patron, book
+}
+
+// checkoutBody returns the body for a POST /books/{id}/checkout request
+func checkoutBody(patron model.Patron) string {
+	return fmt.Sprintf(`{"patron_id": "%v"}`, patron.ID)
+}
+
+func TestCheckoutBook(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/checkout", "POST", checkoutBody(patron))
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/checkout: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 201 {
+		t.Errorf("Expected status 201 from POST /books/{id}/checkout, got %v", res.Status)
+	}
+
+	var loan map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&loan)
+	if err != nil {
+		t.Errorf("Error trying to read the body from POST /books/{id}/checkout: %v", err)
+		t.FailNow()
+	}
+
+	if loan["patron_id"] != patron.ID.String() || loan["due_at"] == nil {
+		t.Errorf("Didn't get the loan back from POST /books/{id}/checkout, got %v", loan)
+	}
+
+	checkedOut, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if checkedOut.Status != model.CheckedOut {
+		t.Errorf("POST /books/{id}/checkout didn't mark the book as CheckedOut")
+	}
+
+	// the book can't be checked out twice
+	res, err = sendRequest("/books/"+book.ID.String()+"/checkout", "POST", checkoutBody(patron))
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/checkout: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 checking out a checked out book, got %v", res.Status)
+	}
+
+	// and it can't be deleted while it's checked out
+	res, err = sendRequest("/books/"+book.ID.String(), "DELETE", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for DELETE /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 deleting a checked out book, got %v", res.Status)
+	}
+}
+
+func TestCheckoutBadPatron(t *testing.T) {
+	defer cleanLibrary()
+
+	_, book := addTestPatronAndBook()
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/checkout", "POST", checkoutBody(model.NewPatron()))
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/checkout: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status 404 checking out to a missing patron, got %v", res.Status)
+	}
+}
+
+func TestReturnBook(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/return", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/return: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from POST /books/{id}/return, got %v", res.Status)
+	}
+
+	returned, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if returned.Status != model.CheckedIn {
+		t.Errorf("POST /books/{id}/return didn't mark the book as CheckedIn")
+	}
+
+	// the book can't be returned twice
+	res, err = sendRequest("/books/"+book.ID.String()+"/return", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/return: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 returning a book that isn't checked out, got %v", res.Status)
+	}
+}
+
+func TestPutBookIgnoresStatus(t *testing.T) {
+	defer cleanLibrary()
+
+	_, book := addTestPatronAndBook()
+
+	res, err := sendRequest("/books/"+book.ID.String(), "PUT", `{"rating": 1, "status": 1}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for PUT /books/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 {
+		t.Errorf("Expected status 202 from PUT /books/{id}, got %v", res.Status)
+	}
+
+	modified, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if modified.Status != model.CheckedIn {
+		t.Errorf("PUT /books/{id} changed the status of the book by hand")
+	}
+}
+
+func TestRenewBook(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	res, err := sendRequest("/books/"+book.ID.String()+"/renew", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/renew: %v", err)
+		t.FailNow()
+	}
+
+	var loan map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&loan)
+	if err != nil || res.StatusCode != 200 || loan["renewals"] != 1.0 {
+		t.Errorf("Expected a loan renewed once from POST /books/{id}/renew, got %v %v", res.Status, loan)
+	}
+
+	// the book can't be renewed once someone is waiting for it
+	waiting := model.NewPatron()
+	waiting.Name = "Waiting"
+	managers.GetCirculation().AddPatron(waiting)
+	managers.GetCirculation().PlaceHold(book.ID, waiting.ID)
+
+	res, err = sendRequest("/books/"+book.ID.String()+"/renew", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/{id}/renew: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 renewing a book with holds, got %v", res.Status)
+	}
+}
+
+func TestGetOverdueLoans(t *testing.T) {
+	defer cleanLibrary()
+
+	clock := managers.NewFakeClock(time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC))
+	managers.GetCirculation().Clock = clock
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	clock.Advance(managers.DefaultLoanPeriod + time.Hour)
+
+	res, err := sendRequest("/loans/overdue", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /loans/overdue: %v", err)
+		t.FailNow()
+	}
+
+	var loans []map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&loans)
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("Error getting GET /loans/overdue: %v %v", res.Status, err)
+		t.FailNow()
+	}
+
+	if len(loans) != 1 || loans[0]["book_id"] != book.ID.String() {
+		t.Errorf("Expected the one overdue loan from GET /loans/overdue, got %v", loans)
+	}
+}
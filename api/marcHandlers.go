@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+// ExportBooksMARC is the handler for the GET /books/export.mrc api call,
+// it writes every book matching the same filters as GET /books as MARC21
+// records
+func ExportBooksMARC(w http.ResponseWriter, r *http.Request) {
+	exportBooks(w, r, "application/marc", "books.mrc", managers.WriteMARC)
+}
+
+// ExportBooksMARCXML is the handler for the GET /books/export.xml api call,
+// it writes every book matching the same filters as GET /books as a MARCXML
+// collection
+func ExportBooksMARCXML(w http.ResponseWriter, r *http.Request) {
+	exportBooks(w, r, "application/marcxml+xml; charset=utf-8", "books.xml", managers.WriteMARCXML)
+}
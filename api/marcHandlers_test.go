@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestExportAndImportMARC(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyMARCBook"
+	book.ISBN = "0-441-17271-7"
+	book.Rating = 2
+	managers.GetLibrary().AddBook(book)
+
+	exports := []struct {
+		url, contentType, importURL, importContentType string
+	}{
+		{"/books/export.mrc", "application/marc", "/books/import", "application/marc"},
+		{"/books/export.xml", "application/marcxml+xml; charset=utf-8", "/books/import?format=marcxml", "text/plain"},
+	}
+
+	for _, export := range exports {
+		res, err := sendRequest(export.url, "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET %v: %v", export.url, err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != 200 || res.Header.Get("Content-Type") != export.contentType {
+			t.Errorf("Expected %v from GET %v, got %v %v", export.contentType, export.url, res.Status, res.Header.Get("Content-Type"))
+		}
+
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		// importing the export back in updates the same book
+		status, report := decodeImportReport(t, export.importURL, export.importContentType, string(body))
+		if status != 200 || report.Updated != 1 || report.Created != 0 || report.Failed != 0 {
+			t.Errorf("Expected the export from %v to update the book, got %v %+v", export.url, status, report)
+		}
+	}
+
+	imported, _ := managers.GetLibrary().GetBookByID(book.ID)
+	if imported.Title != book.Title || imported.ISBN != book.ISBN || imported.Rating != book.Rating {
+		t.Errorf("Expected the book to be unchanged by importing it, got %+v", imported)
+	}
+}
+
+func TestImportMARCUpload(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "MyUnratedBook"
+
+	var marc bytes.Buffer
+	managers.WriteMARC(&marc, []model.Book{book})
+
+	// the format comes from the file name and the missing rating from
+	// default_rating
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "books.mrc")
+	part.Write(marc.Bytes())
+	writer.Close()
+
+	status, report := decodeImportReport(t, "/books/import?default_rating=1", writer.FormDataContentType(), form.String())
+	if status != 200 || report.Created != 1 || !report.Applied {
+		t.Errorf("Wrong report from POST /books/import, got %v %+v", status, report)
+	}
+
+	imported, err := managers.GetLibrary().GetBookByID(book.ID)
+	if err != nil || imported.Rating != 1 {
+		t.Errorf("Expected the book to be imported with a rating of 1, got %+v (%v)", imported, err)
+	}
+
+	for _, url := range []string{"/books/import?format=pdf", "/books/import?default_rating=4"} {
+		res, err := sendRequest(url, "POST", marc.String())
+		if err != nil {
+			t.Errorf("Got error when sending request for POST %v: %v", url, err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status 400 from POST %v, got %v", url, res.Status)
+		}
+	}
+
+	res, err := sendRequestWithHeaders("/books/import", "POST", "<collection>", map[string]string{"Content-Type": "application/xml"})
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /books/import: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /books/import with broken xml, got %v", res.Status)
+	}
+}
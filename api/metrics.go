@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes the name of every metric
+const metricsNamespace = "books_api"
+
+// unmatchedRoute is the route label of requests that didn't match any route
+const unmatchedRoute = "unmatched"
+
+var (
+	// requestsTotal counts the requests handled by each route
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "How many requests were handled, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// requestDuration is how long each route took to handle its requests
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "How long requests took to handle, by route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// metricsRegistry holds every metric served at /metrics, it's separate
+	// from the default registry so that only the api's metrics, and the
+	// go runtime and process metrics, are served
+	metricsRegistry = prometheus.NewRegistry()
+
+	// metricsHandler writes the metrics in metricsRegistry
+	metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+)
+
+// the metrics are registered in init, once the descriptions that
+// libraryCollector uses have been made
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		libraryCollector{},
+	)
+}
+
+// instrument returns a middleware that counts and times the requests to a
+// route, labeled with the route's pattern rather than the request's path so
+// that every book's requests are counted together
+func instrument(pattern string) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, r)
+
+			method := r.Method
+			if pattern == unmatchedRoute {
+				method = knownMethod(method)
+			}
+
+			code := strconv.Itoa(recorder.statusCode())
+			requestsTotal.WithLabelValues(pattern, method, code).Inc()
+			requestDuration.WithLabelValues(pattern, method, code).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// knownMethod returns the method if it's one of the standard HTTP methods,
+// and OTHER if it isn't, so requests that don't match a route can't create
+// a new series for every made up method
+func knownMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE":
+		return method
+	}
+
+	return "OTHER"
+}
+
+var (
+	booksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "books"),
+		"How many books are in the library.",
+		nil, nil,
+	)
+
+	booksByStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "books_by_status"),
+		"How many books in the library have each status.",
+		[]string{"status"}, nil,
+	)
+
+	activeLoansDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "active_loans"),
+		"How many books are checked out.",
+		nil, nil,
+	)
+
+	validationFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "book_validation_failures_total"),
+		"How many books failed validation, by the field that was invalid.",
+		[]string{"field"}, nil,
+	)
+)
+
+// libraryCollector reads the metrics about the library's books and loans
+// from the global book store and circulation each time they're scraped
+type libraryCollector struct{}
+
+// Describe sends the descriptions of every metric the collector collects
+func (libraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- booksDesc
+	ch <- booksByStatusDesc
+	ch <- activeLoansDesc
+	ch <- validationFailuresDesc
+}
+
+// Collect sends the current value of every metric
+func (libraryCollector) Collect(ch chan<- prometheus.Metric) {
+	books, err := managers.GetLibrary().GetBooks()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(booksDesc, err)
+	} else {
+		statuses := make(map[model.Status]int)
+		for _, book := range books {
+			statuses[book.Status]++
+		}
+
+		ch <- prometheus.MustNewConstMetric(booksDesc, prometheus.GaugeValue, float64(len(books)))
+		for _, status := range model.Statuses {
+			ch <- prometheus.MustNewConstMetric(booksByStatusDesc, prometheus.GaugeValue, float64(statuses[status]), status.String())
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(activeLoansDesc, prometheus.GaugeValue, float64(managers.GetCirculation().ActiveLoanCount()))
+
+	for field, count := range managers.ValidationFailures() {
+		ch <- prometheus.MustNewConstMetric(validationFailuresDesc, prometheus.CounterValue, float64(count), field)
+	}
+}
+
+// GetMetrics is the handler for the GET /metrics api call,
+// it returns the api's metrics in the Prometheus exposition format
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// getMetrics returns the body of GET /metrics
+func getMetrics(t *testing.T) string {
+	res, err := sendRequest("/metrics", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /metrics: %v", err)
+		t.FailNow()
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /metrics, got %v", res.Status)
+		t.FailNow()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	return string(body)
+}
+
+func TestRequestMetrics(t *testing.T) {
+	found := requestsTotal.WithLabelValues("/books/{id}", "GET", "404")
+	before := testutil.ToFloat64(found)
+
+	book := model.NewBook()
+	sendRequest("/books/"+book.ID.String(), "GET", "")
+	sendRequest("/no/such/route", "GET", "")
+
+	if testutil.ToFloat64(found) != before+1 {
+		t.Errorf("Expected GET /books/{id} to count a 404 under its route pattern")
+	}
+
+	metrics := getMetrics(t)
+	for _, line := range []string{
+		`books_api_http_requests_total{code="404",method="GET",route="/books/{id}"}`,
+		`books_api_http_requests_total{code="404",method="GET",route="unmatched"}`,
+		`books_api_http_request_duration_seconds_bucket{code="404",method="GET",route="/books/{id}",le="+Inf"}`,
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected GET /metrics to have %v", line)
+		}
+	}
+}
+
+func TestLibraryMetrics(t *testing.T) {
+	defer cleanLibrary()
+
+	for _, status := range []model.Status{model.CheckedIn, model.CheckedIn, model.Lost} {
+		book := model.NewBook()
+		book.Rating = 1
+		book.Status = status
+		managers.GetLibrary().AddBook(book)
+	}
+
+	before := managers.ValidationFailures()["rating"]
+	res, err := sendRequest("/books", "POST", `{"title": "MyUnratedBook"}`)
+	if err != nil || res.StatusCode != 400 {
+		t.Errorf("Expected a book without a rating to be rejected, got %v %v", res.Status, err)
+		t.FailNow()
+	}
+
+	metrics := getMetrics(t)
+	for _, line := range []string{
+		"books_api_books 3\n",
+		`books_api_books_by_status{status="CheckedIn"} 2` + "\n",
+		`books_api_books_by_status{status="CheckedOut"} 0` + "\n",
+		`books_api_books_by_status{status="Lost"} 1` + "\n",
+		"books_api_active_loans 0\n",
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected GET /metrics to have %q", line)
+		}
+	}
+
+	if managers.ValidationFailures()["rating"] != before+1 {
+		t.Errorf("Expected the invalid rating to be counted")
+	}
+	for _, field := range []string{"rating", "status", "isbn", "text", "other"} {
+		if !strings.Contains(metrics, `books_api_book_validation_failures_total{field="`+field+`"}`) {
+			t.Errorf("Expected GET /metrics to have the %v validation failures", field)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// requestIDHeader is the header the request id is read from, if the client
+// or a proxy in front of the api already gave the request one, and is
+// written to on the response
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request id that's taken from a request,
+// a longer one is replaced so it can't flood the logs
+const maxRequestIDLength = 128
+
+// middleware wraps a handler to do something for every request it handles
+type middleware func(http.Handler) http.Handler
+
+// defaultMiddleware wraps every route, the first is the outermost, so the
+// request id is set before anything is logged and a panic is recovered from
+// before the access log records the response
+var defaultMiddleware = []middleware{withRequestID, logAccess, recoverPanics, timeRequest}
+
+// chain wraps a handler in the middlewares, the first middleware is the
+// outermost and so sees the request first
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// contextKey is the type of the keys the middleware stores values in the
+// request context with, so they can't collide with another package's keys
+type contextKey string
+
+// requestIDKey is the context key of the request id
+const requestIDKey contextKey = "request_id"
+
+// RequestID returns the id of the request a context belongs to, or "" if
+// the request didn't go through withRequestID
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestLog returns a logger that adds the request's id to everything it
+// logs
+func requestLog(r *http.Request) *log.Entry {
+	return log.WithField("request_id", RequestID(r.Context()))
+}
+
+// withRequestID gives each request an id, the one in its X-Request-ID header
+// if it has one, and otherwise a new uuid. The id is put in the request
+// context and sent back in the response's X-Request-ID header
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			newID, _ := uuid.NewV4()
+			id = newID.String()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// logAccess logs every request once it's been handled, with its status,
+// size and how long it took
+func logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		requestLog(r).WithFields(log.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"query":       r.URL.RawQuery,
+			"status":      recorder.statusCode(),
+			"bytes":       recorder.bytes,
+			"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+		}).Info("Handled request")
+	})
+}
+
+// recoverPanics turns a panic in a handler into a 500 with a json error, as
+// long as the handler hadn't already started its response, and logs the
+// panic along with its stack
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &responseRecorder{ResponseWriter: w}
+
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// ErrAbortHandler is how a handler asks for the response to be
+			// cut off, the server handles it without logging a stack
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			requestLog(r).WithField("stack", string(debug.Stack())).Errorf("Panic handling %v %v: %v", r.Method, r.URL.Path, err)
+
+			if recorder.status == 0 {
+				writeJSONFail(recorder, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// timeRequest adds how long the handler took to the response, in the
+// Server-Timing header that browsers show in their developer tools, and in
+// X-Response-Time. The time is taken when the handler writes its header,
+// since the headers can't be changed after that
+func timeRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		recorder.beforeHeader = func() {
+			elapsed := time.Since(start)
+			w.Header().Set("Server-Timing", fmt.Sprintf("app;dur=%.3f", float64(elapsed)/float64(time.Millisecond)))
+			w.Header().Set("X-Response-Time", elapsed.String())
+		}
+
+		next.ServeHTTP(recorder, r)
+
+		// a handler that doesn't write anything still sends a 200, which
+		// has to be timed too
+		if recorder.status == 0 {
+			recorder.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// limitBody returns a middleware that cuts off request bodies after the
+// given number of bytes, so a route that reads the whole body can't be made
+// to read forever
+func limitBody(size int64) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, size)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// noStore stops the response from being kept by browsers and proxies, for
+// the routes that respond with secrets
+func noStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder wraps a ResponseWriter to record the status and size of
+// the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+
+	// beforeHeader, if it's set, is called just before the header is
+	// written
+	beforeHeader func()
+}
+
+// WriteHeader records the status before writing it
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+
+	if r.beforeHeader != nil {
+		r.beforeHeader()
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records how much of the body has been written, writing a 200 header
+// first if the handler didn't write one
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush sends whatever's been written so far to the client, if the
+// underlying ResponseWriter can
+func (r *responseRecorder) Flush() {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode returns the status that was sent, a handler that didn't write
+// anything at all sends a 200
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
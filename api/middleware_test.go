@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+
+	// named returns a middleware that records when it's called
+	named := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}), named("first"), named("second"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if strings.Join(calls, ",") != "first,second,handler" {
+		t.Errorf("Expected the middleware to be called outermost first, got %v", calls)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}), defaultMiddleware...)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	id := w.Header().Get(requestIDHeader)
+	if id == "" || id != seen {
+		t.Errorf("Expected the handler to see the request id %q that was sent back, got %q", id, seen)
+	}
+
+	// an id from the client is kept
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(requestIDHeader, "my-request")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Header().Get(requestIDHeader) != "my-request" || seen != "my-request" {
+		t.Errorf("Expected the request id my-request to be kept, got %q", seen)
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Server-Timing"), "app;dur=") || w.Header().Get("X-Response-Time") == "" {
+		t.Errorf("Expected the response to be timed, got %v", w.Header())
+	}
+
+	// every route goes through the middleware
+	res, err := sendRequest("/books", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books: %v", err)
+		t.FailNow()
+	}
+
+	if res.Header.Get(requestIDHeader) == "" {
+		t.Errorf("Expected GET /books to have a request id")
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("MyPanic")
+	}), defaultMiddleware...)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get(requestIDHeader) == "" {
+		t.Errorf("Expected a 500 with a request id from a handler that panics, got %v %v", w.Code, w.Header())
+	}
+
+	var body apiError
+	err := json.NewDecoder(w.Body).Decode(&body)
+	if err != nil || body.Error == "" {
+		t.Errorf("Expected a json error from a handler that panics, got %q (%v)", w.Body.String(), err)
+	}
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	res, err := sendRequest("/admin/config", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /admin/config: %v", err)
+		t.FailNow()
+	}
+
+	if res.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected the route's noStore middleware to run, got Cache-Control %q", res.Header.Get("Cache-Control"))
+	}
+
+	res, err = sendRequest("/books", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books: %v", err)
+		t.FailNow()
+	}
+
+	if res.Header.Get("Cache-Control") != "" {
+		t.Errorf("Expected routes without the middleware not to run it, got Cache-Control %q", res.Header.Get("Cache-Control"))
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	var readErr error
+	handler := limitBody(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = ioutil.ReadAll(r.Body)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("too long")))
+
+	if readErr == nil {
+		t.Errorf("Expected reading a body over the limit to fail")
+	}
+}
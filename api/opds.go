@@ -0,0 +1,279 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// the media types of OPDS feeds and entries, and the OpenSearch description
+const (
+	opdsNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	opdsEntryType       = "application/atom+xml;type=entry;profile=opds-catalog"
+	openSearchType      = "application/opensearchdescription+xml"
+)
+
+// opdsBorrowRel is the link relation for borrowing a book, there aren't any
+// files to download so checking a book out is the only way to acquire it
+const opdsBorrowRel = "http://opds-spec.org/acquisition/borrow"
+
+// opdsPageSize is the most entries there are in each page of a feed
+const opdsPageSize = 25
+
+// maxPage is the last page that can be asked for, any later page would start
+// past the most entries an int can count
+const maxPage = math.MaxInt/opdsPageSize + 1
+
+// opdsFeed is an OPDS catalog feed, either a navigation feed whose entries
+// link to other feeds, or an acquisition feed whose entries are books
+type opdsFeed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  opdsAuthor `xml:"author"`
+	Links   []opdsLink `xml:"link"`
+
+	TotalResults *int `xml:"http://a9.com/-/spec/opensearch/1.1/ totalResults,omitempty"`
+	ItemsPerPage *int `xml:"http://a9.com/-/spec/opensearch/1.1/ itemsPerPage,omitempty"`
+	StartIndex   *int `xml:"http://a9.com/-/spec/opensearch/1.1/ startIndex,omitempty"`
+
+	Entries []opdsEntry `xml:"entry"`
+}
+
+// opdsEntry is a book in an acquisition feed, or a link to another feed in a
+// navigation feed
+type opdsEntry struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom entry"`
+
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []opdsAuthor `xml:"author"`
+	Identifier string       `xml:"http://purl.org/dc/terms/ identifier,omitempty"`
+	Publisher  string       `xml:"http://purl.org/dc/terms/ publisher,omitempty"`
+	Issued     string       `xml:"http://purl.org/dc/terms/ issued,omitempty"`
+	Content    *opdsContent `xml:"content"`
+	Links      []opdsLink   `xml:"link"`
+}
+
+// opdsAuthor is the author of a feed or a book
+type opdsAuthor struct {
+	Name string `xml:"name"`
+}
+
+// opdsContent is the text describing an entry
+type opdsContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// opdsLink links a feed or entry to another feed, entry or resource
+type opdsLink struct {
+	Rel   string `xml:"rel,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// openSearchDescription tells e-readers how to search the catalog
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	URLs          []openSearchURL `xml:"Url"`
+}
+
+// openSearchURL is the template of a search url, {searchTerms} is replaced
+// with what's being searched for
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// newOPDSFeed returns a feed with the links every feed in the catalog has,
+// to itself, to the start of the catalog, and to search. Books aren't
+// timestamped so every feed is as up to date as the time it was made
+func newOPDSFeed(r *http.Request, id, title, kind, updated string) opdsFeed {
+	return opdsFeed{
+		ID:      id,
+		Title:   title,
+		Updated: updated,
+		Author:  opdsAuthor{Name: "books-api"},
+		Links: []opdsLink{
+			{Rel: "self", Type: kind, Href: r.URL.RequestURI()},
+			{Rel: "start", Type: opdsNavigationType, Href: "/opds"},
+			{Rel: "search", Type: openSearchType, Href: "/opds/opensearch.xml"},
+			{Rel: "search", Type: opdsAcquisitionType, Href: "/opds/search?q={searchTerms}"},
+		},
+	}
+}
+
+// paginate adds the links to the first, previous, next and last pages of the
+// feed, and the OpenSearch counts of the results, given the page the feed is
+// and the total number of entries across every page
+func (f *opdsFeed) paginate(r *http.Request, page, total int, kind string) {
+	itemsPerPage := opdsPageSize
+	startIndex := (page-1)*opdsPageSize + 1
+	f.TotalResults = &total
+	f.ItemsPerPage = &itemsPerPage
+	f.StartIndex = &startIndex
+
+	last := (total + opdsPageSize - 1) / opdsPageSize
+	if last < 1 {
+		last = 1
+	}
+
+	// pageLink returns a link to the request with its page changed
+	pageLink := func(rel string, page int) opdsLink {
+		values := r.URL.Query()
+		values.Set("page", strconv.Itoa(page))
+		u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		return opdsLink{Rel: rel, Type: kind, Href: u.String()}
+	}
+
+	f.Links = append(f.Links, pageLink("first", 1))
+	if page > 1 {
+		f.Links = append(f.Links, pageLink("previous", page-1))
+	}
+	if page < last {
+		f.Links = append(f.Links, pageLink("next", page+1))
+	}
+	f.Links = append(f.Links, pageLink("last", last))
+}
+
+// parsePage reads the page query parameter of a feed, pages start at 1
+func parsePage(values url.Values) (int, error) {
+	s := values.Get("page")
+	if s == "" {
+		return 1, nil
+	}
+
+	// the page is multiplied by the page size to find its first entry, so
+	// bigger pages than maxPage would overflow
+	page, err := strconv.Atoi(s)
+	if err != nil || page < 1 || page > maxPage {
+		return 0, fmt.Errorf("The page must be a positive integer no larger than %v", maxPage)
+	}
+
+	return page, nil
+}
+
+// bookEntry returns the acquisition feed entry for a book
+func bookEntry(book model.Book, updated string) opdsEntry {
+	id := book.ID.String()
+
+	entry := opdsEntry{
+		ID:        "urn:uuid:" + id,
+		Title:     book.Title,
+		Updated:   updated,
+		Publisher: book.Publisher,
+		Content:   &opdsContent{Type: "text", Value: bookSummary(book)},
+		Links: []opdsLink{
+			{Rel: "alternate", Type: opdsEntryType, Href: "/opds/books/" + id},
+			{Rel: "alternate", Type: "application/json", Href: "/books/" + id},
+			{Rel: opdsBorrowRel, Type: "application/json", Href: "/books/" + id + "/checkout"},
+		},
+	}
+
+	if book.Author != "" {
+		entry.Authors = []opdsAuthor{{Name: book.Author}}
+	}
+
+	if book.ISBN != "" {
+		entry.Identifier = "urn:isbn:" + strings.NewReplacer("-", "", " ", "").Replace(book.ISBN)
+	}
+
+	if book.PublishDate != nil {
+		entry.Issued = book.PublishDate.UTC().Format("2006-01-02")
+	}
+
+	return entry
+}
+
+// bookSummary is the text shown for a book in a feed, since there's no
+// description it's the book's rating and whether it can be borrowed
+func bookSummary(book model.Book) string {
+	summary := "Status: " + book.Status.String()
+	if book.Rating != 0 {
+		summary = fmt.Sprintf("Rating: %v of 3, %v", book.Rating, summary)
+	}
+
+	return summary
+}
+
+// opdsGroup is a value shared by some of the books, like an author
+type opdsGroup struct {
+	Name  string
+	Count int
+}
+
+// groupBooks returns each value of a field of the books, ignoring case just
+// like GET /books?author= does, and how many books have it, sorted by the
+// value. Books without a value aren't in any group
+func groupBooks(books []model.Book, field func(model.Book) string) []opdsGroup {
+	var groups []opdsGroup
+	index := make(map[string]int)
+
+	for _, book := range books {
+		name := strings.TrimSpace(field(book))
+		if name == "" {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		i, found := index[key]
+		if !found {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, opdsGroup{Name: name})
+		}
+		groups[i].Count++
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+	})
+
+	return groups
+}
+
+// pageBounds returns the start and end of a page in a list of total entries
+func pageBounds(page, total int) (int, int) {
+	start := (page - 1) * opdsPageSize
+	if start > total {
+		start = total
+	}
+
+	end := start + opdsPageSize
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+// writeOPDS writes a feed, entry or OpenSearch description as xml
+func writeOPDS(w http.ResponseWriter, contentType string, v interface{}) error {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal to xml: %v", err)
+	}
+
+	w.Header().Set("Content-Type", contentType+";charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+
+	return nil
+}
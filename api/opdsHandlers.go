@@ -0,0 +1,260 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetOPDSCatalog is the handler for the GET /opds api call,
+// it returns the navigation feed at the start of the OPDS catalog, which
+// links to every book, the books by author and the books by publisher
+func GetOPDSCatalog(w http.ResponseWriter, r *http.Request) {
+	updated := time.Now().UTC().Format(time.RFC3339)
+	feed := newOPDSFeed(r, "urn:books-api:opds", "Books", opdsNavigationType, updated)
+
+	sections := []struct {
+		id, title, content, kind, href string
+	}{
+		{"books", "All Books", "Every book in the library, by title", opdsAcquisitionType, "/opds/books"},
+		{"authors", "By Author", "The books in the library grouped by author", opdsNavigationType, "/opds/authors"},
+		{"publishers", "By Publisher", "The books in the library grouped by publisher", opdsNavigationType, "/opds/publishers"},
+	}
+
+	for _, section := range sections {
+		feed.Entries = append(feed.Entries, opdsEntry{
+			ID:      "urn:books-api:opds:" + section.id,
+			Title:   section.title,
+			Updated: updated,
+			Content: &opdsContent{Type: "text", Value: section.content},
+			Links:   []opdsLink{{Rel: "subsection", Type: section.kind, Href: section.href}},
+		})
+	}
+
+	err := writeOPDS(w, opdsNavigationType, feed)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetOPDSBooks is the handler for the GET /opds/books api call,
+// it returns a page of the acquisition feed of books sorted by title,
+// optionally only the ones by the author or publisher query parameter
+func GetOPDSBooks(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	page, err := parsePage(values)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := managers.GetLibrary().GetBooks()
+	if err != nil {
+		writeJSONFail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := managers.BookQuery{
+		Author:    values.Get("author"),
+		Publisher: values.Get("publisher"),
+		Limit:     opdsPageSize,
+		Offset:    (page - 1) * opdsPageSize,
+	}
+
+	books, total, err := managers.QueryBooks(books, query)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, title := "urn:books-api:opds:books", "All Books"
+	switch {
+	case query.Author != "":
+		id, title = "urn:books-api:opds:authors:"+url.QueryEscape(query.Author), "Books by "+query.Author
+	case query.Publisher != "":
+		id, title = "urn:books-api:opds:publishers:"+url.QueryEscape(query.Publisher), "Books published by "+query.Publisher
+	}
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+	feed := newOPDSFeed(r, id, title, opdsAcquisitionType, updated)
+	feed.Links = append(feed.Links, opdsLink{Rel: "up", Type: opdsNavigationType, Href: "/opds"})
+	feed.paginate(r, page, total, opdsAcquisitionType)
+
+	for _, book := range books {
+		feed.Entries = append(feed.Entries, bookEntry(book, updated))
+	}
+
+	err = writeOPDS(w, opdsAcquisitionType, feed)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetOPDSBook is the handler for the GET /opds/books/{id} api call,
+// it returns the complete OPDS entry for a single book
+func GetOPDSBook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := uuid.FromString(vars["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	book, err := managers.GetLibrary().GetBookByID(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	entry := bookEntry(book, time.Now().UTC().Format(time.RFC3339))
+	err = writeOPDS(w, opdsEntryType, entry)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetOPDSAuthors is the handler for the GET /opds/authors api call,
+// it returns a page of the navigation feed of authors, each linking to the
+// acquisition feed of their books
+func GetOPDSAuthors(w http.ResponseWriter, r *http.Request) {
+	writeOPDSGroups(w, r, "authors", "Authors", "author", func(book model.Book) string {
+		return book.Author
+	})
+}
+
+// GetOPDSPublishers is the handler for the GET /opds/publishers api call,
+// it returns a page of the navigation feed of publishers, each linking to
+// the acquisition feed of the books they published
+func GetOPDSPublishers(w http.ResponseWriter, r *http.Request) {
+	writeOPDSGroups(w, r, "publishers", "Publishers", "publisher", func(book model.Book) string {
+		return book.Publisher
+	})
+}
+
+// writeOPDSGroups writes a page of a navigation feed with an entry for each
+// value of a field of the books, linking to /opds/books filtered by the
+// query parameter for that field
+func writeOPDSGroups(w http.ResponseWriter, r *http.Request, id, title, parameter string, field func(model.Book) string) {
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := managers.GetLibrary().GetBooks()
+	if err != nil {
+		writeJSONFail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	groups := groupBooks(books, field)
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+	feed := newOPDSFeed(r, "urn:books-api:opds:"+id, title, opdsNavigationType, updated)
+	feed.Links = append(feed.Links, opdsLink{Rel: "up", Type: opdsNavigationType, Href: "/opds"})
+	feed.paginate(r, page, len(groups), opdsNavigationType)
+
+	start, end := pageBounds(page, len(groups))
+	for _, group := range groups[start:end] {
+		href := "/opds/books?" + url.Values{parameter: {group.Name}}.Encode()
+
+		feed.Entries = append(feed.Entries, opdsEntry{
+			ID:      "urn:books-api:opds:" + id + ":" + url.QueryEscape(group.Name),
+			Title:   group.Name,
+			Updated: updated,
+			Content: &opdsContent{Type: "text", Value: countBooksText(group.Count)},
+			Links:   []opdsLink{{Rel: "subsection", Type: opdsAcquisitionType, Href: href}},
+		})
+	}
+
+	err = writeOPDS(w, opdsNavigationType, feed)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// countBooksText returns how many books there are as text, like "2 books"
+func countBooksText(count int) string {
+	if count == 1 {
+		return "1 book"
+	}
+
+	return fmt.Sprintf("%v books", count)
+}
+
+// SearchOPDS is the handler for the GET /opds/search api call,
+// it returns a page of the acquisition feed of the books matching the q
+// query parameter, ranked by relevance like GET /books/search
+func SearchOPDS(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	query := values.Get("q")
+	if query == "" {
+		writeJSONFail(w, http.StatusBadRequest, "The q query parameter is required")
+		return
+	}
+
+	page, err := parsePage(values)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hits := managers.GetSearchIndex().Search(query)
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+	feed := newOPDSFeed(r, "urn:books-api:opds:search:"+url.QueryEscape(query), "Search results for "+query, opdsAcquisitionType, updated)
+	feed.Links = append(feed.Links, opdsLink{Rel: "up", Type: opdsNavigationType, Href: "/opds"})
+	feed.paginate(r, page, len(hits), opdsAcquisitionType)
+
+	start, end := pageBounds(page, len(hits))
+	for _, hit := range hits[start:end] {
+		feed.Entries = append(feed.Entries, bookEntry(hit.Book, updated))
+	}
+
+	err = writeOPDS(w, opdsAcquisitionType, feed)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetOpenSearchDescription is the handler for the GET /opds/opensearch.xml
+// api call, it returns the OpenSearch description e-readers use to search
+// the catalog. The template has to be absolute, so it's made from the host
+// the request was sent to
+func GetOpenSearchDescription(w http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// the header comes from whoever sent the request, so only the two
+	// schemes that make sense in the template are taken from it
+	if proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	description := openSearchDescription{
+		ShortName:     "Books",
+		Description:   "Search the library's books by title, author and publisher",
+		InputEncoding: "UTF-8",
+		URLs: []openSearchURL{{
+			Type:     opdsAcquisitionType,
+			Template: scheme + "://" + r.Host + "/opds/search?q={searchTerms}",
+		}},
+	}
+
+	err := writeOPDS(w, openSearchType, description)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// getOPDS sends a GET request for a feed and reads the response into v
+func getOPDS(t *testing.T, url string, v interface{}) string {
+	res, err := sendRequest(url, "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET %v: %v", url, err)
+		t.FailNow()
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET %v, got %v", url, res.Status)
+		t.FailNow()
+	}
+
+	err = xml.NewDecoder(res.Body).Decode(v)
+	if err != nil {
+		t.Errorf("Error trying to read the body from GET %v: %v", url, err)
+		t.FailNow()
+	}
+
+	return res.Header.Get("Content-Type")
+}
+
+// feedLink returns the href of the feed's link with the given rel
+func feedLink(feed opdsFeed, rel string) string {
+	for _, link := range feed.Links {
+		if link.Rel == rel {
+			return link.Href
+		}
+	}
+
+	return ""
+}
+
+func TestOPDSCatalog(t *testing.T) {
+	defer cleanLibrary()
+
+	var feed opdsFeed
+	contentType := getOPDS(t, "/opds", &feed)
+
+	if !strings.HasPrefix(contentType, opdsNavigationType) {
+		t.Errorf("Expected a navigation feed from GET /opds, got %v", contentType)
+	}
+
+	if len(feed.Entries) != 3 || feed.Entries[0].Links[0].Href != "/opds/books" || feedLink(feed, "search") != "/opds/opensearch.xml" {
+		t.Errorf("Expected GET /opds to link to the books, authors and publishers and to search, got %+v", feed)
+	}
+}
+
+func TestOPDSBooks(t *testing.T) {
+	defer cleanLibrary()
+
+	for i := 0; i < opdsPageSize+5; i++ {
+		book := model.NewBook()
+		book.Title = fmt.Sprintf("Book%02d", i)
+		book.Author = "Someone"
+		book.Rating = 1
+		if i%2 == 0 {
+			book.Author = "Me"
+		}
+		managers.GetLibrary().AddBook(book)
+	}
+
+	var feed opdsFeed
+	contentType := getOPDS(t, "/opds/books", &feed)
+
+	if !strings.HasPrefix(contentType, opdsAcquisitionType) {
+		t.Errorf("Expected an acquisition feed from GET /opds/books, got %v", contentType)
+	}
+
+	if len(feed.Entries) != opdsPageSize || feed.Entries[0].Title != "Book00" || feedLink(feed, "next") != "/opds/books?page=2" || feedLink(feed, "previous") != "" {
+		t.Errorf("Expected the first page of books from GET /opds/books, got %v entries and links %+v", len(feed.Entries), feed.Links)
+	}
+
+	if feed.TotalResults == nil || *feed.TotalResults != opdsPageSize+5 {
+		t.Errorf("Expected the total results to be %v, got %v", opdsPageSize+5, feed.TotalResults)
+	}
+
+	var borrow bool
+	for _, link := range feed.Entries[0].Links {
+		borrow = borrow || link.Rel == opdsBorrowRel
+	}
+	if !borrow {
+		t.Errorf("Expected each book to have a borrow link, got %+v", feed.Entries[0].Links)
+	}
+
+	feed = opdsFeed{}
+	getOPDS(t, "/opds/books?page=2", &feed)
+	if len(feed.Entries) != 5 || feedLink(feed, "next") != "" || feedLink(feed, "previous") != "/opds/books?page=1" {
+		t.Errorf("Expected the last page of books from GET /opds/books?page=2, got %v entries and links %+v", len(feed.Entries), feed.Links)
+	}
+
+	feed = opdsFeed{}
+	getOPDS(t, "/opds/books?author=me", &feed)
+	if len(feed.Entries) != 15 || feed.Entries[0].Authors[0].Name != "Me" {
+		t.Errorf("Expected the books by Me from GET /opds/books?author=me, got %v entries", len(feed.Entries))
+	}
+
+	// pages past maxPage would overflow when they're turned into offsets
+	for _, path := range []string{"/opds/books?page=0", "/opds/books?page=368934881474191034", "/opds/authors?page=368934881474191034", "/opds/search?q=me&page=368934881474191034"} {
+		res, err := sendRequest(path, "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET %v: %v", path, err)
+			t.FailNow()
+		}
+
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status 400 from GET %v, got %v", path, res.Status)
+		}
+	}
+
+	feed = opdsFeed{}
+	getOPDS(t, "/opds/books?page="+strconv.Itoa(maxPage), &feed)
+	if len(feed.Entries) != 0 {
+		t.Errorf("Expected the last page that can be asked for to be empty, got %v entries", len(feed.Entries))
+	}
+}
+
+func TestOPDSAuthors(t *testing.T) {
+	defer cleanLibrary()
+
+	for _, author := range []string{"Me", "me", " Someone ", ""} {
+		book := model.NewBook()
+		book.Title = "MyOPDSBook"
+		book.Author = author
+		book.Rating = 1
+		managers.GetLibrary().AddBook(book)
+	}
+
+	var feed opdsFeed
+	getOPDS(t, "/opds/authors", &feed)
+
+	if len(feed.Entries) != 2 || feed.Entries[0].Content.Value != "2 books" || feed.Entries[1].Title != "Someone" {
+		t.Errorf("Expected the authors grouped ignoring case from GET /opds/authors, got %+v", feed.Entries)
+		t.FailNow()
+	}
+
+	href := feed.Entries[1].Links[0].Href
+	if href != "/opds/books?author=Someone" {
+		t.Errorf("Expected the author to link to their books, got %v", href)
+	}
+
+	var books opdsFeed
+	getOPDS(t, href, &books)
+	if len(books.Entries) != 1 {
+		t.Errorf("Expected the author's link to have their book, got %+v", books.Entries)
+	}
+}
+
+func TestOPDSSearch(t *testing.T) {
+	defer cleanLibrary()
+
+	book := model.NewBook()
+	book.Title = "Dragons of Autumn"
+	book.ISBN = "0-441-17271-7"
+	book.Rating = 1
+	managers.GetLibrary().AddBook(book)
+
+	var feed opdsFeed
+	getOPDS(t, "/opds/search?q=dragon", &feed)
+
+	if len(feed.Entries) != 1 || feed.Entries[0].ID != "urn:uuid:"+book.ID.String() {
+		t.Errorf("Expected the book from GET /opds/search?q=dragon, got %+v", feed.Entries)
+	}
+
+	var entry opdsEntry
+	contentType := getOPDS(t, "/opds/books/"+book.ID.String(), &entry)
+	if !strings.HasPrefix(contentType, opdsEntryType) || entry.Title != book.Title || entry.Identifier != "urn:isbn:0441172717" {
+		t.Errorf("Expected the book's entry from GET /opds/books/{id}, got %v %+v", contentType, entry)
+	}
+
+	var description openSearchDescription
+	getOPDS(t, "/opds/opensearch.xml", &description)
+	if len(description.URLs) != 1 || description.URLs[0].Template != server.URL+"/opds/search?q={searchTerms}" {
+		t.Errorf("Expected an absolute search template, got %+v", description.URLs)
+	}
+
+	for proto, scheme := range map[string]string{"https": "https", "javascript": "http"} {
+		res, err := sendRequestWithHeaders("/opds/opensearch.xml", "GET", "", map[string]string{"X-Forwarded-Proto": proto})
+		if err != nil {
+			t.Errorf("Got error when sending request for GET /opds/opensearch.xml: %v", err)
+			t.FailNow()
+		}
+
+		description = openSearchDescription{}
+		xml.NewDecoder(res.Body).Decode(&description)
+		if len(description.URLs) != 1 || !strings.HasPrefix(description.URLs[0].Template, scheme+"://") {
+			t.Errorf("Expected the %v scheme for X-Forwarded-Proto %v, got %+v", scheme, proto, description.URLs)
+		}
+	}
+}
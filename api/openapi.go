@@ -0,0 +1,555 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askewseth/kubernetes/config"
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// openAPIVersion is the version of the OpenAPI specification that the
+// document served at /openapi.json follows
+const openAPIVersion = "3.0.3"
+
+// routeSpec is what the OpenAPI document says about a route on top of its
+// pattern, method and description. Every route has to have one, which is
+// checked by TestRoutesHaveSpecs
+type routeSpec struct {
+	// Parameters are the route's query and header parameters, the path
+	// parameters come from its pattern
+	Parameters []param
+
+	// Body is an example of the request body, the body's schema is made
+	// from its type, or it's a mediaTypes when the body isn't json. It's
+	// nil when the route doesn't take a body
+	Body interface{}
+
+	// Responses holds an example of the response body for each status the
+	// route can respond with, just like Body, with nil for no body
+	Responses responses
+}
+
+// responses maps status codes to an example of the body sent with them
+type responses map[int]interface{}
+
+// mediaTypes is a request or response body that isn't json, given as the
+// media types it can be sent as
+type mediaTypes []string
+
+// param is a query or header parameter of a route
+type param struct {
+	Name        string
+	Description string
+
+	// In is where the parameter is, either query or header, query if it's
+	// empty
+	In string
+
+	// Type is the parameter's type, either string, integer or boolean,
+	// string if it's empty
+	Type string
+
+	Required bool
+}
+
+// the parameters shared by more than one route
+var (
+	bookQueryParams = []param{
+		{Name: "author", Description: "only books by this author, ignoring case"},
+		{Name: "publisher", Description: "only books from this publisher, ignoring case"},
+		{Name: "status", Description: "only books with this status, CheckedIn|CheckedOut|Reserved|Lost or 0-3"},
+		{Name: "min_rating", Type: "integer", Description: "only books with at least this rating, 1-3"},
+		{Name: "max_rating", Type: "integer", Description: "only books with at most this rating, 1-3"},
+		{Name: "published_after", Description: "only books published on or after this date, like 2018-01-02 or 2018-01-02T15:04:05Z"},
+		{Name: "published_before", Description: "only books published on or before this date, a date without a time includes the whole day, like 2018-01-02 or 2018-01-02T15:04:05Z"},
+		{Name: "sort", Description: "the field to sort by, title|author|publisher|publish_date|rating|status"},
+		{Name: "order", Description: "asc or desc"},
+		{Name: "limit", Type: "integer", Description: "the most books to return, 0 for all of them"},
+		{Name: "offset", Type: "integer", Description: "how many of the books to skip"},
+	}
+
+	pageParams = []param{
+		{Name: "limit", Type: "integer", Description: "the most results to return, 0 for all of them"},
+		{Name: "offset", Type: "integer", Description: "how many of the results to skip"},
+	}
+
+	ifMatchParam = param{Name: "If-Match", In: "header", Description: "only make the change if the book's ETag matches"}
+
+	eventParams = []param{
+		{Name: "Last-Event-ID", In: "header", Type: "integer", Description: "the id of the last event the client got, the events after it are sent first"},
+		{Name: "lastEventId", Type: "integer", Description: "the Last-Event-ID, for clients that can't set headers"},
+	}
+
+	opdsPageParam = param{Name: "page", Type: "integer", Description: "the page of the feed, starting at 1"}
+)
+
+// openAPIDocument is the root of an OpenAPI document
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+// openAPIInfo describes the api as a whole
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// openAPIComponents holds the schemas that operations refer to by name, and
+// the ways requests can be authenticated
+type openAPIComponents struct {
+	Schemas         schemaSet                        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+// openAPISecurityScheme is one of the ways a request can be authenticated
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// securitySchemes are the credentials that authorize checks, any one of them
+// can be used
+var securitySchemes = map[string]openAPISecurityScheme{
+	"apiKey": {Type: "apiKey", Name: "X-API-Key", In: "header", Description: "a static api key"},
+	"basic":  {Type: "http", Scheme: "basic", Description: "a static api key given as the password, the username is ignored"},
+	"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "a JWT with a role claim of reader, librarian or admin"},
+}
+
+// openAPIOperation is a single route
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security"`
+}
+
+// openAPIParameter is a path, query or header parameter of an operation
+type openAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+// openAPIRequestBody is the body an operation takes
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse is one of the responses an operation can send
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType is the schema of a body sent as a media type
+type openAPIMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is a JSON schema, as OpenAPI uses them
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaSet holds the schemas of named types by their name
+type schemaSet map[string]*schema
+
+var (
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	timeType     = reflect.TypeOf(time.Time{})
+	statusType   = reflect.TypeOf(model.Status(0))
+	durationType = reflect.TypeOf(config.Duration(0))
+
+	// pathParam finds the parameters in a route's pattern
+	pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// schemaFor returns the schema of the json a type is marshaled to, named
+// structs are added to the set and referred to by their name
+func (s schemaSet) schemaFor(t reflect.Type) *schema {
+	switch t {
+	case uuidType:
+		return &schema{Type: "string", Format: "uuid"}
+	case timeType:
+		return &schema{Type: "string", Format: "date-time"}
+	case statusType:
+		// books marshal their status as its name
+		var names []string
+		for _, status := range model.Statuses {
+			names = append(names, status.String())
+		}
+		return &schema{Type: "string", Enum: names}
+	case durationType:
+		// durations marshal as strings like 72h
+		return &schema{Type: "string", Format: "duration"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schemaFor(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.objectSchema(t)
+		}
+
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, found := s[name]; !found {
+			// the schema is added before its fields are so that a type
+			// that refers to itself doesn't recurse forever
+			s[name] = &schema{}
+			*s[name] = *s.objectSchema(t)
+		}
+		return &schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	}
+
+	// anything else, like an interface, can be any json
+	return &schema{}
+}
+
+// objectSchema returns the schema of a struct from the json names of its
+// fields, the fields without omitempty are required
+func (s schemaSet) objectSchema(t reflect.Type) *schema {
+	object := &schema{Type: "object", Properties: make(map[string]*schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		// the fields of an embedded struct are marshaled as if they were
+		// the outer struct's
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := s.objectSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				object.Properties[property] = propertySchema
+			}
+			object.Required = append(object.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		object.Properties[name] = s.schemaFor(field.Type)
+		if !strings.Contains(field.Tag.Get("json"), ",omitempty") {
+			object.Required = append(object.Required, name)
+		}
+	}
+
+	return object
+}
+
+// content returns the media types of a request or response body
+func (s schemaSet) content(body interface{}) map[string]*openAPIMediaType {
+	types, ok := body.(mediaTypes)
+	if !ok {
+		return map[string]*openAPIMediaType{
+			"application/json": {Schema: s.schemaFor(reflect.TypeOf(body))},
+		}
+	}
+
+	content := make(map[string]*openAPIMediaType)
+	for _, mediaType := range types {
+		file := &schema{Type: "string", Format: "binary"}
+		if mediaType == "multipart/form-data" {
+			file = &schema{Type: "object", Properties: map[string]*schema{"file": file}}
+		}
+		content[mediaType] = &openAPIMediaType{Schema: file}
+	}
+
+	return content
+}
+
+// handlerName returns the name of a route's handler function, which is used
+// as the operation id
+func handlerName(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// routeTag returns the tag a route is grouped under, the first part of its
+// pattern
+func routeTag(pattern string) string {
+	tag := strings.SplitN(strings.TrimPrefix(pattern, "/"), "/", 2)[0]
+	return strings.TrimSuffix(tag, path.Ext(tag))
+}
+
+// openAPI is the OpenAPI document for every route, the routes never change
+// so it's only built once
+var openAPI openAPIDocument
+
+func init() {
+	openAPI = buildOpenAPI(routes)
+}
+
+// buildOpenAPI builds the OpenAPI document for the routes
+func buildOpenAPI(routes []route) openAPIDocument {
+	doc := openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       "books-api",
+			Description: "Manages a library's books, patrons, loans, holds and fines",
+			Version:     "1.0.0",
+		},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{Schemas: make(schemaSet), SecuritySchemes: securitySchemes},
+	}
+	schemas := doc.Components.Schemas
+
+	for _, route := range routes {
+		spec := route.Spec
+		if spec == nil {
+			spec = &routeSpec{}
+		}
+
+		operation := &openAPIOperation{
+			OperationID: handlerName(route.Function),
+			Summary:     route.Description,
+			Tags:        []string{routeTag(route.Pattern)},
+			Responses:   make(map[string]*openAPIResponse),
+			Security:    []map[string][]string{},
+		}
+
+		// an empty security list is how OpenAPI says a route is public
+		if route.Role != RolePublic {
+			operation.Description = fmt.Sprintf("Needs the %v role", route.Role)
+			for name := range securitySchemes {
+				operation.Security = append(operation.Security, map[string][]string{name: {}})
+			}
+			sort.Slice(operation.Security, func(i, j int) bool {
+				return securityName(operation.Security[i]) < securityName(operation.Security[j])
+			})
+
+		}
+
+		for _, match := range pathParam.FindAllStringSubmatch(route.Pattern, -1) {
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &schema{Type: "string", Format: "uuid"},
+			})
+		}
+
+		for _, p := range spec.Parameters {
+			parameter := openAPIParameter{Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: &schema{Type: p.Type}}
+			if parameter.In == "" {
+				parameter.In = "query"
+			}
+			if parameter.Schema.Type == "" {
+				parameter.Schema.Type = "string"
+			}
+			operation.Parameters = append(operation.Parameters, parameter)
+		}
+
+		if spec.Body != nil {
+			operation.RequestBody = &openAPIRequestBody{Required: true, Content: schemas.content(spec.Body)}
+		}
+
+		rs := spec.Responses
+		if route.Role != RolePublic {
+			rs = withAuthResponses(rs)
+		}
+
+		for status, body := range rs {
+			response := &openAPIResponse{Description: http.StatusText(status)}
+			if body != nil {
+				response.Content = schemas.content(body)
+			}
+			operation.Responses[strconv.Itoa(status)] = response
+		}
+
+		if doc.Paths[route.Pattern] == nil {
+			doc.Paths[route.Pattern] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[route.Pattern][strings.ToLower(route.Method)] = operation
+	}
+
+	return doc
+}
+
+// securityName returns the name of the scheme in a security requirement
+func securityName(requirement map[string][]string) string {
+	for name := range requirement {
+		return name
+	}
+
+	return ""
+}
+
+// withAuthResponses returns a copy of the responses with the 401 and 403 that
+// authorize can send
+func withAuthResponses(rs responses) responses {
+	all := responses{http.StatusUnauthorized: apiError{}, http.StatusForbidden: apiError{}}
+	for status, body := range rs {
+		all[status] = body
+	}
+
+	return all
+}
+
+// GetOpenAPI is the handler for the GET /openapi.json api call,
+// it returns the OpenAPI document describing every route
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := writeJSONSuccess(w, openAPI, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// GetDocs is the handler for the GET /docs api call,
+// it returns a page documenting every route, made from the same OpenAPI
+// document as GET /openapi.json
+func GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	err := docsTemplate.Execute(w, openAPI)
+	if err != nil {
+		log.Errorf("Error writing the docs page: %v", err)
+	}
+}
+
+// schemaName returns how a schema is shown on the docs page, the name of the
+// schema it refers to, or its type
+func schemaName(s *schema) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Ref != "":
+		return path.Base(s.Ref)
+	case s.Type == "array":
+		return "[]" + schemaName(s.Items)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map[string]" + schemaName(s.AdditionalProperties)
+	case len(s.Enum) > 0:
+		return strings.Join(s.Enum, "|")
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	case s.Type == "":
+		return "any"
+	}
+
+	return s.Type
+}
+
+// isRequired returns true if the property is one of the required ones
+func isRequired(required []string, property string) bool {
+	for _, r := range required {
+		if r == property {
+			return true
+		}
+	}
+
+	return false
+}
+
+// docsTemplate is the docs page, everything it needs is in the page so that
+// it works without access to the internet
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"schemaName": schemaName,
+	"isRequired": isRequired,
+	"upper":      strings.ToUpper,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Info.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 0 auto; padding: 1em; color: #222; }
+.operation { border: 1px solid #ddd; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.method { display: inline-block; min-width: 4em; font-weight: bold; }
+code { background: #f4f4f4; padding: 0 0.2em; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+td, th { border: 1px solid #ddd; padding: 0.2em 0.5em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Info.Title}}</h1>
+<p>{{.Info.Description}}, the OpenAPI {{.OpenAPI}} document is at <a href="/openapi.json">/openapi.json</a></p>
+{{range $path, $methods := .Paths}}{{range $method, $operation := $methods}}
+<div class="operation" id="{{$operation.OperationID}}">
+<h3><span class="method">{{upper $method}}</span> <code>{{$path}}</code></h3>
+<p>{{$operation.Summary}}</p>
+{{if $operation.Parameters}}<table>
+<tr><th>Parameter</th><th>In</th><th>Type</th><th>Description</th></tr>
+{{range $operation.Parameters}}<tr><td><code>{{.Name}}</code>{{if .Required}} (required){{end}}</td><td>{{.In}}</td><td>{{schemaName .Schema}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>{{end}}
+{{with $operation.RequestBody}}<p>Body: {{range $type, $media := .Content}}<code>{{$type}}</code> {{schemaName $media.Schema}} {{end}}</p>{{end}}
+<table>
+<tr><th>Status</th><th>Body</th></tr>
+{{range $status, $response := $operation.Responses}}<tr><td>{{$status}} {{$response.Description}}</td><td>{{range $type, $media := $response.Content}}<code>{{$type}}</code> {{schemaName $media.Schema}} {{end}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}{{end}}
+<h2>Schemas</h2>
+{{range $name, $schema := .Components.Schemas}}
+<div class="operation" id="schema-{{$name}}">
+<h3>{{$name}}</h3>
+<table>
+<tr><th>Property</th><th>Type</th></tr>
+{{range $property, $propertySchema := $schema.Properties}}<tr><td><code>{{$property}}</code>{{if isRequired $schema.Required $property}} (required){{end}}</td><td>{{schemaName $propertySchema}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestRoutesHaveSpecs fails when a route is added to the routes table
+// without saying what it takes and what it responds with
+func TestRoutesHaveSpecs(t *testing.T) {
+	operationIDs := make(map[string]bool)
+
+	for _, route := range routes {
+		name := route.Method + " " + route.Pattern
+
+		if route.Spec == nil {
+			t.Errorf("%v has no Spec, every route needs one for the OpenAPI document", name)
+			continue
+		}
+
+		if len(route.Spec.Responses) == 0 {
+			t.Errorf("%v has no Responses in its Spec", name)
+		}
+
+		for status := range route.Spec.Responses {
+			if status < 200 || status > 599 {
+				t.Errorf("%v has a response with the invalid status %v", name, status)
+			}
+		}
+
+		id := handlerName(route.Function)
+		if operationIDs[id] {
+			t.Errorf("%v uses the handler %v, which is already used by another route", name, id)
+		}
+		operationIDs[id] = true
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	res, err := sendRequest("/openapi.json", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /openapi.json: %v", err)
+		t.FailNow()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	var doc openAPIDocument
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		t.Errorf("Error trying to read the body from GET /openapi.json: %v", err)
+		t.FailNow()
+	}
+
+	if doc.OpenAPI != openAPIVersion {
+		t.Errorf("Expected the document to be OpenAPI %v, got %v", openAPIVersion, doc.OpenAPI)
+	}
+
+	// every route is in the document
+	for _, route := range routes {
+		if doc.Paths[route.Pattern][strings.ToLower(route.Method)] == nil {
+			t.Errorf("Expected %v %v to be in the document", route.Method, route.Pattern)
+		}
+	}
+
+	operation := doc.Paths["/books/{id}"]["put"]
+	if operation == nil || len(operation.Parameters) != 2 || operation.Parameters[0].In != "path" || operation.Parameters[1].Name != "If-Match" {
+		t.Errorf("Expected PUT /books/{id} to take the id and If-Match, got %+v", operation)
+	}
+
+	book := doc.Components.Schemas["Book"]
+	if book == nil || book.Properties["id"].Format != "uuid" || len(book.Properties["status"].Enum) != 4 || book.Properties["isbn"] == nil {
+		t.Errorf("Expected the Book schema to have the book's fields, got %+v", book)
+	}
+
+	// every schema that's referred to has to be in the document
+	for _, ref := range strings.Split(string(body), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("Expected the schema %v to be in the document", name)
+		}
+	}
+}
+
+func TestGetDocs(t *testing.T) {
+	res, err := sendRequest("/docs", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /docs: %v", err)
+		t.FailNow()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != 200 || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Expected a page from GET /docs, got %v %v", res.Status, res.Header.Get("Content-Type"))
+	}
+
+	for _, s := range []string{`id="GetBooks"`, `id="schema-Book"`, "/books/{id}/checkout"} {
+		if !strings.Contains(string(body), s) {
+			t.Errorf("Expected the docs page to have %v in it", s)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the content types that PATCH /books/{id} accepts
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrPatchTestFailed is returned when a test operation in a JSON Patch
+	// doesn't match the book, the whole patch is rejected
+	ErrPatchTestFailed = errors.New("The patch's test operation didn't match the book")
+)
+
+// mergePatch applies a JSON Merge Patch (RFC 7386) to a json document that's
+// been decoded into an interface{}. Members of the patch that are null are
+// removed from the document, objects are merged recursively, and anything
+// else replaces what's in the document
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// patchOperation is a single operation of a JSON Patch (RFC 6902), Value is
+// kept raw so that a null value can be told apart from a missing one
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parseJSONPatch reads a JSON Patch document and checks that each of the
+// operations has the members its op needs
+func parseJSONPatch(data []byte) ([]patchOperation, error) {
+	var operations []patchOperation
+	err := json.Unmarshal(data, &operations)
+	if err != nil {
+		return nil, fmt.Errorf("The patch must be an array of operations: %v", err)
+	}
+
+	for i, operation := range operations {
+		if _, err = parsePointer(operation.Path); err != nil {
+			return nil, fmt.Errorf("Operation %v: %v", i, err)
+		}
+
+		switch operation.Op {
+		case "add", "replace", "test":
+			if len(operation.Value) == 0 {
+				return nil, fmt.Errorf("Operation %v: %v needs a value", i, operation.Op)
+			}
+		case "move", "copy":
+			if _, err = parsePointer(operation.From); err != nil {
+				return nil, fmt.Errorf("Operation %v: from %v", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("Operation %v: unknown op %q", i, operation.Op)
+		}
+	}
+
+	return operations, nil
+}
+
+// applyJSONPatch applies each of the operations in turn to a json document
+// that's been decoded into an interface{}, stopping at the first one that
+// fails
+func applyJSONPatch(doc interface{}, operations []patchOperation) (interface{}, error) {
+	for i, operation := range operations {
+		var err error
+		doc, err = applyOperation(doc, operation)
+		if err == ErrPatchTestFailed {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Operation %v: %v", i, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// applyOperation applies a single JSON Patch operation, the operation has
+// already been checked by parseJSONPatch
+func applyOperation(doc interface{}, operation patchOperation) (interface{}, error) {
+	path, _ := parsePointer(operation.Path)
+	from, _ := parsePointer(operation.From)
+
+	var value interface{}
+	if len(operation.Value) > 0 {
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch operation.Op {
+	case "add":
+		return addValue(doc, path, value)
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "replace":
+		// the root can't be removed, but it can be replaced
+		if len(path) == 0 {
+			return value, nil
+		}
+
+		doc, _, err := removeValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	case "move":
+		if strings.HasPrefix(operation.Path, operation.From+"/") {
+			return nil, fmt.Errorf("Can't move %v into one of its own children", operation.From)
+		}
+
+		doc, moved, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, moved)
+
+	case "copy":
+		copied, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, copyValue(copied))
+
+	case "test":
+		actual, err := getValue(doc, path)
+		if err != nil || !reflect.DeepEqual(actual, value) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("Unknown op %q", operation.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens,
+// the empty pointer is the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("The path %q must start with a /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex reads an array index token, which has to be within the array,
+// or if end is true can also be one past the end of it
+func arrayIndex(token string, array []interface{}, end bool) (int, error) {
+	last := len(array) - 1
+	if end {
+		last = len(array)
+	}
+
+	if end && token == "-" {
+		return len(array), nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > last || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("The array index %q is out of range", token)
+	}
+
+	return i, nil
+}
+
+// getValue returns the value at the path in the document
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, found := container[token]
+			if !found {
+				return nil, fmt.Errorf("There's nothing at %q", token)
+			}
+			doc = value
+
+		case []interface{}:
+			i, err := arrayIndex(token, container, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+
+		default:
+			return nil, fmt.Errorf("There's nothing at %q", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// addValue adds the value at the path in the document and returns the new
+// document, members of objects are replaced while values are inserted
+// into arrays
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			container[token] = value
+			return container, nil
+		}
+
+		child, found := container[token]
+		if !found {
+			return nil, fmt.Errorf("There's nothing at %q", token)
+		}
+
+		child, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		container[token] = child
+		return container, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, container, len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rest) == 0 {
+			added := make([]interface{}, 0, len(container)+1)
+			added = append(added, container[:i]...)
+			added = append(added, value)
+			return append(added, container[i:]...), nil
+		}
+
+		child, err := addValue(container[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		container[i] = child
+		return container, nil
+	}
+
+	return nil, fmt.Errorf("There's nothing at %q", token)
+}
+
+// removeValue removes the value at the path in the document, returning the
+// new document along with the value that was removed
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("The whole document can't be removed")
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, found := container[token]
+		if !found {
+			return nil, nil, fmt.Errorf("There's nothing at %q", token)
+		}
+
+		if len(rest) == 0 {
+			delete(container, token)
+			return container, child, nil
+		}
+
+		child, removed, err := removeValue(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		container[token] = child
+		return container, removed, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, container, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(rest) == 0 {
+			removed := container[i]
+			remaining := make([]interface{}, 0, len(container)-1)
+			remaining = append(remaining, container[:i]...)
+			return append(remaining, container[i+1:]...), removed, nil
+		}
+
+		child, removed, err := removeValue(container[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		container[i] = child
+		return container, removed, nil
+	}
+
+	return nil, nil, fmt.Errorf("There's nothing at %q", token)
+}
+
+// copyValue returns a deep copy of a decoded json value, so that a copied
+// value and the original can be patched separately
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for name, child := range v {
+			copied[name] = copyValue(child)
+		}
+		return copied
+
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, child := range v {
+			copied[i] = copyValue(child)
+		}
+		return copied
+	}
+
+	return value
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decode decodes a json string for comparing documents
+func decode(t *testing.T, s string) interface{} {
+	var doc interface{}
+	err := json.Unmarshal([]byte(s), &doc)
+	if err != nil {
+		t.Errorf("Error decoding %v: %v", s, err)
+		t.FailNow()
+	}
+
+	return doc
+}
+
+func TestMergePatch(t *testing.T) {
+	// the examples from appendix A of RFC 7386
+	tests := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		patched := mergePatch(decode(t, test.target), decode(t, test.patch))
+		if !reflect.DeepEqual(patched, decode(t, test.expected)) {
+			t.Errorf("Merging %v into %v, expected %v but got %v", test.patch, test.target, test.expected, patched)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// examples from appendix A of RFC 6902
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"","value":{"foo":"baz"}}]`, `{"foo":"baz"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":"bar","baz":"bar"}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":1}]`, `{"/":1,"~1":10}`},
+	}
+
+	for _, test := range tests {
+		operations, err := parseJSONPatch([]byte(test.patch))
+		if err != nil {
+			t.Errorf("Error parsing %v: %v", test.patch, err)
+			continue
+		}
+
+		patched, err := applyJSONPatch(decode(t, test.doc), operations)
+		if err != nil {
+			t.Errorf("Error applying %v to %v: %v", test.patch, test.doc, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(patched, decode(t, test.expected)) {
+			t.Errorf("Applying %v to %v, expected %v but got %v", test.patch, test.doc, test.expected, patched)
+		}
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	badPatches := []string{
+		`{"op":"add","path":"/a","value":1}`,
+		`[{"op":"frobnicate","path":"/a"}]`,
+		`[{"op":"add","path":"a","value":1}]`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"move","path":"/a","from":"b"}]`,
+	}
+
+	for _, patch := range badPatches {
+		if _, err := parseJSONPatch([]byte(patch)); err == nil {
+			t.Errorf("Expected an error parsing %v", patch)
+		}
+	}
+
+	failingPatches := []string{
+		`[{"op":"remove","path":"/missing"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"add","path":"/missing/child","value":1}]`,
+		`[{"op":"add","path":"/list/5","value":1}]`,
+		`[{"op":"add","path":"/list/01","value":1}]`,
+		`[{"op":"move","from":"/obj","path":"/obj/child"}]`,
+	}
+
+	for _, patch := range failingPatches {
+		operations, err := parseJSONPatch([]byte(patch))
+		if err != nil {
+			t.Errorf("Error parsing %v: %v", patch, err)
+			continue
+		}
+
+		if _, err = applyJSONPatch(decode(t, `{"list":[1,2],"obj":{}}`), operations); err == nil {
+			t.Errorf("Expected an error applying %v", patch)
+		}
+	}
+
+	operations, _ := parseJSONPatch([]byte(`[{"op":"test","path":"/a","value":2}]`))
+	if _, err := applyJSONPatch(decode(t, `{"a":1}`), operations); err != ErrPatchTestFailed {
+		t.Errorf("Expected %v from a failing test operation, got %v", ErrPatchTestFailed, err)
+	}
+}
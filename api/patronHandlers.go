@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetPatrons is the handler for the GET /patrons api call,
+// it returns a list of all of the patrons
+func GetPatrons(w http.ResponseWriter, r *http.Request) {
+	err := writeJSONSuccess(w, managers.GetCirculation().GetPatrons(), http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// PostPatron is the handler for the POST /patrons api call,
+// it will create a new patron and return it
+func PostPatron(w http.ResponseWriter, r *http.Request) {
+	patron := model.NewPatron()
+	err := json.NewDecoder(r.Body).Decode(&patron)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The Post Body was invalid")
+		return
+	}
+	defer r.Body.Close()
+
+	err = patron.Validate()
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the id, if given, is overwritten
+	patron.ID = model.NewPatron().ID
+
+	err = managers.GetCirculation().AddPatron(patron)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, patron, http.StatusCreated)
+}
+
+// GetPatronByID is the handler for the GET /patrons/{id} api call,
+// it will return a specific patron given their uuid
+func GetPatronByID(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	patron, err := managers.GetCirculation().GetPatronByID(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, patron, http.StatusOK)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestPostAndGetPatron(t *testing.T) {
+	defer cleanLibrary()
+
+	res, err := sendRequest("/patrons", "POST", `{"name": "Me", "email": "me@example.com"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /patrons: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 201 {
+		t.Errorf("Expected status 201 from POST /patrons, got %v", res.Status)
+	}
+
+	var patron map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&patron)
+	if err != nil {
+		t.Errorf("Error trying to read the body from POST /patrons: %v", err)
+		t.FailNow()
+	}
+
+	res, err = sendRequest("/patrons/"+patron["id"].(string), "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /patrons/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /patrons/{id}, got %v", res.Status)
+	}
+
+	if len(managers.GetCirculation().GetPatrons()) != 1 {
+		t.Errorf("Didn't have 1 patron after calling POST /patrons")
+	}
+}
+
+func TestPostPatronNoName(t *testing.T) {
+	defer cleanLibrary()
+
+	res, err := sendRequest("/patrons", "POST", `{"email": "me@example.com"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /patrons: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /patrons without a name, got %v", res.Status)
+	}
+}
+
+func TestGetPatronBadPatron(t *testing.T) {
+	defer cleanLibrary()
+
+	id, _ := uuid.NewV4()
+	res, err := sendRequest("/patrons/"+id.String(), "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /patrons/{id}: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status 404 from GET /patrons/{id} with a bad id, got %v", res.Status)
+	}
+}
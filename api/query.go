@@ -0,0 +1,177 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// parseBookQuery reads the filtering, sorting and paging query parameters of
+// a GET /books request into a BookQuery
+func parseBookQuery(values url.Values) (managers.BookQuery, error) {
+	q := managers.BookQuery{
+		Author:    values.Get("author"),
+		Publisher: values.Get("publisher"),
+		SortBy:    values.Get("sort"),
+	}
+
+	if status := values.Get("status"); status != "" {
+		s, err := parseStatus(status)
+		if err != nil {
+			return q, err
+		}
+		q.Status = &s
+	}
+
+	var err error
+	if q.MinRating, err = parseRating(values, "min_rating"); err != nil {
+		return q, err
+	}
+	if q.MaxRating, err = parseRating(values, "max_rating"); err != nil {
+		return q, err
+	}
+
+	if q.PublishedAfter, err = parseDate(values, "published_after"); err != nil {
+		return q, err
+	}
+	if q.PublishedBefore, err = parseEndDate(values, "published_before"); err != nil {
+		return q, err
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, fmt.Errorf("The order must be either asc or desc")
+	}
+
+	if q.Limit, err = parseCount(values, "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseCount(values, "offset"); err != nil {
+		return q, err
+	}
+
+	return q, q.Validate()
+}
+
+// parseStatus reads a status given either as its name or its number
+func parseStatus(s string) (model.Status, error) {
+	for _, status := range model.Statuses {
+		if s == status.String() || s == strconv.Itoa(int(status)) {
+			return status, nil
+		}
+	}
+
+	return 0, model.ErrInvalidStatus
+}
+
+// parseRating reads an optional rating query parameter, returning 0 if it
+// wasn't given
+func parseRating(values url.Values, name string) (uint8, error) {
+	s := values.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+
+	rating, err := strconv.Atoi(s)
+	if err != nil || rating < 1 || rating > 3 {
+		return 0, fmt.Errorf("The %v must be 1-3", name)
+	}
+
+	return uint8(rating), nil
+}
+
+// parseDate reads an optional date query parameter given either as a full
+// RFC 3339 timestamp or just as a date, which is the start of that day
+func parseDate(values url.Values, name string) (*time.Time, error) {
+	date, _, err := parseDateOrDay(values, name)
+	return date, err
+}
+
+// parseEndDate reads an optional date query parameter like parseDate, but
+// for the inclusive upper bound of a range, so a date given without a time
+// is the very end of that day
+func parseEndDate(values url.Values, name string) (*time.Time, error) {
+	date, dayOnly, err := parseDateOrDay(values, name)
+	if date != nil && dayOnly {
+		end := date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		date = &end
+	}
+
+	return date, err
+}
+
+// parseDateOrDay reads an optional date query parameter, returning true if
+// it was given as just a date without a time
+func parseDateOrDay(values url.Values, name string) (*time.Time, bool, error) {
+	s := values.Get(name)
+	if s == "" {
+		return nil, false, nil
+	}
+
+	if date, err := time.Parse(time.RFC3339, s); err == nil {
+		return &date, false, nil
+	}
+	if date, err := time.Parse("2006-01-02", s); err == nil {
+		return &date, true, nil
+	}
+
+	return nil, false, fmt.Errorf("The %v must be a date formatted like 2018-01-02 or 2018-01-02T15:04:05Z", name)
+}
+
+// parseCount reads an optional non-negative integer query parameter
+func parseCount(values url.Values, name string) (int, error) {
+	s := values.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("The %v must be a non-negative integer", name)
+	}
+
+	return count, nil
+}
+
+// writePageHeaders sets the X-Total-Count header to the number of books that
+// matched the query, and when the request asked for a limited page, a Link
+// header pointing to the next and previous pages
+func writePageHeaders(w http.ResponseWriter, r *http.Request, q managers.BookQuery, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	if q.Limit == 0 {
+		return
+	}
+
+	// pageLink returns the url of the request with its offset changed
+	pageLink := func(offset int, rel string) string {
+		values := r.URL.Query()
+		values.Set("offset", strconv.Itoa(offset))
+		u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		return fmt.Sprintf(`<%v>; rel="%v"`, u.String(), rel)
+	}
+
+	var links []string
+	if q.Limit < total-q.Offset {
+		links = append(links, pageLink(q.Offset+q.Limit, "next"))
+	}
+	if q.Offset > 0 {
+		prev := q.Offset - q.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(prev, "prev"))
+	}
+
+	for _, link := range links {
+		w.Header().Add("Link", link)
+	}
+}
@@ -1,23 +1,46 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
 )
 
 // GetRouter returns all of the routes in a pointer to a mux.Router object which
 // can be passed to ListenAndServe.
 //
-// Here is where any interceptors/decorators would be applied
+// Every route is wrapped in a middleware recording its metrics, the
+// defaultMiddleware, then, unless it's a Probe, a wait for the server to
+// finish starting, a check that the request has the route's Role, and then
+// its own Middleware. The responses for paths and methods that don't
+// match any route get the metrics and the defaultMiddleware too
 func GetRouter() *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 	for _, route := range routes {
+		middlewares := append([]middleware{instrument(route.Pattern)}, defaultMiddleware...)
+		if !route.Probe {
+			middlewares = append(middlewares, waitForStartup)
+		}
+		middlewares = append(middlewares, authorize(route.Role))
+		middlewares = append(middlewares, route.Middleware...)
 
 		// append each route to the router
 		router.
 			Methods(route.Method).
 			Path(route.Pattern).
-			Handler(route.Function)
+			Handler(chain(route.Function, middlewares...))
 
 	}
+
+	unmatched := append([]middleware{instrument(unmatchedRoute)}, defaultMiddleware...)
+	router.NotFoundHandler = chain(http.NotFoundHandler(), unmatched...)
+	router.MethodNotAllowedHandler = chain(http.HandlerFunc(methodNotAllowed), unmatched...)
+
 	return router
 }
+
+// methodNotAllowed responds to a request for a path that has routes, just
+// not for the request's method
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
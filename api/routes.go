@@ -1,12 +1,33 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/askewseth/kubernetes/config"
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
 
 type route struct {
 	Pattern     string
 	Function    http.HandlerFunc
 	Method      string
 	Description string
+
+	// Probe routes are served while the server is starting, every other
+	// route responds with a 503 until it's finished
+	Probe bool
+
+	// Role is the least role a request needs to call the route, once
+	// authentication is turned on with SetAuthenticator
+	Role Role
+
+	// Middleware wraps the route after the defaultMiddleware, the first
+	// middleware is the outermost
+	Middleware []middleware
+
+	// Spec is what the OpenAPI document says about the route
+	Spec *routeSpec
 }
 
 var routes = []route{
@@ -14,34 +35,623 @@ var routes = []route{
 		Pattern:     "/books",
 		Function:    GetBooks,
 		Method:      "GET",
+		Role:        RoleReader,
 		Description: "/books will print out all of the books",
+		Spec: &routeSpec{
+			Parameters: bookQueryParams,
+			Responses:  responses{200: []model.Book{}, 400: apiError{}},
+		},
 	},
 
 	route{
 		Pattern:     "/books",
 		Function:    PostBook,
 		Method:      "POST",
-		Description: "POST /book will create a new book in the library",
+		Role:        RoleLibrarian,
+		Description: "POST /book will create a new book in the library, with a new id, and return it",
+		Spec: &routeSpec{
+			Body:      model.Book{},
+			Responses: responses{201: model.Book{}, 400: apiError{}},
+		},
+	},
+
+	// the routes from /books/batch to /books/search have to come before
+	// /books/{id} so that batch, the exports, import, events and search
+	// aren't taken as ids
+	route{
+		Pattern:     "/books/batch",
+		Function:    PostBatch,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/batch will create, update and delete many books at once",
+		Spec: &routeSpec{
+			Body:      batchRequest{},
+			Responses: responses{200: batchResponse{}, 207: batchResponse{}, 400: apiError{}, 413: apiError{}, 422: batchResponse{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/export.csv",
+		Function:    ExportBooksCSV,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/books/export.csv will return the books in the library as a csv",
+		Spec: &routeSpec{
+			Parameters: bookQueryParams,
+			Responses:  responses{200: mediaTypes{"text/csv"}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/export.mrc",
+		Function:    ExportBooksMARC,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/books/export.mrc will return the books in the library as MARC21 records",
+		Spec: &routeSpec{
+			Parameters: bookQueryParams,
+			Responses:  responses{200: mediaTypes{"application/marc"}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/export.xml",
+		Function:    ExportBooksMARCXML,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/books/export.xml will return the books in the library as MARCXML",
+		Spec: &routeSpec{
+			Parameters: bookQueryParams,
+			Responses:  responses{200: mediaTypes{"application/marcxml+xml"}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/import",
+		Function:    ImportBooks,
+		Method:      "POST",
+		Role:        RoleAdmin,
+		Description: "POST /books/import will add or replace books from a csv, MARC21 or MARCXML file",
+		Middleware:  []middleware{limitBody(maxImportSize)},
+		Spec: &routeSpec{
+			Parameters: []param{
+				{Name: "format", Description: "csv|marc|marcxml, otherwise it's worked out from the content type or file name"},
+				{Name: "dry_run", Type: "boolean", Description: "check every row without changing anything"},
+				{Name: "atomic", Type: "boolean", Description: "only import the rows if all of them can be"},
+				{Name: "default_rating", Type: "integer", Description: "the rating, 1-3, given to books without one"},
+				{Name: "map", Description: "maps a csv header to a field, as header=field, can be given more than once"},
+			},
+			Body:      mediaTypes{"text/csv", "application/marc", "application/marcxml+xml", "multipart/form-data"},
+			Responses: responses{200: managers.ImportReport{}, 400: apiError{}, 413: apiError{}, 422: managers.ImportReport{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/events",
+		Function:    GetBookEvents,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/books/events will stream the changes made to the books as Server-Sent Events",
+		Spec: &routeSpec{
+			Parameters: eventParams,
+			Responses:  responses{200: mediaTypes{eventStreamType}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/search",
+		Function:    SearchBooks,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/books/search?q= will return the books matching the query ranked by relevance",
+		Spec: &routeSpec{
+			Parameters: append([]param{{Name: "q", Required: true, Description: "the words to search for"}}, pageParams...),
+			Responses:  responses{200: []managers.SearchHit{}, 400: apiError{}},
+		},
 	},
 
 	route{
 		Pattern:     "/books/{id}",
 		Function:    GetBookByID,
 		Method:      "GET",
+		Role:        RoleReader,
 		Description: "/book/{id} will return a specific book by it's id",
+		Spec: &routeSpec{
+			Parameters: []param{{Name: "If-None-Match", In: "header", Description: "respond with a 304 if the book's ETag matches"}},
+			Responses:  responses{200: model.Book{}, 304: nil, 400: apiError{}, 404: apiError{}},
+		},
 	},
 
 	route{
 		Pattern:     "/books/{id}",
 		Function:    PutBook,
 		Method:      "PUT",
+		Role:        RoleLibrarian,
 		Description: "PUT /book/{id} will modify the given book if it exists",
+		Spec: &routeSpec{
+			Parameters: []param{ifMatchParam},
+			Body:       model.Book{},
+			Responses:  responses{202: nil, 400: apiError{}, 404: apiError{}, 412: apiError{}},
+		},
 	},
 
 	route{
 		Pattern:     "/books/{id}",
 		Function:    DeleteBook,
 		Method:      "DELETE",
+		Role:        RoleLibrarian,
 		Description: "DELETE /book/{id} will remove the given book if it exists",
+		Spec: &routeSpec{
+			Parameters: []param{ifMatchParam},
+			Responses:  responses{202: nil, 400: apiError{}, 404: apiError{}, 409: apiError{}, 412: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}",
+		Function:    PatchBook,
+		Method:      "PATCH",
+		Role:        RoleLibrarian,
+		Description: "PATCH /books/{id} will apply a JSON Merge Patch or a JSON Patch to a specific book",
+		Spec: &routeSpec{
+			Parameters: []param{ifMatchParam},
+			Body:       mediaTypes{mergePatchType, jsonPatchType},
+			Responses:  responses{200: model.Book{}, 400: apiError{}, 404: apiError{}, 409: apiError{}, 412: apiError{}, 415: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/checkout",
+		Function:    CheckoutBook,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/checkout will lend the book to the given patron",
+		Spec: &routeSpec{
+			Body:      patronRequest{},
+			Responses: responses{201: model.Loan{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/return",
+		Function:    ReturnBook,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/return will end the book's active loan",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Loan{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/renew",
+		Function:    RenewBook,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/renew will push back the due date of the book's active loan",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Loan{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/lost",
+		Function:    ReportLostBook,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/lost will end the book's active loan and charge the patron for losing it",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Loan{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/found",
+		Function:    FoundBook,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/found will check a lost book back in, and refund the patron who lost it when credit is true",
+		Spec: &routeSpec{
+			Parameters: []param{{Name: "credit", Type: "boolean", Description: "refund the lost item charge to the patron who lost the book"}},
+			Responses:  responses{200: model.Book{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/loans",
+		Function:    GetBookLoans,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/books/{id}/loans will return every loan of the book, current and past",
+		Spec: &routeSpec{
+			Responses: responses{200: []model.Loan{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/loans/overdue",
+		Function:    GetOverdueLoans,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/loans/overdue will return every active loan that's past its due date",
+		Spec: &routeSpec{
+			Responses: responses{200: []model.Loan{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/holds",
+		Function:    GetHolds,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/books/{id}/holds will return the book's holds queue in order",
+		Spec: &routeSpec{
+			Responses: responses{200: []model.Hold{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/holds",
+		Function:    PostHold,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /books/{id}/holds will put the given patron at the end of the book's holds queue",
+		Spec: &routeSpec{
+			Body:      patronRequest{},
+			Responses: responses{201: model.Hold{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/holds/{holdID}",
+		Function:    GetHold,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/books/{id}/holds/{holdID} will return a hold and its position in the queue",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Hold{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/books/{id}/holds/{holdID}",
+		Function:    DeleteHold,
+		Method:      "DELETE",
+		Role:        RoleLibrarian,
+		Description: "DELETE /books/{id}/holds/{holdID} will cancel the hold",
+		Spec: &routeSpec{
+			Responses: responses{202: nil, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons",
+		Function:    GetPatrons,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/patrons will print out all of the patrons",
+		Spec: &routeSpec{
+			Responses: responses{200: []model.Patron{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons",
+		Function:    PostPatron,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /patrons will create a new patron",
+		Spec: &routeSpec{
+			Body:      model.Patron{},
+			Responses: responses{201: model.Patron{}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons/{id}",
+		Function:    GetPatronByID,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/patrons/{id} will return a specific patron by their id",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Patron{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons/{id}/loans",
+		Function:    GetPatronLoans,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/patrons/{id}/loans will return every loan to the patron, current and past",
+		Spec: &routeSpec{
+			Responses: responses{200: []model.Loan{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons/{id}/ledger",
+		Function:    GetLedger,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/patrons/{id}/ledger will return the patron's charges, credits and balance",
+		Spec: &routeSpec{
+			Responses: responses{200: model.Ledger{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons/{id}/payments",
+		Function:    PostPayment,
+		Method:      "POST",
+		Role:        RoleLibrarian,
+		Description: "POST /patrons/{id}/payments will credit a payment to the patron's ledger",
+		Spec: &routeSpec{
+			Body:      creditRequest{},
+			Responses: responses{201: model.LedgerEntry{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/patrons/{id}/waivers",
+		Function:    PostWaiver,
+		Method:      "POST",
+		Role:        RoleAdmin,
+		Description: "POST /patrons/{id}/waivers will credit a waiver to the patron's ledger",
+		Spec: &routeSpec{
+			Body:      creditRequest{},
+			Responses: responses{201: model.LedgerEntry{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/stats/circulation",
+		Function:    GetCirculationStats,
+		Method:      "GET",
+		Role:        RoleLibrarian,
+		Description: "/stats/circulation will return the number of checkouts by book, author and month",
+		Spec: &routeSpec{
+			Parameters: []param{
+				{Name: "since", Description: "only count checkouts on or after this date, like 2018-01-02 or 2018-01-02T15:04:05Z"},
+				{Name: "until", Description: "only count checkouts on or before this date, a date without a time includes the whole day, like 2018-01-02 or 2018-01-02T15:04:05Z"},
+			},
+			Responses: responses{200: managers.CirculationStats{}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds",
+		Function:    GetOPDSCatalog,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds will return the start of the OPDS catalog for e-reader apps",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{opdsNavigationType}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/books",
+		Function:    GetOPDSBooks,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/books will return the OPDS acquisition feed of books, optionally by author or publisher",
+		Spec: &routeSpec{
+			Parameters: []param{
+				{Name: "author", Description: "only books by this author, ignoring case"},
+				{Name: "publisher", Description: "only books from this publisher, ignoring case"},
+				opdsPageParam,
+			},
+			Responses: responses{200: mediaTypes{opdsAcquisitionType}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/books/{id}",
+		Function:    GetOPDSBook,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/books/{id} will return the OPDS entry for a specific book",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{opdsEntryType}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/authors",
+		Function:    GetOPDSAuthors,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/authors will return the OPDS navigation feed of authors",
+		Spec: &routeSpec{
+			Parameters: []param{opdsPageParam},
+			Responses:  responses{200: mediaTypes{opdsNavigationType}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/publishers",
+		Function:    GetOPDSPublishers,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/publishers will return the OPDS navigation feed of publishers",
+		Spec: &routeSpec{
+			Parameters: []param{opdsPageParam},
+			Responses:  responses{200: mediaTypes{opdsNavigationType}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/search",
+		Function:    SearchOPDS,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/search?q= will return the OPDS acquisition feed of the books matching the query",
+		Spec: &routeSpec{
+			Parameters: []param{{Name: "q", Required: true, Description: "the words to search for"}, opdsPageParam},
+			Responses:  responses{200: mediaTypes{opdsAcquisitionType}, 400: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/opds/opensearch.xml",
+		Function:    GetOpenSearchDescription,
+		Method:      "GET",
+		Role:        RoleReader,
+		Description: "/opds/opensearch.xml will return the OpenSearch description of the OPDS catalog",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{openSearchType}},
+		},
+	},
+
+	route{
+		Pattern:     "/admin/config",
+		Function:    GetConfig,
+		Method:      "GET",
+		Role:        RoleAdmin,
+		Description: "/admin/config will return the config the server is running with, with its secrets redacted",
+		Middleware:  []middleware{noStore},
+		Spec: &routeSpec{
+			Responses: responses{200: config.Config{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks",
+		Function:    GetWebhooks,
+		Method:      "GET",
+		Role:        RoleAdmin,
+		Description: "/webhooks will print out all of the webhooks, without their secrets",
+		Spec: &routeSpec{
+			Responses: responses{200: []managers.Webhook{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks",
+		Function:    PostWebhook,
+		Method:      "POST",
+		Role:        RoleAdmin,
+		Description: "POST /webhooks will create a new webhook that the book events are sent to",
+		Middleware:  []middleware{noStore},
+		Spec: &routeSpec{
+			Body:      webhookRequest{},
+			Responses: responses{201: managers.Webhook{}, 400: apiError{}},
+		},
+	},
+
+	// this has to come before /webhooks/{id} so that dead-letters isn't
+	// taken as an id
+	route{
+		Pattern:     "/webhooks/dead-letters",
+		Function:    GetDeadLetters,
+		Method:      "GET",
+		Role:        RoleAdmin,
+		Description: "/webhooks/dead-letters will return the deliveries that ran out of attempts",
+		Spec: &routeSpec{
+			Responses: responses{200: []managers.Delivery{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks/dead-letters/{id}/retry",
+		Function:    RetryDeadLetter,
+		Method:      "POST",
+		Role:        RoleAdmin,
+		Description: "POST /webhooks/dead-letters/{id}/retry will send a dead delivery again",
+		Spec: &routeSpec{
+			Responses: responses{202: managers.Delivery{}, 400: apiError{}, 404: apiError{}, 409: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks/{id}",
+		Function:    GetWebhookByID,
+		Method:      "GET",
+		Role:        RoleAdmin,
+		Description: "/webhooks/{id} will return a specific webhook, without its secret",
+		Spec: &routeSpec{
+			Responses: responses{200: managers.Webhook{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks/{id}",
+		Function:    DeleteWebhook,
+		Method:      "DELETE",
+		Role:        RoleAdmin,
+		Description: "DELETE /webhooks/{id} will stop sending events to the webhook",
+		Spec: &routeSpec{
+			Responses: responses{202: nil, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/webhooks/{id}/deliveries",
+		Function:    GetWebhookDeliveries,
+		Method:      "GET",
+		Role:        RoleAdmin,
+		Description: "/webhooks/{id}/deliveries will return the webhook's delivery log, newest first",
+		Spec: &routeSpec{
+			Responses: responses{200: []managers.Delivery{}, 400: apiError{}, 404: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/healthz",
+		Function:    GetHealthz,
+		Method:      "GET",
+		Role:        RolePublic,
+		Probe:       true,
+		Description: "/healthz will succeed as long as the server is running, it's the liveness probe",
+		Spec: &routeSpec{
+			Responses: responses{200: health{}},
+		},
+	},
+
+	route{
+		Pattern:     "/readyz",
+		Function:    GetReadyz,
+		Method:      "GET",
+		Role:        RolePublic,
+		Probe:       true,
+		Description: "/readyz will succeed once the server has started, until it starts shutting down, while the book store is available, it's the readiness probe",
+		Spec: &routeSpec{
+			Responses: responses{200: health{}, 503: apiError{}},
+		},
+	},
+
+	route{
+		Pattern:     "/metrics",
+		Function:    GetMetrics,
+		Method:      "GET",
+		Role:        RolePublic,
+		Probe:       true,
+		Description: "/metrics will return the api's metrics for Prometheus to scrape",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{"text/plain"}},
+		},
+	},
+
+	route{
+		Pattern:     "/openapi.json",
+		Function:    GetOpenAPI,
+		Method:      "GET",
+		Role:        RolePublic,
+		Description: "/openapi.json will return the OpenAPI document describing every route",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{"application/json"}},
+		},
+	},
+
+	route{
+		Pattern:     "/docs",
+		Function:    GetDocs,
+		Method:      "GET",
+		Role:        RolePublic,
+		Description: "/docs will return a page documenting every route",
+		Spec: &routeSpec{
+			Responses: responses{200: mediaTypes{"text/html"}},
+		},
 	},
 }
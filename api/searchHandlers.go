@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/askewseth/kubernetes/managers"
+	log "github.com/sirupsen/logrus"
+)
+
+// SearchBooks is the handler for the GET /books/search api call,
+// it returns the books matching the q query parameter ranked by relevance
+func SearchBooks(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	query := values.Get("q")
+	if query == "" {
+		writeJSONFail(w, http.StatusBadRequest, "The q query parameter is required")
+		return
+	}
+
+	limit, err := parseCount(values, "limit")
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, err := parseCount(values, "offset")
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hits := managers.GetSearchIndex().Search(query)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(hits)))
+
+	// cut out the requested page
+	if offset > len(hits) {
+		offset = len(hits)
+	}
+	hits = hits[offset:]
+	if limit > 0 && limit < len(hits) {
+		hits = hits[:limit]
+	}
+
+	err = writeJSONSuccess(w, hits, http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
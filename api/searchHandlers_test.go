@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestSearchBooks(t *testing.T) {
+	defer cleanLibrary()
+
+	library := managers.GetLibrary()
+	for _, title := range []string{"Dragon Rider", "Dragons of Autumn", "Autumn Leaves"} {
+		book := model.NewBook()
+		book.Title = title
+		library.AddBook(book)
+	}
+
+	res, err := sendRequest("/books/search?q=dragon&limit=1", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/search: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status 200 from GET /books/search, got %v", res.Status)
+	}
+
+	var hits []map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&hits)
+	if err != nil {
+		t.Errorf("Error trying to read the body from GET /books/search: %v", err)
+		t.FailNow()
+	}
+
+	if len(hits) != 1 || res.Header.Get("X-Total-Count") != "2" {
+		t.Errorf("Expected the first of 2 hits, got %v of %v", hits, res.Header.Get("X-Total-Count"))
+	}
+}
+
+func TestSearchBooksNoQuery(t *testing.T) {
+	defer cleanLibrary()
+
+	res, err := sendRequest("/books/search", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /books/search: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from GET /books/search without a query, got %v", res.Status)
+	}
+}
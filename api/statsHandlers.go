@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+// GetCirculationStats is the handler for the GET /stats/circulation api call,
+// it returns the number of checkouts by book, by author and by month, limited
+// to the checkouts between the since and until query parameters if given
+func GetCirculationStats(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	since, err := parseDate(values, "since")
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	until, err := parseEndDate(values, "until")
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := managers.GetCirculation().CirculationStats(since, until)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, stats, http.StatusOK)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+)
+
+func TestGetLoanHistory(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+	managers.GetCirculation().Return(book.ID)
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	for _, url := range []string{"/books/" + book.ID.String() + "/loans", "/patrons/" + patron.ID.String() + "/loans"} {
+		res, err := sendRequest(url, "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET %v: %v", url, err)
+			t.FailNow()
+		}
+
+		var loans []map[string]interface{}
+		err = json.NewDecoder(res.Body).Decode(&loans)
+		if err != nil || res.StatusCode != 200 {
+			t.Errorf("Error getting GET %v: %v %v", url, res.Status, err)
+			t.FailNow()
+		}
+
+		if len(loans) != 2 || loans[0]["returned_at"] == nil || loans[1]["returned_at"] != nil {
+			t.Errorf("Expected the returned loan then the active loan from GET %v, got %v", url, loans)
+		}
+	}
+
+	res, err := sendRequest("/patrons/"+book.ID.String()+"/loans", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /patrons/{id}/loans: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status 404 from GET /patrons/{id}/loans with an unknown patron, got %v", res.Status)
+	}
+}
+
+func TestGetCirculationStats(t *testing.T) {
+	defer cleanLibrary()
+
+	patron, book := addTestPatronAndBook()
+	managers.GetCirculation().Checkout(book.ID, patron.ID)
+
+	res, err := sendRequest("/stats/circulation", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /stats/circulation: %v", err)
+		t.FailNow()
+	}
+
+	var stats managers.CirculationStats
+	err = json.NewDecoder(res.Body).Decode(&stats)
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("Error getting GET /stats/circulation: %v %v", res.Status, err)
+		t.FailNow()
+	}
+
+	if len(stats.ByBook) != 1 || stats.ByBook[0].BookID != book.ID || stats.ByBook[0].Checkouts != 1 || len(stats.ByMonth) != 1 {
+		t.Errorf("Expected the one checkout from GET /stats/circulation, got %+v", stats)
+	}
+
+	// a date without a time includes the whole of that day
+	res, err = sendRequest("/stats/circulation?until="+time.Now().UTC().Format("2006-01-02"), "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /stats/circulation: %v", err)
+		t.FailNow()
+	}
+
+	stats = managers.CirculationStats{}
+	json.NewDecoder(res.Body).Decode(&stats)
+	if len(stats.ByBook) != 1 {
+		t.Errorf("Expected today's checkout from GET /stats/circulation?until=today, got %+v", stats)
+	}
+
+	res, err = sendRequest("/stats/circulation?since=yesterday", "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /stats/circulation: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from GET /stats/circulation with a bad date, got %v", res.Status)
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/askewseth/kubernetes/managers"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// webhookRequest is the body of a POST /webhooks request
+type webhookRequest struct {
+	URL string `json:"url"`
+
+	// Events are the types of event to send, all of them if it's empty
+	Events []managers.EventType `json:"events,omitempty"`
+
+	// Secret signs the deliveries, one is made up if it's empty
+	Secret string `json:"secret,omitempty"`
+}
+
+// GetWebhooks is the handler for the GET /webhooks api call,
+// it returns a list of all of the webhooks, without their secrets
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	err := writeJSONSuccess(w, managers.GetWebhooks().List(), http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// PostWebhook is the handler for the POST /webhooks api call,
+// it will create a new webhook and return it, this is the only time its
+// secret is returned
+func PostWebhook(w http.ResponseWriter, r *http.Request) {
+	var request webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, "The Post Body was invalid")
+		return
+	}
+	defer r.Body.Close()
+
+	hook, err := managers.GetWebhooks().Add(managers.Webhook{
+		URL:    request.URL,
+		Events: request.Events,
+		Secret: request.Secret,
+	})
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, hook, http.StatusCreated)
+}
+
+// GetWebhookByID is the handler for the GET /webhooks/{id} api call,
+// it will return a specific webhook, without its secret
+func GetWebhookByID(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	hook, err := managers.GetWebhooks().Get(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, hook, http.StatusOK)
+}
+
+// DeleteWebhook is the handler for the DELETE /webhooks/{id} api call,
+// it stops sending events to the webhook
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	err = managers.GetWebhooks().Remove(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, "", http.StatusAccepted)
+}
+
+// GetWebhookDeliveries is the handler for the GET /webhooks/{id}/deliveries
+// api call, it returns the webhook's delivery log, newest first
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	deliveries, err := managers.GetWebhooks().Deliveries(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, deliveries, http.StatusOK)
+}
+
+// GetDeadLetters is the handler for the GET /webhooks/dead-letters api call,
+// it returns the deliveries that ran out of attempts, newest first
+func GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	err := writeJSONSuccess(w, managers.GetWebhooks().DeadLetters(), http.StatusOK)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// RetryDeadLetter is the handler for the POST
+// /webhooks/dead-letters/{id}/retry api call, it takes the delivery out of
+// the dead letters and sends it again
+func RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	parameters := mux.Vars(r)
+	id, err := uuid.FromString(parameters["id"])
+	if err != nil {
+		writeJSONFail(w, http.StatusBadRequest, ErrInvalidUUID.Error())
+		return
+	}
+
+	delivery, err := managers.GetWebhooks().Retry(id)
+	if err != nil {
+		writeManagerFail(w, err)
+		return
+	}
+
+	writeJSONSuccess(w, delivery, http.StatusAccepted)
+}
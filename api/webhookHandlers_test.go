@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	uuid "github.com/satori/go.uuid"
+)
+
+// useTestWebhooks replaces the global webhooks with ones that retry quickly,
+// the returned function stops them
+func useTestWebhooks() func() {
+	webhooks := managers.NewWebhooks()
+	// the receivers are httptest servers on the loopback, which the default
+	// client refuses to send to
+	webhooks.Client = &http.Client{}
+	webhooks.Policy = managers.WebhookPolicy{MaxAttempts: 2, Backoff: 5 * time.Millisecond, MaxBackoff: 5 * time.Millisecond, Timeout: time.Second}
+	webhooks.Start(managers.GetEvents())
+	managers.SetWebhooks(webhooks)
+
+	return func() {
+		webhooks.Stop(context.Background())
+	}
+}
+
+// getDeliveries polls GET /webhooks/{id}/deliveries until there are count
+// deliveries with the status
+func getDeliveries(t *testing.T, id string, count int, status managers.DeliveryStatus) []managers.Delivery {
+	var deliveries []managers.Delivery
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		res, err := sendRequest("/webhooks/"+id+"/deliveries", "GET", "")
+		if err != nil {
+			t.Errorf("Got error when sending request for GET /webhooks/{id}/deliveries: %v", err)
+			t.FailNow()
+		}
+
+		deliveries = nil
+		err = json.NewDecoder(res.Body).Decode(&deliveries)
+		res.Body.Close()
+		if err != nil {
+			t.Errorf("Error trying to read the body from GET /webhooks/{id}/deliveries: %v", err)
+			t.FailNow()
+		}
+
+		if len(deliveries) == count && (count == 0 || deliveries[0].Status == status) {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Errorf("Expected %v deliveries that are %v, got %+v", count, status, deliveries)
+	t.FailNow()
+	return nil
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	defer cleanLibrary()
+	defer useTestWebhooks()()
+
+	var fail int32 = 1
+	got := make(chan http.Header, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		got <- r.Header
+	}))
+	defer receiver.Close()
+
+	res, err := sendRequest("/webhooks", "POST", `{"url": "`+receiver.URL+`", "events": ["created"]}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /webhooks: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 201 {
+		t.Errorf("Expected status 201 from POST /webhooks, got %v", res.Status)
+		t.FailNow()
+	}
+
+	var hook managers.Webhook
+	json.NewDecoder(res.Body).Decode(&hook)
+	if hook.Secret == "" {
+		t.Errorf("Expected POST /webhooks to return the new webhook's secret")
+	}
+
+	sendRequest("/books", "POST", `{"title": "MyBook", "rating": 3}`)
+	deliveries := getDeliveries(t, hook.ID.String(), 1, managers.DeliveryDead)
+	if len(deliveries[0].Attempts) != 2 || deliveries[0].Attempts[1].StatusCode != 503 {
+		t.Errorf("Expected the delivery to fail twice with a 503, got %+v", deliveries[0].Attempts)
+	}
+
+	res, _ = sendRequest("/webhooks/dead-letters", "GET", "")
+	var dead []managers.Delivery
+	json.NewDecoder(res.Body).Decode(&dead)
+	if len(dead) != 1 || dead[0].ID != deliveries[0].ID {
+		t.Errorf("Expected the delivery to be in the dead letters, got %+v", dead)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	res, err = sendRequest("/webhooks/dead-letters/"+deliveries[0].ID.String()+"/retry", "POST", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /webhooks/dead-letters/{id}/retry: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 202 {
+		t.Errorf("Expected status 202 from POST /webhooks/dead-letters/{id}/retry, got %v", res.Status)
+	}
+
+	getDeliveries(t, hook.ID.String(), 1, managers.DeliverySucceeded)
+	headers := <-got
+	if headers.Get(managers.WebhookEventHeader) != "created" || headers.Get(managers.WebhookSignatureHeader) == "" {
+		t.Errorf("Expected a signed created event, got the headers %v", headers)
+	}
+
+	res, _ = sendRequest("/webhooks/dead-letters/"+deliveries[0].ID.String()+"/retry", "POST", "")
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status 409 retrying a delivery that isn't dead, got %v", res.Status)
+	}
+}
+
+func TestPostWebhookBadURL(t *testing.T) {
+	defer useTestWebhooks()()
+
+	res, err := sendRequest("/webhooks", "POST", `{"url": "not a url"}`)
+	if err != nil {
+		t.Errorf("Got error when sending request for POST /webhooks: %v", err)
+		t.FailNow()
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status 400 from POST /webhooks with a bad url, got %v", res.Status)
+	}
+}
+
+func TestGetAndDeleteWebhook(t *testing.T) {
+	defer useTestWebhooks()()
+
+	hook, _ := managers.GetWebhooks().Add(managers.Webhook{URL: "https://example.com/hook"})
+
+	res, err := sendRequest("/webhooks/"+hook.ID.String(), "GET", "")
+	if err != nil {
+		t.Errorf("Got error when sending request for GET /webhooks/{id}: %v", err)
+		t.FailNow()
+	}
+
+	var got map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&got)
+	if res.StatusCode != 200 || got["secret"] != nil {
+		t.Errorf("Expected status 200 and no secret from GET /webhooks/{id}, got %v %v", res.Status, got)
+	}
+
+	res, _ = sendRequest("/webhooks/"+hook.ID.String(), "DELETE", "")
+	if res.StatusCode != 202 {
+		t.Errorf("Expected status 202 from DELETE /webhooks/{id}, got %v", res.Status)
+	}
+
+	id, _ := uuid.NewV4()
+	for _, path := range []string{"/webhooks/" + hook.ID.String(), "/webhooks/" + id.String() + "/deliveries"} {
+		res, _ = sendRequest(path, "GET", "")
+		if res.StatusCode != 404 {
+			t.Errorf("Expected status 404 from GET %v, got %v", path, res.Status)
+		}
+	}
+}
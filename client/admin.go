@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Config returns the config the server is running with, with its secrets
+// redacted. It's returned as json, which can be decoded into a config.Config,
+// so that the client doesn't import the server's config package
+func (c *Client) Config(ctx context.Context) (json.RawMessage, error) {
+	var cfg json.RawMessage
+	_, err := c.get(ctx, "/admin/config", nil, &cfg)
+
+	return cfg, err
+}
+
+// Webhooks returns every webhook, without their secrets
+func (c *Client) Webhooks(ctx context.Context) ([]model.Webhook, error) {
+	var hooks []model.Webhook
+	_, err := c.get(ctx, "/webhooks", nil, &hooks)
+
+	return hooks, err
+}
+
+// CreateWebhook registers a url that the events of the given types, or all
+// of them if there aren't any, are sent to. It returns the webhook with its
+// secret, which is made up if the webhook doesn't have one and isn't
+// returned by any other method
+func (c *Client) CreateWebhook(ctx context.Context, hook model.Webhook) (model.Webhook, error) {
+	body := struct {
+		URL    string            `json:"url"`
+		Events []model.EventType `json:"events,omitempty"`
+		Secret string            `json:"secret,omitempty"`
+	}{hook.URL, hook.Events, hook.Secret}
+
+	var created model.Webhook
+	err := c.sendJSON(ctx, "POST", "/webhooks", body, &created)
+
+	return created, err
+}
+
+// GetWebhook returns the webhook with the given id, without its secret
+func (c *Client) GetWebhook(ctx context.Context, id uuid.UUID) (model.Webhook, error) {
+	var hook model.Webhook
+	_, err := c.get(ctx, "/webhooks/"+id.String(), nil, &hook)
+
+	return hook, err
+}
+
+// DeleteWebhook stops sending events to the webhook
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := c.call(ctx, request{method: "DELETE", path: "/webhooks/" + id.String()}, nil)
+	return err
+}
+
+// WebhookDeliveries returns the webhook's delivery log, newest first
+func (c *Client) WebhookDeliveries(ctx context.Context, id uuid.UUID) ([]model.Delivery, error) {
+	var deliveries []model.Delivery
+	_, err := c.get(ctx, "/webhooks/"+id.String()+"/deliveries", nil, &deliveries)
+
+	return deliveries, err
+}
+
+// DeadLetters returns the deliveries that ran out of attempts, newest first
+func (c *Client) DeadLetters(ctx context.Context) ([]model.Delivery, error) {
+	var deliveries []model.Delivery
+	_, err := c.get(ctx, "/webhooks/dead-letters", nil, &deliveries)
+
+	return deliveries, err
+}
+
+// RetryDeadLetter takes the delivery out of the dead letters and sends it
+// again
+func (c *Client) RetryDeadLetter(ctx context.Context, id uuid.UUID) (model.Delivery, error) {
+	var delivery model.Delivery
+	_, err := c.call(ctx, request{method: "POST", path: "/webhooks/dead-letters/" + id.String() + "/retry"}, &delivery)
+
+	return delivery, err
+}
+
+// Healthz returns nil as long as the server is running
+func (c *Client) Healthz(ctx context.Context) error {
+	_, err := c.get(ctx, "/healthz", nil, nil)
+	return err
+}
+
+// Readyz returns nil once the server has loaded the books and can reach
+// its book store
+func (c *Client) Readyz(ctx context.Context) error {
+	_, err := c.get(ctx, "/readyz", nil, nil)
+	return err
+}
+
+// Metrics returns the server's metrics in the Prometheus exposition format
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	return c.getRaw(ctx, "/metrics", nil)
+}
+
+// OpenAPI returns the OpenAPI document of every route
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	return c.getRaw(ctx, "/openapi.json", nil)
+}
+
+// Docs returns the html page documenting every route
+func (c *Client) Docs(ctx context.Context) ([]byte, error) {
+	return c.getRaw(ctx, "/docs", nil)
+}
+
+// opdsPage returns the page query parameter of an OPDS feed, the first page
+// if it's 0
+func opdsPage(page int) url.Values {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+
+	return query
+}
+
+// OPDSCatalog returns the start of the OPDS catalog as an Atom feed
+func (c *Client) OPDSCatalog(ctx context.Context) ([]byte, error) {
+	return c.getRaw(ctx, "/opds", nil)
+}
+
+// OPDSBooks returns a page of the OPDS acquisition feed of the books, only
+// those by the author or from the publisher if they're given
+func (c *Client) OPDSBooks(ctx context.Context, author, publisher string, page int) ([]byte, error) {
+	query := opdsPage(page)
+	if author != "" {
+		query.Set("author", author)
+	}
+	if publisher != "" {
+		query.Set("publisher", publisher)
+	}
+
+	return c.getRaw(ctx, "/opds/books", query)
+}
+
+// OPDSBook returns the OPDS entry for a specific book
+func (c *Client) OPDSBook(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	return c.getRaw(ctx, "/opds/books/"+id.String(), nil)
+}
+
+// OPDSAuthors returns a page of the OPDS navigation feed of authors
+func (c *Client) OPDSAuthors(ctx context.Context, page int) ([]byte, error) {
+	return c.getRaw(ctx, "/opds/authors", opdsPage(page))
+}
+
+// OPDSPublishers returns a page of the OPDS navigation feed of publishers
+func (c *Client) OPDSPublishers(ctx context.Context, page int) ([]byte, error) {
+	return c.getRaw(ctx, "/opds/publishers", opdsPage(page))
+}
+
+// OPDSSearch returns a page of the OPDS acquisition feed of the books
+// matching the words
+func (c *Client) OPDSSearch(ctx context.Context, q string, page int) ([]byte, error) {
+	query := opdsPage(page)
+	query.Set("q", q)
+
+	return c.getRaw(ctx, "/opds/search", query)
+}
+
+// OpenSearchDescription returns the OpenSearch description of the OPDS
+// catalog
+func (c *Client) OpenSearchDescription(ctx context.Context) ([]byte, error) {
+	return c.getRaw(ctx, "/opds/opensearch.xml", nil)
+}
@@ -0,0 +1,463 @@
+package client
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// DefaultPageSize is how many results the iterators get at a time when
+// their query doesn't have a limit
+const DefaultPageSize = 100
+
+// the media types a book can be patched with
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// ExportFormat is a format the books can be exported in
+type ExportFormat string
+
+// this const block holds the ExportFormat values, each is the extension of
+// its export route
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportMARC    ExportFormat = "mrc"
+	ExportMARCXML ExportFormat = "xml"
+)
+
+// PatchOperation is a single operation of a JSON Patch
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// BatchMode is how a batch is applied
+type BatchMode string
+
+// this const block holds the BatchMode values, an atomic batch either
+// applies every operation or none of them, while a best effort one applies
+// every operation that it can
+const (
+	BatchAtomic     BatchMode = "atomic"
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// BatchItem is the result of a single operation in a batch, Status is the
+// status code the operation would have had as its own request
+type BatchItem struct {
+	Index  int           `json:"index"`
+	Op     model.BatchOp `json:"op"`
+	ID     uuid.UUID     `json:"id"`
+	Status int           `json:"status"`
+	Book   *model.Book   `json:"book,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// BatchResponse is the result of a batch
+type BatchResponse struct {
+	Mode    BatchMode   `json:"mode"`
+	Applied int         `json:"applied"`
+	Failed  int         `json:"failed"`
+	Results []BatchItem `json:"results"`
+}
+
+// ImportFormat is a format books can be imported from, the api works it
+// out from the content type when it's empty
+type ImportFormat string
+
+// this const block holds the ImportFormat values
+const (
+	ImportCSV     ImportFormat = "csv"
+	ImportMARC    ImportFormat = "marc"
+	ImportMARCXML ImportFormat = "marcxml"
+)
+
+// importTypes are the content types each import format is uploaded with
+var importTypes = map[ImportFormat]string{
+	ImportCSV:     "text/csv",
+	ImportMARC:    "application/marc",
+	ImportMARCXML: "application/marcxml+xml",
+}
+
+// bookPath returns the path of a book, or one of its routes
+func bookPath(id uuid.UUID, route ...string) string {
+	path := "/books/" + id.String()
+	for _, r := range route {
+		path += "/" + r
+	}
+
+	return path
+}
+
+// formatDate formats a time the way the api's query parameters take it
+func formatDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// bookQueryValues returns the query parameters for a BookQuery
+func bookQueryValues(q model.BookQuery) url.Values {
+	values := url.Values{}
+
+	set := func(name, value string) {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	set("author", q.Author)
+	set("publisher", q.Publisher)
+	set("sort", q.SortBy)
+	if q.Status != nil {
+		set("status", q.Status.String())
+	}
+	if q.MinRating != 0 {
+		set("min_rating", strconv.Itoa(int(q.MinRating)))
+	}
+	if q.MaxRating != 0 {
+		set("max_rating", strconv.Itoa(int(q.MaxRating)))
+	}
+	if q.PublishedAfter != nil {
+		set("published_after", formatDate(*q.PublishedAfter))
+	}
+	if q.PublishedBefore != nil {
+		set("published_before", formatDate(*q.PublishedBefore))
+	}
+	if q.Descending {
+		set("order", "desc")
+	}
+	if q.Limit != 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset != 0 {
+		set("offset", strconv.Itoa(q.Offset))
+	}
+
+	return values
+}
+
+// revisionHeader returns the If-Match header for a revision of a book, or
+// no header if the revision is 0
+func revisionHeader(revision uint64) http.Header {
+	if revision == 0 {
+		return nil
+	}
+
+	return http.Header{"If-Match": []string{`"` + strconv.FormatUint(revision, 10) + `"`}}
+}
+
+// ListBooks returns a page of the books matching the query, and how many
+// books match it in total
+func (c *Client) ListBooks(ctx context.Context, q model.BookQuery) ([]model.Book, int, error) {
+	var books []model.Book
+	header, err := c.get(ctx, "/books", bookQueryValues(q), &books)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return books, totalCount(header), nil
+}
+
+// Books returns an iterator over every book matching the query, starting
+// from its offset, getting its limit, or DefaultPageSize, at a time
+func (c *Client) Books(ctx context.Context, q model.BookQuery) *BookIterator {
+	if q.Limit == 0 {
+		q.Limit = DefaultPageSize
+	}
+
+	it := &BookIterator{}
+	it.pager = newPager(q.Limit, q.Offset, func(limit, offset int) (int, int, error) {
+		q.Limit, q.Offset = limit, offset
+
+		var total int
+		var err error
+		it.books, total, err = c.ListBooks(ctx, q)
+		return len(it.books), total, err
+	})
+
+	return it
+}
+
+// CreateBook adds a new book to the library and returns it as it was
+// stored, the api always gives it a new id so any id the book has is ignored
+func (c *Client) CreateBook(ctx context.Context, book model.Book) (model.Book, error) {
+	var created model.Book
+	err := c.sendJSON(ctx, "POST", "/books", book, &created)
+
+	return created, err
+}
+
+// GetBook returns the book with the given id
+func (c *Client) GetBook(ctx context.Context, id uuid.UUID) (model.Book, error) {
+	var book model.Book
+	_, err := c.get(ctx, bookPath(id), nil, &book)
+
+	return book, err
+}
+
+// GetBookIfChanged returns the book with the given id if it's been changed
+// since the revision, and false if it hasn't
+func (c *Client) GetBookIfChanged(ctx context.Context, id uuid.UUID, revision uint64) (model.Book, bool, error) {
+	r := request{method: "GET", path: bookPath(id), header: http.Header{
+		"If-None-Match": []string{`"` + strconv.FormatUint(revision, 10) + `"`},
+	}}
+
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return model.Book{}, false, err
+	}
+	defer res.Body.Close()
+
+	var book model.Book
+	err = decodeBody(res, r, &book)
+	return book, err == nil && res.StatusCode != http.StatusNotModified, err
+}
+
+// UpdateBook replaces the book with the same id, the status and revision
+// can't be changed and are ignored. If the book's revision isn't 0 it's only
+// replaced if it hasn't been changed since that revision, otherwise
+// ErrPreconditionFailed is returned
+func (c *Client) UpdateBook(ctx context.Context, book model.Book) error {
+	r, err := jsonRequest("PUT", bookPath(book.ID), book)
+	if err != nil {
+		return err
+	}
+	r.header = revisionHeader(book.Revision)
+
+	_, err = c.call(ctx, r, nil)
+	return err
+}
+
+// PatchBook applies a JSON Merge Patch to the book and returns the patched
+// book, the patch can be anything that marshals to a json object. If the
+// revision isn't 0 the patch is only applied if the book hasn't been changed
+// since that revision
+func (c *Client) PatchBook(ctx context.Context, id uuid.UUID, patch interface{}, revision uint64) (model.Book, error) {
+	return c.patchBook(ctx, id, MergePatchType, patch, revision)
+}
+
+// JSONPatchBook applies a JSON Patch to the book and returns the patched
+// book, just like PatchBook
+func (c *Client) JSONPatchBook(ctx context.Context, id uuid.UUID, operations []PatchOperation, revision uint64) (model.Book, error) {
+	return c.patchBook(ctx, id, JSONPatchType, operations, revision)
+}
+
+// patchBook sends a patch of the given media type
+func (c *Client) patchBook(ctx context.Context, id uuid.UUID, contentType string, patch interface{}, revision uint64) (model.Book, error) {
+	var book model.Book
+
+	r, err := jsonRequest("PATCH", bookPath(id), patch)
+	if err != nil {
+		return book, err
+	}
+	r.contentType = contentType
+	r.header = revisionHeader(revision)
+
+	_, err = c.call(ctx, r, &book)
+	return book, err
+}
+
+// DeleteBook removes the book, if the revision isn't 0 it's only removed if
+// it hasn't been changed since that revision
+func (c *Client) DeleteBook(ctx context.Context, id uuid.UUID, revision uint64) error {
+	_, err := c.call(ctx, request{method: "DELETE", path: bookPath(id), header: revisionHeader(revision)}, nil)
+	return err
+}
+
+// Batch creates, updates and deletes many books at once. A batch with
+// operations that failed isn't an error, the response has the result of
+// every operation
+func (c *Client) Batch(ctx context.Context, mode BatchMode, operations []model.BatchOperation) (BatchResponse, error) {
+	body := struct {
+		Mode       BatchMode              `json:"mode"`
+		Operations []model.BatchOperation `json:"operations"`
+	}{mode, operations}
+
+	var response BatchResponse
+	r, err := jsonRequest("POST", "/books/batch", body)
+	if err != nil {
+		return response, err
+	}
+
+	// atomic batches with failures still have a result for each operation
+	r.accept = http.StatusUnprocessableEntity
+
+	_, err = c.call(ctx, r, &response)
+	return response, err
+}
+
+// ExportBooks returns the books matching the query in the format, the
+// limit and offset of the query are ignored. The caller has to close it
+func (c *Client) ExportBooks(ctx context.Context, format ExportFormat, q model.BookQuery) (io.ReadCloser, error) {
+	res, err := c.do(ctx, request{method: "GET", path: "/books/export." + string(format), query: bookQueryValues(q)})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// ImportBooks adds or replaces books from a catalog in the format, and
+// returns the report of the rows that were and weren't imported. An atomic
+// import that wasn't applied because some rows failed isn't an error, the
+// report says which rows failed
+func (c *Client) ImportBooks(ctx context.Context, catalog io.Reader, format ImportFormat, options model.ImportOptions) (model.ImportReport, error) {
+	var report model.ImportReport
+
+	body, err := ioutil.ReadAll(catalog)
+	if err != nil {
+		return report, err
+	}
+
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", string(format))
+	}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if options.Atomic {
+		query.Set("atomic", "true")
+	}
+	if options.DefaultRating != 0 {
+		query.Set("default_rating", strconv.Itoa(int(options.DefaultRating)))
+	}
+	for header, column := range options.Mapping {
+		query.Add("map", header+"="+column)
+	}
+
+	// atomic imports with failures still have a report
+	r := request{method: "POST", path: "/books/import", query: query, body: body, contentType: importTypes[format], accept: http.StatusUnprocessableEntity}
+
+	_, err = c.call(ctx, r, &report)
+	return report, err
+}
+
+// SearchBooks returns a page of the books matching the words ranked by
+// relevance, and how many books match in total, a limit of 0 returns all
+// of them
+func (c *Client) SearchBooks(ctx context.Context, q string, limit, offset int) ([]model.SearchHit, int, error) {
+	query := url.Values{"q": []string{q}}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset != 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	var hits []model.SearchHit
+	header, err := c.get(ctx, "/books/search", query, &hits)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hits, totalCount(header), nil
+}
+
+// Search returns an iterator over every book matching the words, ranked by
+// relevance and getting DefaultPageSize at a time
+func (c *Client) Search(ctx context.Context, q string) *SearchIterator {
+	it := &SearchIterator{}
+	it.pager = newPager(DefaultPageSize, 0, func(limit, offset int) (int, int, error) {
+		var total int
+		var err error
+		it.hits, total, err = c.SearchBooks(ctx, q, limit, offset)
+		return len(it.hits), total, err
+	})
+
+	return it
+}
+
+// pager gets the pages of a paged route one at a time as they're iterated
+// through, fetch gets the page at the offset and returns how many results
+// it had and how many there are in total
+type pager struct {
+	limit, offset int
+
+	// index is the position in the current page, which has count results
+	index, count int
+	total        int
+	done         bool
+	err          error
+
+	fetch func(limit, offset int) (count, total int, err error)
+}
+
+// newPager returns a pager that starts at the offset
+func newPager(limit, offset int, fetch func(limit, offset int) (int, int, error)) pager {
+	return pager{limit: limit, offset: offset, index: -1, fetch: fetch}
+}
+
+// Next moves on to the next result, getting the next page if it has to, and
+// returns false once there aren't any more or getting a page failed
+func (p *pager) Next() bool {
+	p.index++
+	for p.index >= p.count {
+		if p.done || p.err != nil {
+			return false
+		}
+
+		p.count, p.total, p.err = p.fetch(p.limit, p.offset)
+		if p.err != nil {
+			p.count = 0
+			return false
+		}
+
+		p.index = 0
+		p.offset += p.count
+		p.done = p.count < p.limit || p.offset >= p.total
+	}
+
+	return true
+}
+
+// Total returns how many results there are in total, once the first page
+// has been got
+func (p *pager) Total() int {
+	return p.total
+}
+
+// Err returns the error that stopped the iteration, if it was stopped by
+// one
+func (p *pager) Err() error {
+	return p.err
+}
+
+// BookIterator goes through the books matching a query, like
+//
+//	it := c.Books(ctx, query)
+//	for it.Next() {
+//		book := it.Book()
+//	}
+//	err := it.Err()
+type BookIterator struct {
+	pager
+	books []model.Book
+}
+
+// Book returns the current book
+func (it *BookIterator) Book() model.Book {
+	return it.books[it.index]
+}
+
+// SearchIterator goes through the books matching a search, just like a
+// BookIterator
+type SearchIterator struct {
+	pager
+	hits []model.SearchHit
+}
+
+// Hit returns the current book and how well it matched
+func (it *SearchIterator) Hit() model.SearchHit {
+	return it.hits[it.index]
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// addBooks adds books titled Book 00 up to the count to the global library
+func addBooks(t *testing.T, count int) {
+	library := managers.GetLibrary()
+	for i := 0; i < count; i++ {
+		book := model.NewBook()
+		book.Title = fmt.Sprintf("Book %02d", i)
+		book.Author = "Me"
+		book.Rating = 2
+
+		err := library.AddBook(book)
+		if err != nil {
+			t.Errorf("Error adding a book: %v", err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestBookRevisions(t *testing.T) {
+	defer cleanLibrary()
+	c := newClient(t, server.URL)
+	ctx := context.Background()
+
+	book, err := c.CreateBook(ctx, model.Book{Title: "MyBook", Rating: 3})
+	if err != nil {
+		t.Errorf("Error creating a book: %v", err)
+		t.FailNow()
+	}
+
+	if uuid.Equal(book.ID, uuid.Nil) || book.Revision != 1 {
+		t.Errorf("Expected the created book with its id and first revision, got %+v", book)
+	}
+
+	book, err = c.GetBook(ctx, book.ID)
+	if err != nil || book.Title != "MyBook" {
+		t.Errorf("Expected to get the book that was created, got %+v %v", book, err)
+		t.FailNow()
+	}
+
+	_, changed, err := c.GetBookIfChanged(ctx, book.ID, book.Revision)
+	if err != nil || changed {
+		t.Errorf("Expected the book not to have changed, got %v %v", changed, err)
+	}
+
+	patched, err := c.PatchBook(ctx, book.ID, map[string]interface{}{"author": "Me"}, book.Revision)
+	if err != nil || patched.Author != "Me" || patched.Revision == book.Revision {
+		t.Errorf("Expected the patch to set the author and a new revision, got %+v %v", patched, err)
+	}
+
+	// the book has been changed since it was read
+	book.Title = "Stale"
+	err = c.UpdateBook(ctx, book)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected ErrPreconditionFailed updating a stale revision, got %v", err)
+	}
+
+	_, err = c.JSONPatchBook(ctx, book.ID, []PatchOperation{{Op: "test", Path: "/title", Value: "Stale"}}, 0)
+	if !errors.Is(err, ErrPatchTestFailed) {
+		t.Errorf("Expected ErrPatchTestFailed for a test that doesn't match, got %v", err)
+	}
+
+	latest, changed, err := c.GetBookIfChanged(ctx, book.ID, book.Revision)
+	if err != nil || !changed || latest.Author != "Me" {
+		t.Errorf("Expected to get the patched book, got %+v %v %v", latest, changed, err)
+	}
+
+	err = c.DeleteBook(ctx, book.ID, latest.Revision)
+	if err != nil {
+		t.Errorf("Error deleting the book: %v", err)
+	}
+
+	_, err = c.GetBook(ctx, book.ID)
+	if !errors.Is(err, ErrNoBookWithThatID) {
+		t.Errorf("Expected ErrNoBookWithThatID after deleting the book, got %v", err)
+	}
+}
+
+func TestBooksIterator(t *testing.T) {
+	defer cleanLibrary()
+	addBooks(t, 25)
+	c := newClient(t, server.URL)
+
+	it := c.Books(context.Background(), managers.BookQuery{Author: "me", Limit: 10})
+	var titles []string
+	for it.Next() {
+		titles = append(titles, it.Book().Title)
+	}
+
+	if it.Err() != nil {
+		t.Errorf("Error iterating through the books: %v", it.Err())
+	}
+	if len(titles) != 25 || titles[0] != "Book 00" || titles[24] != "Book 24" || it.Total() != 25 {
+		t.Errorf("Expected to iterate through the 25 books in order, got %v of %v", titles, it.Total())
+	}
+
+	it = c.Books(context.Background(), managers.BookQuery{SortBy: "isbn"})
+	if it.Next() || !errors.Is(it.Err(), ErrInvalidSortField) {
+		t.Errorf("Expected ErrInvalidSortField iterating with a bad sort, got %v", it.Err())
+	}
+}
+
+func TestSearchIterator(t *testing.T) {
+	defer cleanLibrary()
+	addBooks(t, 3)
+	c := newClient(t, server.URL)
+
+	it := c.Search(context.Background(), "book")
+	count := 0
+	for it.Next() {
+		if it.Hit().Score <= 0 {
+			t.Errorf("Expected every hit to have a score, got %+v", it.Hit())
+		}
+		count++
+	}
+
+	if it.Err() != nil || count != 3 {
+		t.Errorf("Expected 3 hits, got %v %v", count, it.Err())
+	}
+}
+
+func TestBatchAndImport(t *testing.T) {
+	defer cleanLibrary()
+	c := newClient(t, server.URL)
+	ctx := context.Background()
+
+	missing := model.NewBook()
+	response, err := c.Batch(ctx, BatchAtomic, []managers.BatchOperation{
+		{Op: managers.BatchCreate, Book: model.Book{Title: "MyBook", Rating: 1}},
+		{Op: managers.BatchDelete, ID: missing.ID},
+	})
+	if err != nil || response.Applied != 0 || response.Results[1].Status != 404 {
+		t.Errorf("Expected the atomic batch to have the failed delete, got %+v %v", response, err)
+	}
+
+	report, err := c.ImportBooks(ctx, strings.NewReader("title,rating\nMyBook,2\nOther,9\n"), ImportCSV, managers.ImportOptions{Atomic: true})
+	if err != nil || report.Applied || report.Failed != 1 {
+		t.Errorf("Expected the atomic import not to be applied with a bad row, got %+v %v", report, err)
+	}
+
+	report, err = c.ImportBooks(ctx, strings.NewReader("Name,rating\nMyBook,2\n"), ImportCSV, managers.ImportOptions{Mapping: map[string]string{"Name": "title"}})
+	if err != nil || report.Created != 1 {
+		t.Errorf("Expected the import to create 1 book, got %+v %v", report, err)
+	}
+
+	export, err := c.ExportBooks(ctx, ExportCSV, managers.BookQuery{})
+	if err != nil {
+		t.Errorf("Error exporting the books: %v", err)
+		t.FailNow()
+	}
+	defer export.Close()
+
+	csv, _ := ioutil.ReadAll(export)
+	if !strings.Contains(string(csv), "MyBook") {
+		t.Errorf("Expected the export to have the imported book, got %s", csv)
+	}
+}
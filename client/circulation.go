@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// patronRequest is the body of the requests that act on a book for a patron
+type patronRequest struct {
+	PatronID uuid.UUID `json:"patron_id"`
+}
+
+// creditRequest is the body of a payment or waiver, the amount is in cents
+type creditRequest struct {
+	Amount int64  `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// patronPath returns the path of a patron, or one of their routes
+func patronPath(id uuid.UUID, route ...string) string {
+	path := "/patrons/" + id.String()
+	for _, r := range route {
+		path += "/" + r
+	}
+
+	return path
+}
+
+// Checkout lends the book to the patron and returns the loan
+func (c *Client) Checkout(ctx context.Context, bookID, patronID uuid.UUID) (model.Loan, error) {
+	var loan model.Loan
+	err := c.sendJSON(ctx, "POST", bookPath(bookID, "checkout"), patronRequest{patronID}, &loan)
+
+	return loan, err
+}
+
+// Return ends the book's loan and returns the finished loan, with any fine
+// for returning it late
+func (c *Client) Return(ctx context.Context, bookID uuid.UUID) (model.Loan, error) {
+	var loan model.Loan
+	_, err := c.call(ctx, request{method: "POST", path: bookPath(bookID, "return")}, &loan)
+
+	return loan, err
+}
+
+// Renew extends the book's loan and returns it
+func (c *Client) Renew(ctx context.Context, bookID uuid.UUID) (model.Loan, error) {
+	var loan model.Loan
+	_, err := c.call(ctx, request{method: "POST", path: bookPath(bookID, "renew")}, &loan)
+
+	return loan, err
+}
+
+// ReportLost marks the checked out book as lost and returns its finished
+// loan
+func (c *Client) ReportLost(ctx context.Context, bookID uuid.UUID) (model.Loan, error) {
+	var loan model.Loan
+	_, err := c.call(ctx, request{method: "POST", path: bookPath(bookID, "lost")}, &loan)
+
+	return loan, err
+}
+
+// Found checks a lost book back in and returns it, if credit is true the
+// patron who lost it is refunded the lost item charge
+func (c *Client) Found(ctx context.Context, bookID uuid.UUID, credit bool) (model.Book, error) {
+	query := url.Values{}
+	if credit {
+		query.Set("credit", "true")
+	}
+
+	var book model.Book
+	_, err := c.call(ctx, request{method: "POST", path: bookPath(bookID, "found"), query: query}, &book)
+
+	return book, err
+}
+
+// BookLoans returns every loan of the book, current and past
+func (c *Client) BookLoans(ctx context.Context, bookID uuid.UUID) ([]model.Loan, error) {
+	var loans []model.Loan
+	_, err := c.get(ctx, bookPath(bookID, "loans"), nil, &loans)
+
+	return loans, err
+}
+
+// OverdueLoans returns the loans that are past their due date
+func (c *Client) OverdueLoans(ctx context.Context) ([]model.Loan, error) {
+	var loans []model.Loan
+	_, err := c.get(ctx, "/loans/overdue", nil, &loans)
+
+	return loans, err
+}
+
+// Holds returns the book's holds in the order they'll be filled
+func (c *Client) Holds(ctx context.Context, bookID uuid.UUID) ([]model.Hold, error) {
+	var holds []model.Hold
+	_, err := c.get(ctx, bookPath(bookID, "holds"), nil, &holds)
+
+	return holds, err
+}
+
+// PlaceHold puts the patron in the queue for the checked out book
+func (c *Client) PlaceHold(ctx context.Context, bookID, patronID uuid.UUID) (model.Hold, error) {
+	var hold model.Hold
+	err := c.sendJSON(ctx, "POST", bookPath(bookID, "holds"), patronRequest{patronID}, &hold)
+
+	return hold, err
+}
+
+// GetHold returns a specific hold on the book
+func (c *Client) GetHold(ctx context.Context, bookID, holdID uuid.UUID) (model.Hold, error) {
+	var hold model.Hold
+	_, err := c.get(ctx, bookPath(bookID, "holds", holdID.String()), nil, &hold)
+
+	return hold, err
+}
+
+// CancelHold removes the hold from the book's queue
+func (c *Client) CancelHold(ctx context.Context, bookID, holdID uuid.UUID) error {
+	_, err := c.call(ctx, request{method: "DELETE", path: bookPath(bookID, "holds", holdID.String())}, nil)
+	return err
+}
+
+// Patrons returns every patron
+func (c *Client) Patrons(ctx context.Context) ([]model.Patron, error) {
+	var patrons []model.Patron
+	_, err := c.get(ctx, "/patrons", nil, &patrons)
+
+	return patrons, err
+}
+
+// CreatePatron adds a new patron and returns them with their new id
+func (c *Client) CreatePatron(ctx context.Context, patron model.Patron) (model.Patron, error) {
+	var created model.Patron
+	err := c.sendJSON(ctx, "POST", "/patrons", patron, &created)
+
+	return created, err
+}
+
+// GetPatron returns the patron with the given id
+func (c *Client) GetPatron(ctx context.Context, id uuid.UUID) (model.Patron, error) {
+	var patron model.Patron
+	_, err := c.get(ctx, patronPath(id), nil, &patron)
+
+	return patron, err
+}
+
+// PatronLoans returns every loan to the patron, current and past
+func (c *Client) PatronLoans(ctx context.Context, patronID uuid.UUID) ([]model.Loan, error) {
+	var loans []model.Loan
+	_, err := c.get(ctx, patronPath(patronID, "loans"), nil, &loans)
+
+	return loans, err
+}
+
+// Ledger returns the patron's charges, credits and balance
+func (c *Client) Ledger(ctx context.Context, patronID uuid.UUID) (model.Ledger, error) {
+	var ledger model.Ledger
+	_, err := c.get(ctx, patronPath(patronID, "ledger"), nil, &ledger)
+
+	return ledger, err
+}
+
+// Pay credits a payment of the amount, in cents, to the patron's ledger
+func (c *Client) Pay(ctx context.Context, patronID uuid.UUID, amount int64, note string) (model.LedgerEntry, error) {
+	var entry model.LedgerEntry
+	err := c.sendJSON(ctx, "POST", patronPath(patronID, "payments"), creditRequest{amount, note}, &entry)
+
+	return entry, err
+}
+
+// Waive credits a waiver of the amount, in cents, to the patron's ledger
+func (c *Client) Waive(ctx context.Context, patronID uuid.UUID, amount int64, note string) (model.LedgerEntry, error) {
+	var entry model.LedgerEntry
+	err := c.sendJSON(ctx, "POST", patronPath(patronID, "waivers"), creditRequest{amount, note}, &entry)
+
+	return entry, err
+}
+
+// CirculationStats returns the number of checkouts by book, author and
+// month, only counting the checkouts between since and until when they're
+// given
+func (c *Client) CirculationStats(ctx context.Context, since, until *time.Time) (model.CirculationStats, error) {
+	query := url.Values{}
+	if since != nil {
+		query.Set("since", formatDate(*since))
+	}
+	if until != nil {
+		query.Set("until", formatDate(*until))
+	}
+
+	var stats model.CirculationStats
+	_, err := c.get(ctx, "/stats/circulation", query, &stats)
+
+	return stats, err
+}
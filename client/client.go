@@ -0,0 +1,311 @@
+// Package client is the Go client for BooksAPI, it has a method for every
+// route in api/routes.go and uses the same types as the api does, so a book
+// from the client is a model.Book. Those types are all in the models package,
+// which is the only package of the server's that the client imports, so it
+// doesn't pull in the server's dependencies.
+//
+// The errors the api responds with are returned as an *Error which wraps the
+// matching error from this package, so they can be checked with errors.Is,
+// like errors.Is(err, client.ErrNoBookWithThatID)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// this const block holds the defaults of a RetryPolicy
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 100 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+)
+
+// RetryPolicy holds the rules for retrying requests that failed in a way
+// that might not happen again, which are connection errors and 429, 502, 503
+// and 504 responses. Only GET, PUT and DELETE requests are retried since
+// they're safe to send twice
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is sent before giving up, 1
+	// turns retrying off
+	MaxAttempts int
+
+	// Backoff is how long to wait after the first failed attempt, the wait
+	// doubles after each failed attempt up to MaxBackoff. A Retry-After
+	// header in the response is used instead when there is one
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the RetryPolicy that new Clients start with
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+}
+
+// backoff returns how long to wait after the given number of failed attempts
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// Client calls BooksAPI, its fields can be changed after New but not while
+// it's being used
+type Client struct {
+	// BaseURL is where the api is, the route's paths are added to its path
+	BaseURL *url.URL
+
+	// HTTPClient sends the requests, it shouldn't have a Timeout since that
+	// would end the event streams, use a context with a deadline instead
+	HTTPClient *http.Client
+
+	// APIKey is sent in the X-API-Key header, and Token as a bearer token,
+	// when they're set
+	APIKey string
+	Token  string
+
+	// Retry holds the rules for retrying requests
+	Retry RetryPolicy
+
+	// UserAgent is sent with every request
+	UserAgent string
+}
+
+// New returns a Client for the api at the base url, like
+// http://localhost:5555
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("The base url %q must be an absolute http or https url", baseURL)
+	}
+
+	return &Client{
+		BaseURL:    u,
+		HTTPClient: &http.Client{},
+		Retry:      DefaultRetryPolicy(),
+		UserAgent:  "books-api-go-client",
+	}, nil
+}
+
+// request is a request to the api, the body is kept as bytes so it can be
+// sent again when the request is retried
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+
+	// accept is a status code that isn't 2xx but still has the route's
+	// usual body rather than an error
+	accept int
+}
+
+// jsonRequest returns a request with the value marshaled to json as its body
+func jsonRequest(method, path string, v interface{}) (request, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return request{}, fmt.Errorf("Unable to marshal the request body: %v", err)
+	}
+
+	return request{method: method, path: path, body: body, contentType: "application/json"}, nil
+}
+
+// retryable returns true if the request is safe to send more than once
+func (r request) retryable() bool {
+	switch r.method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// retryableStatuses are the status codes worth retrying a request for
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// url returns the url of the route's path
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.BaseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// do sends the request, retrying it by the client's RetryPolicy, and
+// returns the response if its status code is 2xx or 304, otherwise it
+// returns an *Error. The caller has to close the response's body
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	attempts := c.Retry.MaxAttempts
+	if attempts < 1 || !r.retryable() {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.send(ctx, r)
+		if err == nil && (res.StatusCode < 300 || res.StatusCode == http.StatusNotModified || res.StatusCode == r.accept) {
+			return res, nil
+		}
+
+		// the request was cancelled rather than failing
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var wait time.Duration
+		if err == nil {
+			err = readError(res)
+			wait = retryAfter(res)
+			if !retryableStatuses[res.StatusCode] {
+				return nil, err
+			}
+		}
+
+		if attempt >= attempts {
+			return nil, err
+		}
+
+		if wait == 0 {
+			wait = c.Retry.backoff(attempt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send makes a single attempt at the request
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, c.url(r.path, r.query), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+// retryAfter returns how long a response's Retry-After header says to wait,
+// 0 if it doesn't have one given in seconds
+func retryAfter(res *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// call sends the request and decodes the json response into out, unless
+// out is nil, returning the response's headers
+func (c *Client) call(ctx context.Context, r request, out interface{}) (http.Header, error) {
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return res.Header, decodeBody(res, r, out)
+}
+
+// decodeBody decodes the json body of the response to the request into out,
+// or throws it away if out is nil or the response doesn't have a body
+func decodeBody(res *http.Response, r request, out interface{}) error {
+	if out == nil || res.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+
+	err := json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("Unable to read the response from %v %v: %v", r.method, r.path, err)
+	}
+
+	return nil
+}
+
+// get sends a GET request for the path and decodes the json response into out
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) (http.Header, error) {
+	return c.call(ctx, request{method: "GET", path: path, query: query}, out)
+}
+
+// getRaw sends a GET request for the path and returns the whole response
+// body, for the routes that don't respond with json
+func (c *Client) getRaw(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	res, err := c.do(ctx, request{method: "GET", path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// sendJSON sends a request with the value as its json body and decodes the json
+// response into out
+func (c *Client) sendJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	r, err := jsonRequest(method, path, in)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.call(ctx, r, out)
+	return err
+}
+
+// totalCount reads the X-Total-Count header of a paged response
+func totalCount(header http.Header) int {
+	total, _ := strconv.Atoi(header.Get("X-Total-Count"))
+	return total
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/askewseth/kubernetes/api"
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	server *httptest.Server
+)
+
+func init() {
+	// the client is tested against the real api
+	server = httptest.NewServer(api.GetRouter())
+}
+
+// newClient returns a client for the url that retries quickly
+func newClient(t *testing.T, url string) *Client {
+	c, err := New(url)
+	if err != nil {
+		t.Errorf("Error making a client for %v: %v", url, err)
+		t.FailNow()
+	}
+	c.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	return c
+}
+
+// cleanLibrary replaces the global library and circulation with empty ones
+func cleanLibrary() {
+	managers.SetLibrary(managers.NewLibrary())
+	managers.SetCirculation(managers.NewCirculation())
+}
+
+func TestNew(t *testing.T) {
+	for _, url := range []string{"", "localhost:5555", "ftp://example.com", "http://"} {
+		if _, err := New(url); err == nil {
+			t.Errorf("Expected an error making a client for %q", url)
+		}
+	}
+
+	c := newClient(t, "http://example.com/api/")
+	if got := c.url("/books", nil); got != "http://example.com/api/books" {
+		t.Errorf("Expected the base url's path to be kept, got %v", got)
+	}
+}
+
+func TestErrorsMatchAPI(t *testing.T) {
+	tests := []struct {
+		client, api error
+	}{
+		{ErrInvalidUUID, api.ErrInvalidUUID},
+		{ErrInvalidHoldUUID, api.ErrInvalidHoldUUID},
+		{ErrPreconditionFailed, api.ErrPreconditionFailed},
+		{ErrPatchTestFailed, api.ErrPatchTestFailed},
+		{ErrUnauthorized, api.ErrUnauthorized},
+		{ErrForbidden, api.ErrForbidden},
+		{ErrInvalidAPIKey, api.ErrInvalidAPIKey},
+		{ErrInvalidRole, api.ErrInvalidRole},
+		{ErrStarting, api.ErrStarting},
+		{ErrShuttingDown, api.ErrShuttingDown},
+	}
+
+	for _, test := range tests {
+		if test.client.Error() != test.api.Error() {
+			t.Errorf("Expected the client's error %q to match the api's %q", test.client, test.api)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	defer cleanLibrary()
+	c := newClient(t, server.URL)
+
+	id, _ := uuid.NewV4()
+	_, err := c.GetBook(context.Background(), id)
+	if !errors.Is(err, ErrNoBookWithThatID) || !errors.Is(err, managers.ErrNoBookWithThatID) {
+		t.Errorf("Expected ErrNoBookWithThatID getting a book that doesn't exist, got %v", err)
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != 404 || e.RequestID == "" {
+		t.Errorf("Expected an *Error with a 404 and a request id, got %#v", err)
+	}
+
+	_, err = c.CreateBook(context.Background(), model.Book{Title: "MyBook", Rating: 5})
+	if !errors.Is(err, ErrInvalidRating) {
+		t.Errorf("Expected ErrInvalidRating creating a book rated 5, got %v", err)
+	}
+
+	_, err = c.Checkout(context.Background(), id, id)
+	if !errors.As(err, &e) || e.StatusCode != 404 {
+		t.Errorf("Expected a 404 checking out a book that doesn't exist, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var attempts int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer flaky.Close()
+
+	c := newClient(t, flaky.URL)
+	if _, err := c.Patrons(context.Background()); err != nil {
+		t.Errorf("Expected the third attempt to succeed, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %v", attempts)
+	}
+
+	// a POST isn't safe to send twice
+	atomic.StoreInt32(&attempts, 0)
+	_, err := c.CreatePatron(context.Background(), model.Patron{Name: "Me"})
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != 503 || attempts != 1 {
+		t.Errorf("Expected a single attempt at a POST that failed with a 503, got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	c := newClient(t, unavailable.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.OverdueLoans(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded waiting to retry, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the retry to stop waiting once the context was done")
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "The token has expired"}`))
+	}))
+	defer unauthorized.Close()
+
+	_, err := newClient(t, unauthorized.URL).Patrons(context.Background())
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized for a 401, got %v", err)
+	}
+	if err == nil || err.Error() != "The api responded with 401: The token has expired" {
+		t.Errorf("Expected the error to have the api's message, got %v", err)
+	}
+
+	invalidKey := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "The api key is invalid"}`))
+	}))
+	defer invalidKey.Close()
+
+	_, err = newClient(t, invalidKey.URL).Patrons(context.Background())
+	if !errors.Is(err, ErrInvalidAPIKey) || !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrInvalidAPIKey, which is also ErrUnauthorized, for an invalid api key, got %v", err)
+	}
+}
+
+func TestCirculation(t *testing.T) {
+	defer cleanLibrary()
+	c := newClient(t, server.URL)
+	ctx := context.Background()
+
+	book, err := c.CreateBook(ctx, model.Book{Title: "MyBook", Rating: 3})
+	if err != nil {
+		t.Errorf("Error creating a book: %v", err)
+		t.FailNow()
+	}
+
+	reader, _ := c.CreatePatron(ctx, model.Patron{Name: "Reader", Email: "reader@example.com"})
+	waiter, _ := c.CreatePatron(ctx, model.Patron{Name: "Waiter", Email: "waiter@example.com"})
+
+	loan, err := c.Checkout(ctx, book.ID, reader.ID)
+	if err != nil || !uuid.Equal(loan.PatronID, reader.ID) {
+		t.Errorf("Expected the book to be checked out to the reader, got %+v %v", loan, err)
+	}
+
+	_, err = c.Checkout(ctx, book.ID, waiter.ID)
+	if !errors.Is(err, ErrBookCheckedOut) {
+		t.Errorf("Expected ErrBookCheckedOut checking out a book twice, got %v", err)
+	}
+
+	hold, err := c.PlaceHold(ctx, book.ID, waiter.ID)
+	if err != nil {
+		t.Errorf("Error placing a hold: %v", err)
+	}
+
+	holds, _ := c.Holds(ctx, book.ID)
+	if len(holds) != 1 || !uuid.Equal(holds[0].ID, hold.ID) {
+		t.Errorf("Expected the book to have the hold, got %+v", holds)
+	}
+
+	if _, err = c.Return(ctx, book.ID); err != nil {
+		t.Errorf("Error returning the book: %v", err)
+	}
+
+	loans, _ := c.PatronLoans(ctx, reader.ID)
+	if len(loans) != 1 || loans[0].ReturnedAt == nil {
+		t.Errorf("Expected the reader to have 1 returned loan, got %+v", loans)
+	}
+}
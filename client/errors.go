@@ -0,0 +1,197 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// the errors the api responds with, the ones the managers and models return
+// are the same errors, so errors.Is(err, managers.ErrNoBookWithThatID)
+// works too
+var (
+	ErrNoBookWithThatID     = model.ErrNoBookWithThatID
+	ErrNoPatronWithThatID   = model.ErrNoPatronWithThatID
+	ErrNoHoldWithThatID     = model.ErrNoHoldWithThatID
+	ErrNoWebhookWithThatID  = model.ErrNoWebhookWithThatID
+	ErrNoDeliveryWithThatID = model.ErrNoDeliveryWithThatID
+
+	ErrBookCheckedOut        = model.ErrBookCheckedOut
+	ErrBookNotCheckedOut     = model.ErrBookNotCheckedOut
+	ErrBookAvailable         = model.ErrBookAvailable
+	ErrAlreadyOnHold         = model.ErrAlreadyOnHold
+	ErrPatronHasBook         = model.ErrPatronHasBook
+	ErrBookReserved          = model.ErrBookReserved
+	ErrBookHasHolds          = model.ErrBookHasHolds
+	ErrMaxRenewals           = model.ErrMaxRenewals
+	ErrRenewalBlockedByHolds = model.ErrRenewalBlockedByHolds
+
+	ErrInvalidAmount     = model.ErrInvalidAmount
+	ErrAmountOverBalance = model.ErrAmountOverBalance
+	ErrPatronBlocked     = model.ErrPatronBlocked
+	ErrBookLost          = model.ErrBookLost
+	ErrBookNotLost       = model.ErrBookNotLost
+
+	ErrBatchTooLarge    = model.ErrBatchTooLarge
+	ErrUnknownBatchOp   = model.ErrUnknownBatchOp
+	ErrBookExists       = model.ErrBookExists
+	ErrRevisionMismatch = model.ErrRevisionMismatch
+	ErrBatchAborted     = model.ErrBatchAborted
+
+	ErrEmptyCSV         = model.ErrEmptyCSV
+	ErrUnknownColumn    = model.ErrUnknownColumn
+	ErrInvalidMARC      = model.ErrInvalidMARC
+	ErrInvalidSortField = model.ErrInvalidSortField
+
+	ErrDeliveryNotDead     = model.ErrDeliveryNotDead
+	ErrInvalidWebhookURL   = model.ErrInvalidWebhookURL
+	ErrInvalidWebhookEvent = model.ErrInvalidWebhookEvent
+
+	ErrInvalidRating     = model.ErrInvalidRating
+	ErrInvalidStatus     = model.ErrInvalidStatus
+	ErrInvalidISBN       = model.ErrInvalidISBN
+	ErrInvalidText       = model.ErrInvalidText
+	ErrInvalidPatronName = model.ErrInvalidPatronName
+)
+
+// the errors that come from the api itself, they have the same messages as
+// the errors in the api package, which the client doesn't import so that it
+// doesn't pull in the server's dependencies
+var (
+	// ErrInvalidUUID is returned when an id in the path isn't a valid UUID
+	ErrInvalidUUID = errors.New("The given id was not a valid UUID")
+
+	// ErrInvalidHoldUUID is returned when a hold id in the path isn't a
+	// valid UUID
+	ErrInvalidHoldUUID = errors.New("The given hold id was not a valid UUID")
+
+	// ErrPreconditionFailed is returned when a book was changed since the
+	// revision a change was made against
+	ErrPreconditionFailed = errors.New("The book has been changed since it was read, get it again and retry")
+
+	// ErrPatchTestFailed is returned when a JSON Patch's test operation
+	// doesn't match the book
+	ErrPatchTestFailed = errors.New("The patch's test operation didn't match the book")
+
+	// ErrUnauthorized is returned when the client's api key or token is
+	// missing or invalid
+	ErrUnauthorized = errors.New("The request needs an api key or a bearer token")
+
+	// ErrForbidden is returned when the client's api key or token doesn't
+	// have a role that can call the route
+	ErrForbidden = errors.New("The api key or token doesn't have a role that's allowed to do this")
+
+	// ErrInvalidAPIKey is returned when the client's api key isn't one the
+	// api knows, and ErrInvalidRole when its token's role isn't reader,
+	// librarian or admin. They're both ErrUnauthorized as well
+	ErrInvalidAPIKey error = &unauthorizedError{"The api key is invalid"}
+	ErrInvalidRole   error = &unauthorizedError{"The role must be reader, librarian or admin"}
+
+	// ErrStarting and ErrShuttingDown are returned when the server can't
+	// handle requests yet, or anymore
+	ErrStarting     = errors.New("The server is still starting up")
+	ErrShuttingDown = errors.New("The server is shutting down")
+)
+
+// unauthorizedError is an error the api responds to with a 401, it's a more
+// specific ErrUnauthorized
+type unauthorizedError struct {
+	message string
+}
+
+func (e *unauthorizedError) Error() string {
+	return e.message
+}
+
+// Unwrap returns ErrUnauthorized, so errors.Is matches it along with the
+// more specific error
+func (e *unauthorizedError) Unwrap() error {
+	return ErrUnauthorized
+}
+
+// knownErrors maps the message of each error the api responds with to the
+// error
+var knownErrors = make(map[string]error)
+
+// statusErrors are the errors for responses whose message isn't known, by
+// their status code
+var statusErrors = map[int]error{
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusPreconditionFailed: ErrPreconditionFailed,
+}
+
+func init() {
+	for _, err := range []error{
+		ErrNoBookWithThatID, ErrNoPatronWithThatID, ErrNoHoldWithThatID, ErrNoWebhookWithThatID, ErrNoDeliveryWithThatID,
+		ErrBookCheckedOut, ErrBookNotCheckedOut, ErrBookAvailable, ErrAlreadyOnHold, ErrPatronHasBook,
+		ErrBookReserved, ErrBookHasHolds, ErrMaxRenewals, ErrRenewalBlockedByHolds,
+		ErrInvalidAmount, ErrAmountOverBalance, ErrPatronBlocked, ErrBookLost, ErrBookNotLost,
+		ErrBatchTooLarge, ErrUnknownBatchOp, ErrBookExists, ErrRevisionMismatch, ErrBatchAborted,
+		ErrEmptyCSV, ErrUnknownColumn, ErrInvalidMARC, ErrInvalidSortField,
+		ErrDeliveryNotDead, ErrInvalidWebhookURL, ErrInvalidWebhookEvent,
+		ErrInvalidRating, ErrInvalidStatus, ErrInvalidISBN, ErrInvalidText, ErrInvalidPatronName,
+		ErrInvalidUUID, ErrInvalidHoldUUID, ErrPreconditionFailed, ErrPatchTestFailed,
+		ErrUnauthorized, ErrForbidden, ErrInvalidAPIKey, ErrInvalidRole,
+		ErrStarting, ErrShuttingDown,
+	} {
+		knownErrors[err.Error()] = err
+	}
+}
+
+// Error is a response from the api with a status code that isn't 2xx, it
+// wraps the matching error from this package when there is one
+type Error struct {
+	StatusCode int
+
+	// Message is the error in the response's body, or the status if the
+	// body doesn't have one
+	Message string
+
+	// RequestID is the id the api logged the request with
+	RequestID string
+
+	err error
+}
+
+// Error returns the status code and message of the response
+func (e *Error) Error() string {
+	return fmt.Sprintf("The api responded with %v: %v", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the error from this package that the response was for, or
+// nil if it isn't one of them
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// readError reads the error out of a response and closes its body
+func readError(res *http.Response) *Error {
+	defer res.Body.Close()
+
+	e := &Error{
+		StatusCode: res.StatusCode,
+		Message:    http.StatusText(res.StatusCode),
+		RequestID:  res.Header.Get("X-Request-ID"),
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if json.Unmarshal(b, &body) == nil && body.Error != "" {
+		e.Message = body.Error
+	}
+
+	e.err = knownErrors[e.Message]
+	if e.err == nil {
+		e.err = statusErrors[res.StatusCode]
+	}
+
+	return e
+}
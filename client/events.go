@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// EventReset is the type of the event sent instead of the events a stream
+// missed, when they're no longer kept by the server. The stream carries on
+// from the latest event, but the books have to be read again since some
+// changes were missed
+const EventReset model.EventType = "reset"
+
+// EventStream reads the changes made to the books from GET /books/events,
+// like
+//
+//	stream, err := c.BookEvents(ctx, 0)
+//	defer stream.Close()
+//	for stream.Next() {
+//		event := stream.Event()
+//	}
+//	err = stream.Err()
+//
+// The server ends the stream when it shuts down or the stream falls too far
+// behind, the EventStream reconnects from the last event it got whenever
+// that happens, so no events are missed
+type EventStream struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lastID uint64
+	body   io.ReadCloser
+	reader *bufio.Reader
+
+	// reconnects is how many times in a row the stream has reconnected
+	// without getting an event
+	reconnects int
+
+	event model.Event
+	err   error
+
+	// closed is closed by Close, which can be called while Next is waiting
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// badEventError is an event that couldn't be read, which means the stream
+// can't be trusted anymore
+type badEventError struct {
+	err error
+}
+
+func (e badEventError) Error() string {
+	return e.err.Error()
+}
+
+// BookEvents opens a stream of the changes made to the books, starting
+// after the event with the given id, or from now on if it's 0. The stream
+// ends when the context is done or it's closed
+func (c *Client) BookEvents(ctx context.Context, lastID uint64) (*EventStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventStream{c: c, ctx: ctx, cancel: cancel, lastID: lastID, closed: make(chan struct{})}
+
+	err := s.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// connect opens the stream from the last event
+func (s *EventStream) connect() error {
+	r := request{method: "GET", path: "/books/events", header: http.Header{"Accept": []string{"text/event-stream"}}}
+	if s.lastID != 0 {
+		r.header.Set("Last-Event-ID", strconv.FormatUint(s.lastID, 10))
+	}
+
+	res, err := s.c.do(s.ctx, r)
+	if err != nil {
+		return err
+	}
+
+	s.body = res.Body
+	s.reader = bufio.NewReader(res.Body)
+	return nil
+}
+
+// Next waits for the next event and returns true once it's got it, or
+// false if the stream has ended
+func (s *EventStream) Next() bool {
+	for s.err == nil {
+		event, err := s.read()
+		if err == nil {
+			s.event = event
+			s.lastID = event.ID
+			s.reconnects = 0
+			return true
+		}
+		s.body.Close()
+
+		if _, ok := err.(badEventError); ok {
+			s.err = err
+			break
+		}
+		if s.ctx.Err() != nil {
+			s.err = s.ctx.Err()
+			break
+		}
+
+		// the server ended the stream, but one that keeps ending without
+		// sending anything isn't going to get any better
+		s.reconnects++
+		if s.reconnects > s.c.Retry.MaxAttempts {
+			s.err = fmt.Errorf("The event stream keeps ending: %v", err)
+			break
+		}
+
+		timer := time.NewTimer(s.c.Retry.backoff(s.reconnects))
+		select {
+		case <-timer.C:
+			s.err = s.connect()
+		case <-s.ctx.Done():
+			timer.Stop()
+			s.err = s.ctx.Err()
+		}
+	}
+
+	return false
+}
+
+// read reads the next event off of the stream, skipping the comments that
+// keep it alive and anything else that isn't an event
+func (s *EventStream) read() (model.Event, error) {
+	var id, eventType string
+	var data []string
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return model.Event{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if eventType != "" {
+				return parseEvent(id, eventType, strings.Join(data, "\n"))
+			}
+			id, data = "", nil
+			continue
+		}
+
+		// lines starting with a colon are comments
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+// parseEvent reads an event's id, type and data into an Event
+func parseEvent(id, eventType, data string) (model.Event, error) {
+	var event model.Event
+
+	if model.EventType(eventType) == EventReset {
+		eventID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return event, badEventError{fmt.Errorf("The reset event's id %q isn't valid", id)}
+		}
+
+		return model.Event{ID: eventID, Type: EventReset}, nil
+	}
+
+	err := json.Unmarshal([]byte(data), &event)
+	if err != nil {
+		return event, badEventError{fmt.Errorf("Unable to read the %v event %v: %v", eventType, id, err)}
+	}
+
+	return event, nil
+}
+
+// Event returns the event Next got
+func (s *EventStream) Event() model.Event {
+	return s.event
+}
+
+// LastID returns the id of the last event the stream got, which can be
+// given to BookEvents to carry on from where it left off
+func (s *EventStream) LastID() uint64 {
+	return s.lastID
+}
+
+// Err returns what ended the stream, or nil if it was closed
+func (s *EventStream) Err() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+		return s.err
+	}
+}
+
+// Close ends the stream, it can be called while Next is waiting for an
+// event
+func (s *EventStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.cancel()
+	})
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/askewseth/kubernetes/managers"
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestBookEvents(t *testing.T) {
+	defer cleanLibrary()
+	c := newClient(t, server.URL)
+
+	stream, err := c.BookEvents(context.Background(), 0)
+	if err != nil {
+		t.Errorf("Error opening the event stream: %v", err)
+		t.FailNow()
+	}
+
+	book, _ := c.CreateBook(context.Background(), model.Book{Title: "MyBook", Rating: 3})
+
+	if !stream.Next() {
+		t.Errorf("Expected an event, got %v", stream.Err())
+		t.FailNow()
+	}
+
+	event := stream.Event()
+	if event.Type != managers.EventCreated || event.Book.ID != book.ID || stream.LastID() != event.ID {
+		t.Errorf("Expected a created event for the book, got %+v", event)
+	}
+
+	stream.Close()
+	if stream.Next() || stream.Err() != nil {
+		t.Errorf("Expected a closed stream to end without an error, got %v", stream.Err())
+	}
+}
+
+func TestBookEventsReconnect(t *testing.T) {
+	var lastIDs []string
+	streams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+
+		// the first stream ends after an event, and the second is reset
+		switch len(lastIDs) {
+		case 1:
+			w.Write([]byte("retry: 3000\n\n: keep-alive\n\nid: 7\nevent: created\ndata: {\"id\": 7, \"type\": \"created\"}\n\n"))
+		case 2:
+			w.Write([]byte("id: 12\nevent: reset\ndata: {}\n\n"))
+		}
+	}))
+	defer streams.Close()
+
+	c := newClient(t, streams.URL)
+	stream, err := c.BookEvents(context.Background(), 0)
+	if err != nil {
+		t.Errorf("Error opening the event stream: %v", err)
+		t.FailNow()
+	}
+	defer stream.Close()
+
+	if !stream.Next() || stream.Event().ID != 7 {
+		t.Errorf("Expected event 7, got %+v %v", stream.Event(), stream.Err())
+	}
+	if !stream.Next() || stream.Event().Type != EventReset || stream.LastID() != 12 {
+		t.Errorf("Expected a reset to event 12 after reconnecting, got %+v %v", stream.Event(), stream.Err())
+	}
+
+	// the stream keeps ending without any events
+	if stream.Next() || stream.Err() == nil {
+		t.Errorf("Expected the stream to give up reconnecting")
+	}
+
+	if lastIDs[0] != "" || lastIDs[1] != "7" || lastIDs[2] != "12" {
+		t.Errorf("Expected to reconnect from the last event each time, got %v", lastIDs)
+	}
+}
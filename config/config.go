@@ -0,0 +1,354 @@
+// Package config loads the server's configuration. Every setting has a
+// default and can be given in a YAML or JSON config file, as an environment
+// variable or as a flag, each of which overrides the ones before it
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/askewseth/kubernetes/managers"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of the environment variable for each flag, which
+// is the flag's name in upper case with dashes replaced by underscores, so
+// -loan-period is BOOKS_API_LOAN_PERIOD
+const EnvPrefix = "BOOKS_API_"
+
+// Redacted replaces the value of secrets in the effective config
+const Redacted = "REDACTED"
+
+var (
+	// ErrBothHMACSecrets is returned when the JWT HMAC secret is given both
+	// directly and as a file
+	ErrBothHMACSecrets = errors.New("Only one of jwt-hmac-key and jwt-hmac-secret can be given")
+)
+
+// Duration is a time.Duration that's written and read as a string like 72h
+// in config files and the effective config
+type Duration time.Duration
+
+// String formats the duration like time.Duration does
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set reads the duration from a flag or environment variable
+func (d *Duration) Set(s string) error {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// UnmarshalYAML reads the duration from a config file
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	err := node.Decode(&s)
+	if err != nil {
+		return err
+	}
+
+	err = d.Set(s)
+	if err != nil {
+		return fmt.Errorf("line %v: %v", node.Line, err)
+	}
+
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Config is everything the server can be configured with
+type Config struct {
+	// Listen is the address the server listens on
+	Listen string `yaml:"listen" json:"listen"`
+
+	Storage StorageConfig `yaml:"storage" json:"storage"`
+	Log     LogConfig     `yaml:"log" json:"log"`
+	Server  ServerConfig  `yaml:"server" json:"server"`
+	Auth    AuthConfig    `yaml:"auth" json:"auth"`
+	Loans   LoanConfig    `yaml:"loans" json:"loans"`
+}
+
+// StorageConfig picks where the books are kept
+type StorageConfig struct {
+	// Backend is memory, file or sqlite
+	Backend string `yaml:"backend" json:"backend"`
+
+	// Path is the log file of the file backend or the database of the sqlite
+	// backend, "" for the backend's default
+	Path string `yaml:"path" json:"path"`
+}
+
+// LogConfig sets what's logged and how
+type LogConfig struct {
+	// Level is the least severe level that's logged, like debug or info
+	Level string `yaml:"level" json:"level"`
+
+	// Format is text or json
+	Format string `yaml:"format" json:"format"`
+}
+
+// ServerConfig holds the server's timeouts
+type ServerConfig struct {
+	ReadTimeout     Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	// ShutdownDelay is how long the server keeps accepting connections
+	// after it starts failing the readiness probe, so that it's taken out
+	// of the service before it stops listening
+	ShutdownDelay Duration `yaml:"shutdown_delay" json:"shutdown_delay"`
+}
+
+// AuthConfig holds the credentials requests are authenticated with, the
+// server won't start without any unless InsecureNoAuth is set
+type AuthConfig struct {
+	APIKeysFile         string   `yaml:"api_keys_file" json:"api_keys_file"`
+	JWTHMACKey          string   `yaml:"jwt_hmac_key" json:"jwt_hmac_key"`
+	JWTHMACSecretFile   string   `yaml:"jwt_hmac_secret_file" json:"jwt_hmac_secret_file"`
+	JWTRSAPublicKeyFile string   `yaml:"jwt_rsa_public_key_file" json:"jwt_rsa_public_key_file"`
+	JWTIssuer           string   `yaml:"jwt_issuer" json:"jwt_issuer"`
+	JWTAudience         string   `yaml:"jwt_audience" json:"jwt_audience"`
+	JWTLeeway           Duration `yaml:"jwt_leeway" json:"jwt_leeway"`
+
+	// InsecureNoAuth lets the server start without any credentials, which
+	// leaves every route open to anyone
+	InsecureNoAuth bool `yaml:"insecure_no_auth" json:"insecure_no_auth"`
+}
+
+// LoanConfig holds the rules for lending books and charging patrons, the
+// amounts are in cents
+type LoanConfig struct {
+	LoanPeriod     Duration `yaml:"loan_period" json:"loan_period"`
+	MaxRenewals    int      `yaml:"max_renewals" json:"max_renewals"`
+	PickupWindow   Duration `yaml:"pickup_window" json:"pickup_window"`
+	DailyFine      int64    `yaml:"daily_fine" json:"daily_fine"`
+	MaxFine        int64    `yaml:"max_fine" json:"max_fine"`
+	LostItemCharge int64    `yaml:"lost_item_charge" json:"lost_item_charge"`
+	MaxBalance     int64    `yaml:"max_balance" json:"max_balance"`
+}
+
+// Default returns the config the server runs with when nothing else is given
+func Default() Config {
+	return Config{
+		Listen:  ":5555",
+		Storage: StorageConfig{Backend: "memory"},
+		Log:     LogConfig{Level: "info", Format: "text"},
+		Server: ServerConfig{
+			ReadTimeout:     Duration(30 * time.Second),
+			WriteTimeout:    Duration(time.Minute),
+			IdleTimeout:     Duration(2 * time.Minute),
+			ShutdownTimeout: Duration(20 * time.Second),
+			ShutdownDelay:   Duration(5 * time.Second),
+		},
+		Auth: AuthConfig{JWTLeeway: Duration(30 * time.Second)},
+		Loans: LoanConfig{
+			LoanPeriod:     Duration(managers.DefaultLoanPeriod),
+			MaxRenewals:    managers.DefaultMaxRenewals,
+			PickupWindow:   Duration(managers.DefaultPickupWindow),
+			DailyFine:      managers.DefaultDailyFine,
+			MaxFine:        managers.DefaultMaxFine,
+			LostItemCharge: managers.DefaultLostItemCharge,
+			MaxBalance:     managers.DefaultMaxBalance,
+		},
+	}
+}
+
+// flags returns a flag set that sets the fields of the config
+func (c *Config) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	flags.StringVar(&c.Listen, "listen", c.Listen, "the address the server listens on")
+
+	flags.StringVar(&c.Storage.Backend, "storage", c.Storage.Backend, "where the books are stored, either memory, file or sqlite")
+	flags.StringVar(&c.Storage.Path, "data", c.Storage.Path, "the log file used when -storage is file (default books.log), or the database used when it's sqlite (default books.db)")
+
+	flags.StringVar(&c.Log.Level, "log-level", c.Log.Level, "the least severe level that's logged, either debug, info, warning or error")
+	flags.StringVar(&c.Log.Format, "log-format", c.Log.Format, "how the logs are written, either text or json")
+
+	flags.Var(&c.Server.ReadTimeout, "read-timeout", "how long a request, including its body, can take to read")
+	flags.Var(&c.Server.WriteTimeout, "write-timeout", "how long after its headers are read a response can take to write")
+	flags.Var(&c.Server.IdleTimeout, "idle-timeout", "how long a keep-alive connection can wait for its next request")
+	flags.Var(&c.Server.ShutdownTimeout, "shutdown-timeout", "how long requests being handled, and then the webhook deliveries being sent, get to finish once the server is told to shut down")
+	flags.Var(&c.Server.ShutdownDelay, "shutdown-delay", "how long the server keeps accepting connections after it starts failing the readiness probe, at least the probe's period")
+
+	flags.StringVar(&c.Auth.APIKeysFile, "api-keys", c.Auth.APIKeysFile, "a file of api keys, one on each line formatted like role key [subject]")
+	flags.StringVar(&c.Auth.JWTHMACKey, "jwt-hmac-key", c.Auth.JWTHMACKey, "the secret that HS256, HS384 and HS512 tokens are verified with, better given as "+EnvPrefix+"JWT_HMAC_KEY so it isn't in the process list")
+	flags.StringVar(&c.Auth.JWTHMACSecretFile, "jwt-hmac-secret", c.Auth.JWTHMACSecretFile, "a file holding the secret that HS256, HS384 and HS512 tokens are verified with")
+	flags.StringVar(&c.Auth.JWTRSAPublicKeyFile, "jwt-rsa-public-key", c.Auth.JWTRSAPublicKeyFile, "a PEM file holding the public key that RS256, RS384 and RS512 tokens are verified with")
+	flags.StringVar(&c.Auth.JWTIssuer, "jwt-issuer", c.Auth.JWTIssuer, "the iss claim every token must have")
+	flags.StringVar(&c.Auth.JWTAudience, "jwt-audience", c.Auth.JWTAudience, "the aud claim every token must have")
+	flags.Var(&c.Auth.JWTLeeway, "jwt-leeway", "how far off the clocks of the api and whatever issues the tokens can be")
+	flags.BoolVar(&c.Auth.InsecureNoAuth, "insecure-no-auth", c.Auth.InsecureNoAuth, "start without any api keys or JWT keys, which lets anyone call every route")
+
+	flags.Var(&c.Loans.LoanPeriod, "loan-period", "how long after being checked out, or renewed, a book is due")
+	flags.IntVar(&c.Loans.MaxRenewals, "max-renewals", c.Loans.MaxRenewals, "how many times a loan can be renewed")
+	flags.Var(&c.Loans.PickupWindow, "pickup-window", "how long a returned book is reserved for the next patron waiting for it")
+	flags.Int64Var(&c.Loans.DailyFine, "daily-fine", c.Loans.DailyFine, "the fine in cents for each day a book is overdue")
+	flags.Int64Var(&c.Loans.MaxFine, "max-fine", c.Loans.MaxFine, "the most in cents a single overdue loan can be fined, 0 for no cap")
+	flags.Int64Var(&c.Loans.LostItemCharge, "lost-item-charge", c.Loans.LostItemCharge, "the charge in cents for losing a book")
+	flags.Int64Var(&c.Loans.MaxBalance, "max-balance", c.Loans.MaxBalance, "the most in cents a patron can owe and still check out books")
+
+	return flags
+}
+
+// EnvName returns the environment variable a flag can be given as
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Load reads the config from the defaults, then the config file, then the
+// environment and then the command line arguments, each overriding the ones
+// before it. The config file is given with -config, or the BOOKS_API_CONFIG
+// environment variable, and is optional. getenv looks up an environment
+// variable, like os.LookupEnv. The config is validated before it's returned
+func Load(name string, args []string, getenv func(string) (string, bool)) (Config, error) {
+	config := Default()
+
+	flags := config.flags(name)
+	path := flags.String("config", "", "a YAML or JSON config file, flags and "+EnvPrefix+"* environment variables override what's in it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %v:\n", name)
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nEvery flag can also be given as an environment variable, like %v for -loan-period\n", EnvName("loan-period"))
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return config, err
+	}
+
+	// the flags were just written over the defaults, but they have to win
+	// over the file and the environment, so they're set again once those
+	// have been read
+	given := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	if *path == "" {
+		*path, _ = getenv(EnvName("config"))
+	}
+	if *path != "" {
+		err = config.readFile(*path)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		value, found := getenv(EnvName(f.Name))
+		if err != nil || !found || f.Name == "config" {
+			return
+		}
+
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("Invalid value %q for %v: %v", value, EnvName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return config, err
+	}
+
+	for name, value := range given {
+		flags.Set(name, value)
+	}
+
+	return config, config.Validate()
+}
+
+// readFile reads the config file over the config, settings that aren't in
+// the file are left as they are. YAML is a superset of JSON so either can be
+// read, and settings that don't exist are an error, so typos don't go
+// unnoticed
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Unable to open the config file: %v", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(c)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("Unable to read the config file %v: %v", path, err)
+	}
+
+	return nil
+}
+
+// Validate returns an error listing every setting that's invalid
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(c.Listen != "", "listen can't be empty")
+
+	switch c.Storage.Backend {
+	case "memory", "file", "sqlite":
+	default:
+		problems = append(problems, fmt.Sprintf("storage %q must be memory, file or sqlite", c.Storage.Backend))
+	}
+
+	_, err := log.ParseLevel(c.Log.Level)
+	check(err == nil, fmt.Sprintf("log-level %q must be debug, info, warning or error", c.Log.Level))
+	check(c.Log.Format == "text" || c.Log.Format == "json", fmt.Sprintf("log-format %q must be text or json", c.Log.Format))
+
+	check(c.Server.ReadTimeout >= 0, "read-timeout can't be negative")
+	check(c.Server.WriteTimeout >= 0, "write-timeout can't be negative")
+	check(c.Server.IdleTimeout >= 0, "idle-timeout can't be negative")
+	check(c.Server.ShutdownTimeout > 0, "shutdown-timeout must be positive")
+	check(c.Server.ShutdownDelay >= 0, "shutdown-delay can't be negative")
+
+	if c.Auth.JWTHMACKey != "" && c.Auth.JWTHMACSecretFile != "" {
+		problems = append(problems, ErrBothHMACSecrets.Error())
+	}
+	check(c.Auth.JWTLeeway >= 0, "jwt-leeway can't be negative")
+
+	check(c.Loans.LoanPeriod > 0, "loan-period must be positive")
+	check(c.Loans.MaxRenewals >= 0, "max-renewals can't be negative")
+	check(c.Loans.PickupWindow > 0, "pickup-window must be positive")
+	check(c.Loans.DailyFine >= 0, "daily-fine can't be negative")
+	check(c.Loans.MaxFine >= 0, "max-fine can't be negative")
+	check(c.Loans.LostItemCharge >= 0, "lost-item-charge can't be negative")
+	check(c.Loans.MaxBalance >= 0, "max-balance can't be negative")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("The config is invalid: %v", strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// Redact returns a copy of the config with the secrets replaced by
+// Redacted, so it can be shown to people
+func (c Config) Redact() Config {
+	if c.Auth.JWTHMACKey != "" {
+		c.Auth.JWTHMACKey = Redacted
+	}
+
+	return c
+}
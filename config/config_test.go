@@ -0,0 +1,138 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv that looks the variables up in the map
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := vars[name]
+		return value, found
+	}
+}
+
+// writeConfigFile writes a config file into a temp directory and returns its
+// path
+func writeConfigFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Errorf("Error writing the config file: %v", err)
+		t.FailNow()
+	}
+
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load("test", nil, env(nil))
+	if err != nil {
+		t.Errorf("Got error loading the default config: %v", err)
+		t.FailNow()
+	}
+
+	if c != Default() {
+		t.Errorf("Expected the default config, got %+v", c)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "books.yml", `
+listen: ":8080"
+storage:
+  backend: sqlite
+  path: /data/books.db
+loans:
+  loan_period: 72h
+  max_renewals: 5
+  daily_fine: 10
+`)
+
+	c, err := Load("test", []string{"-config", path, "-max-renewals", "1"}, env(map[string]string{
+		"BOOKS_API_MAX_RENEWALS":     "3",
+		"BOOKS_API_DAILY_FINE":       "50",
+		"BOOKS_API_LOG_FORMAT":       "json",
+		"BOOKS_API_INSECURE_NO_AUTH": "true",
+	}))
+	if err != nil {
+		t.Errorf("Got error loading the config: %v", err)
+		t.FailNow()
+	}
+
+	// the flag beats the environment, which beats the file, which beats
+	// the defaults
+	if c.Loans.MaxRenewals != 1 {
+		t.Errorf("Expected the flag's max renewals of 1, got %v", c.Loans.MaxRenewals)
+	}
+	if c.Loans.DailyFine != 50 || c.Log.Format != "json" || !c.Auth.InsecureNoAuth {
+		t.Errorf("Expected the environment's daily fine, log format and insecure-no-auth, got %v %v %v", c.Loans.DailyFine, c.Log.Format, c.Auth.InsecureNoAuth)
+	}
+	if c.Listen != ":8080" || c.Storage.Backend != "sqlite" || time.Duration(c.Loans.LoanPeriod) != 72*time.Hour {
+		t.Errorf("Expected the file's listen address, storage and loan period, got %+v", c)
+	}
+	if c.Loans.MaxFine != Default().Loans.MaxFine {
+		t.Errorf("Expected the default max fine, got %v", c.Loans.MaxFine)
+	}
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := writeConfigFile(t, "books.json", `{"storage": {"backend": "file"}, "server": {"shutdown_timeout": "5s"}}`)
+
+	c, err := Load("test", nil, env(map[string]string{"BOOKS_API_CONFIG": path}))
+	if err != nil {
+		t.Errorf("Got error loading the config: %v", err)
+		t.FailNow()
+	}
+
+	if c.Storage.Backend != "file" || time.Duration(c.Server.ShutdownTimeout) != 5*time.Second {
+		t.Errorf("Expected the JSON file to be read, got %+v", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		err  string
+	}{
+		{name: "unknown setting", file: "storage:\n  backnd: file\n", err: "backnd"},
+		{name: "bad duration in the file", file: "loans:\n  loan_period: soon\n", err: "line 2"},
+		{name: "bad environment variable", env: map[string]string{"BOOKS_API_MAX_FINE": "lots"}, err: "BOOKS_API_MAX_FINE"},
+		{name: "unknown backend", args: []string{"-storage", "tape"}, err: `storage "tape"`},
+		{name: "several problems", args: []string{"-log-level", "loud", "-loan-period", "-1h"}, err: "loan-period must be positive"},
+		{name: "negative shutdown delay", args: []string{"-shutdown-delay", "-1s"}, err: "shutdown-delay can't be negative"},
+		{name: "both secrets", args: []string{"-jwt-hmac-key", "k3y", "-jwt-hmac-secret", "/secret"}, err: ErrBothHMACSecrets.Error()},
+	}
+
+	for _, test := range tests {
+		args := test.args
+		if test.file != "" {
+			args = append(args, "-config", writeConfigFile(t, "books.yml", test.file))
+		}
+
+		_, err := Load("test", args, env(test.env))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected the %v to fail with %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	c := Default()
+	c.Auth.JWTHMACKey = "s3cr3t"
+
+	if c.Redact().Auth.JWTHMACKey != Redacted {
+		t.Errorf("Expected the HMAC key to be redacted")
+	}
+	if c.Auth.JWTHMACKey != "s3cr3t" {
+		t.Errorf("Expected Redact to leave the config alone")
+	}
+}
@@ -1,20 +1,514 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/askewseth/kubernetes/api"
+	"github.com/askewseth/kubernetes/config"
+	"github.com/askewseth/kubernetes/managers"
+	_ "github.com/mattn/go-sqlite3"
 	log "github.com/sirupsen/logrus"
 )
 
+// defaultDataPaths holds the -data default for each storage backend
+var defaultDataPaths = map[string]string{
+	"file":   "books.log",
+	"sqlite": "books.db",
+}
+
+// openStore returns the BookStore for the storage backend given on the
+// command line
+func openStore(storage, dataPath string) (managers.BookStore, error) {
+	if dataPath == "" {
+		dataPath = defaultDataPaths[storage]
+	}
+
+	switch storage {
+	case "memory":
+		return managers.NewLibrary(), nil
+	case "file":
+		return managers.OpenFileStore(dataPath)
+	case "sqlite":
+		return managers.OpenSQLStore("sqlite3", dataPath)
+	}
+
+	return nil, fmt.Errorf("Unknown storage backend %q", storage)
+}
+
+// loadAuthenticator returns the Authenticator for the api keys and JWT keys
+// in the config, nil if there aren't any
+func loadAuthenticator(auth config.AuthConfig) (api.Authenticator, error) {
+	var authenticators api.Authenticators
+
+	if auth.APIKeysFile != "" {
+		f, err := os.Open(auth.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open the api keys: %v", err)
+		}
+		defer f.Close()
+
+		keys, err := api.LoadAPIKeys(f)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, keys)
+	}
+
+	if auth.JWTHMACKey != "" || auth.JWTHMACSecretFile != "" || auth.JWTRSAPublicKeyFile != "" {
+		verifier := api.NewJWTVerifier()
+		verifier.Issuer = auth.JWTIssuer
+		verifier.Audience = auth.JWTAudience
+		verifier.Leeway = time.Duration(auth.JWTLeeway)
+
+		if auth.JWTHMACKey != "" {
+			verifier.AddHMACKey("", []byte(auth.JWTHMACKey))
+		}
+
+		if auth.JWTHMACSecretFile != "" {
+			secret, err := ioutil.ReadFile(auth.JWTHMACSecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read the JWT secret: %v", err)
+			}
+			verifier.AddHMACKey("", bytes.TrimSpace(secret))
+		}
+
+		if auth.JWTRSAPublicKeyFile != "" {
+			pem, err := ioutil.ReadFile(auth.JWTRSAPublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read the JWT public key: %v", err)
+			}
+			err = verifier.AddRSAKeyPEM("", pem)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		authenticators = append(authenticators, verifier)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+
+	return authenticators, nil
+}
+
+// migrate is the migrate subcommand, it brings the schema of a sqlite
+// database up to date
+func migrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dataPath := flags.String("data", defaultDataPaths["sqlite"], "the sqlite database to migrate")
+	flags.Parse(args)
+
+	db, err := sql.Open("sqlite3", *dataPath)
+	if err != nil {
+		log.Fatalf("Error opening the database: %v", err)
+	}
+	defer db.Close()
+
+	applied, err := managers.Migrate(db)
+	for _, m := range applied {
+		fmt.Printf("Applied migration %v\n", m)
+	}
+	if err != nil {
+		log.Fatalf("Error migrating the database: %v", err)
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("The database is already up to date")
+	}
+}
+
+// mappingFlag is a flag that can be given more than once, each time as
+// header=column
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	var pairs []string
+	for header, column := range m {
+		pairs = append(pairs, header+"="+column)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(s string) error {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return fmt.Errorf("%q must be formatted like header=column", s)
+	}
+
+	m[s[:i]] = s[i+1:]
+	return nil
+}
+
+// importFormats holds the function that reads each format the import
+// subcommand accepts
+var importFormats = map[string]func(io.Reader, managers.ImportOptions) (managers.ImportReport, error){
+	"csv":     managers.ImportCSV,
+	"marc":    managers.ImportMARC,
+	"marcxml": managers.ImportMARCXML,
+}
+
+// fileFormat returns the import format for a file from its extension
+func fileFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mrc", ".marc":
+		return "marc"
+	case ".xml":
+		return "marcxml"
+	}
+
+	return "csv"
+}
+
+// importCatalog is the import subcommand, it imports the books in a csv,
+// MARC21 or MARCXML file into the book store the same way as POST /books/import.
+// The book store is the server's, from the same config file and environment
+// variables, unless it's given with -storage and -data
+func importCatalog(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := flags.String("config", "", "the server's config file, the book store is read from it and the "+config.EnvPrefix+"* environment variables")
+	storage := flags.String("storage", "", "where the books are stored, either file or sqlite (default from the server's config)")
+	dataPath := flags.String("data", "", "the log file used when -storage is file, or the database used when it's sqlite (default from the server's config)")
+	dryRun := flags.Bool("dry-run", false, "check every row without changing the books")
+	atomic := flags.Bool("atomic", false, "only import the rows if every one of them can be imported")
+	format := flags.String("format", "", "the format of the catalog, either csv, marc or marcxml (default from the file extension, or csv)")
+	defaultRating := flags.Uint("default-rating", 0, "the rating, 1-3, given to books that don't have one")
+	mapping := mappingFlag{}
+	flags.Var(mapping, "map", "maps a csv header to a column, given as header=column, can be given more than once")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: books-api import [flags] catalog.csv|catalog.mrc|catalog.xml (or - for stdin)")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *format == "" {
+		*format = fileFormat(flags.Arg(0))
+	}
+	importFormat, found := importFormats[*format]
+	if !found {
+		log.Fatalf("Unknown format %q, it must be csv, marc or marcxml", *format)
+	}
+
+	if *defaultRating > 3 {
+		log.Fatalf("The default rating must be 1-3")
+	}
+
+	var file io.Reader = os.Stdin
+	if flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			log.Fatalf("Error opening the catalog: %v", err)
+		}
+		defer f.Close()
+		file = f
+	}
+
+	var configArgs []string
+	if *configPath != "" {
+		configArgs = []string{"-config", *configPath}
+	}
+	c, err := config.Load(os.Args[0], configArgs, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Error loading the config: %v", err)
+	}
+
+	if *storage != "" {
+		c.Storage.Backend = *storage
+	}
+	if *dataPath != "" {
+		c.Storage.Path = *dataPath
+	}
+
+	// the books would be thrown away as soon as the import finished
+	if c.Storage.Backend == "memory" {
+		log.Fatalf("The server's books are only kept in memory, give -storage file or sqlite, or set them in the config, to import into a book store the server can read")
+	}
+
+	store, err := openStore(c.Storage.Backend, c.Storage.Path)
+	if err != nil {
+		log.Fatalf("Error opening the book store: %v", err)
+	}
+
+	err = managers.SetLibrary(store)
+	if err == nil {
+		var report managers.ImportReport
+		report, err = importFormat(file, managers.ImportOptions{
+			Mapping:       mapping,
+			DefaultRating: uint8(*defaultRating),
+			DryRun:        *dryRun,
+			Atomic:        *atomic,
+		})
+
+		fmt.Printf("Read %v rows: %v created, %v updated, %v failed\n", report.Rows, report.Created, report.Updated, report.Failed)
+		for _, rowErr := range report.Errors {
+			fmt.Printf("Row %v: %v\n", rowErr.Row, rowErr.Error)
+		}
+		if err == nil && !report.Applied {
+			fmt.Println("Nothing was changed")
+		}
+		if err == nil && report.Failed > 0 {
+			err = fmt.Errorf("%v rows couldn't be imported", report.Failed)
+		}
+	}
+
+	if closer, ok := store.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		log.Fatalf("Error importing the catalog: %v", err)
+	}
+}
+
+// expireHolds moves reserved books that weren't picked up on to the next
+// patron once a minute, until done is closed
+func expireHolds(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := managers.GetCirculation().ExpireHolds()
+			if err != nil {
+				log.Errorf("Error expiring holds: %v", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// loadLibrary opens the book store and loads the books, the circulation
+// along with its policies, and the webhooks into the managers
+func loadLibrary(c config.Config) (managers.BookStore, error) {
+	store, err := openStore(c.Storage.Backend, c.Storage.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the book store: %v", err)
+	}
+
+	err = managers.SetLibrary(store)
+	if err != nil {
+		closeStore(store)
+		return nil, fmt.Errorf("Error loading the books: %v", err)
+	}
+
+	circulation := managers.NewCirculation()
+	circulation.Policy = managers.LoanPolicy{
+		LoanPeriod:   time.Duration(c.Loans.LoanPeriod),
+		MaxRenewals:  c.Loans.MaxRenewals,
+		PickupWindow: time.Duration(c.Loans.PickupWindow),
+	}
+	circulation.FinePolicy = managers.FinePolicy{
+		DailyFine:      c.Loans.DailyFine,
+		MaxFine:        c.Loans.MaxFine,
+		LostItemCharge: c.Loans.LostItemCharge,
+		MaxBalance:     c.Loans.MaxBalance,
+	}
+
+	// the patrons, loans, holds and ledgers are kept in the book store too
+	if records, ok := store.(managers.RecordStore); ok {
+		err = circulation.Load(records)
+		if err != nil {
+			closeStore(store)
+			return nil, fmt.Errorf("Error loading the circulation: %v", err)
+		}
+	}
+	managers.SetCirculation(circulation)
+
+	// and so are the webhooks, which are started once their pending
+	// deliveries have been loaded
+	webhooks := managers.NewWebhooks()
+	if records, ok := store.(managers.RecordStore); ok {
+		err = webhooks.Load(records)
+		if err != nil {
+			closeStore(store)
+			return nil, fmt.Errorf("Error loading the webhooks: %v", err)
+		}
+	}
+	webhooks.Start(managers.GetEvents())
+	managers.SetWebhooks(webhooks)
+
+	return store, nil
+}
+
+// setupLogging sets the level and format of the logs from the config, which
+// has already been validated
+func setupLogging(c config.LogConfig) {
+	level, _ := log.ParseLevel(c.Level)
+	log.SetLevel(level)
+
+	if c.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+}
+
+// closeStore closes the book store if it needs closing, which for the file
+// store compacts and syncs its log
+func closeStore(store managers.BookStore) {
+	if closer, ok := store.(io.Closer); ok {
+		err := closer.Close()
+		if err != nil {
+			log.Errorf("Error closing the book store: %v", err)
+		}
+	}
+}
+
+// waitForShutdownDelay waits for the delay between failing the readiness
+// probe and closing the listener, a second signal cuts it short
+func waitForShutdownDelay(delay time.Duration, signals <-chan os.Signal) {
+	if delay <= 0 {
+		return
+	}
+
+	log.Infof("Waiting %v for the readiness probe to fail before closing the listener", delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case sig := <-signals:
+		log.Infof("Got %v, not waiting any longer", sig)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrate(os.Args[2:])
+			return
+		case "import":
+			importCatalog(os.Args[2:])
+			return
+		}
+	}
+
+	c, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	setupLogging(c.Log)
+	api.SetConfig(c)
+
+	authenticator, err := loadAuthenticator(c.Auth)
+	if err != nil {
+		log.Fatalf("Error loading the credentials: %v", err)
+	}
+	if authenticator == nil {
+		// without credentials every route, including deleting books, would
+		// be open to anyone, so that has to be asked for
+		if !c.Auth.InsecureNoAuth {
+			log.Fatal("No api keys or JWT keys were given, give -api-keys, -jwt-hmac-key, -jwt-hmac-secret or -jwt-rsa-public-key, or -insecure-no-auth to let anyone call every route")
+		}
+		log.Warn("No api keys or JWT keys were given and -insecure-no-auth is set, so anyone can call every route")
+	} else {
+		api.SetAuthenticator(authenticator)
+	}
+
+	// the server starts listening before the books are loaded, so the
+	// liveness probe passes while a large log is replayed, and the other
+	// routes respond with a 503 until loading has finished
+	api.SetServerState(api.Starting)
+
+	server := &http.Server{
+		Addr:         c.Listen,
+		Handler:      api.GetRouter(),
+		ReadTimeout:  time.Duration(c.Server.ReadTimeout),
+		WriteTimeout: time.Duration(c.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(c.Server.IdleTimeout),
+	}
+
+	// the event streams never finish on their own, so they're ended when
+	// the server shuts down and their clients can reconnect to another pod
+	server.RegisterOnShutdown(managers.GetEvents().Disconnect)
 
-	router := api.GetRouter()
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Infof("Listening on %v", c.Listen)
+		serveErr <- server.ListenAndServe()
+	}()
 
-	fmt.Println("Listening on http://localhost:5555/")
-	err := http.ListenAndServe(":5555", router)
+	store, err := loadLibrary(c)
 	if err != nil {
+		log.Fatal(err)
+	}
+	api.SetServerState(api.Serving)
+
+	done := make(chan struct{})
+	go expireHolds(done)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	// the requests being handled and then the webhook deliveries being sent
+	// share a single shutdown timeout, so the pod's grace period only has to
+	// cover it once
+	var deadline time.Time
+
+	select {
+	case err = <-serveErr:
 		log.Errorf("Error on ListenAndServe: %v", err)
+		deadline = time.Now().Add(time.Duration(c.Server.ShutdownTimeout))
+	case sig := <-signals:
+		log.Infof("Got %v, shutting down", sig)
+
+		// fail the readiness probe, and keep serving until the kubelet has
+		// seen it fail and the pod is taken out of the service, otherwise
+		// the requests still being routed here would be refused
+		api.SetServerState(api.ShuttingDown)
+		waitForShutdownDelay(time.Duration(c.Server.ShutdownDelay), signals)
+
+		// then stop accepting connections and wait for the requests already
+		// being handled to finish
+		deadline = time.Now().Add(time.Duration(c.Server.ShutdownTimeout))
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		err = server.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			log.Errorf("Error waiting for requests to finish, closing their connections: %v", err)
+			server.Close()
+		}
+	}
+
+	close(done)
+
+	// the deliveries being sent get whatever's left of the shutdown timeout
+	// to finish, the ones waiting to be retried are saved and sent after the
+	// next start
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	err = managers.GetWebhooks().Stop(ctx)
+	cancel()
+	if err != nil {
+		log.Errorf("Error waiting for the webhook deliveries to finish: %v", err)
 	}
+
+	closeStore(store)
 }
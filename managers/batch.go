@@ -0,0 +1,276 @@
+package managers
+
+import (
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// MaxBatchSize is the most operations a single batch can hold
+const MaxBatchSize = 10000
+
+// BatchOp is the kind of change a batch operation makes
+type BatchOp = model.BatchOp
+
+// this const block holds the BatchOp values
+const (
+	BatchCreate = model.BatchCreate
+	BatchUpdate = model.BatchUpdate
+	BatchDelete = model.BatchDelete
+)
+
+var (
+	// ErrBatchTooLarge is returned for a batch with more than MaxBatchSize
+	// operations
+	ErrBatchTooLarge = model.ErrBatchTooLarge
+
+	// ErrUnknownBatchOp is returned for an operation that isn't a create,
+	// update or delete
+	ErrUnknownBatchOp = model.ErrUnknownBatchOp
+
+	// ErrBookExists is returned when creating a book with the id of a book
+	// that's already in the library
+	ErrBookExists = model.ErrBookExists
+
+	// ErrRevisionMismatch is returned for an operation whose revision isn't
+	// the book's current revision
+	ErrRevisionMismatch = model.ErrRevisionMismatch
+
+	// ErrBatchAborted is the result of every operation that would have
+	// succeeded in an atomic batch where another operation failed
+	ErrBatchAborted = model.ErrBatchAborted
+)
+
+// BatchOperation is a single change in a batch
+type BatchOperation = model.BatchOperation
+
+// BatchResult is what happened to a single operation in a batch, Book is the
+// book as it was stored, and is left out for deletes and failures
+type BatchResult struct {
+	Op    BatchOp
+	ID    uuid.UUID
+	Book  *model.Book
+	Error error
+}
+
+// ApplyBatch makes the changes in a batch of operations to the global book
+// store in order, returning a result for each of them. Each operation sees
+// the library as the operations before it left it.
+//
+// If atomic is true then either every operation is applied together or, if
+// any of them fail, none of them are. Otherwise each operation that can be
+// applied is, regardless of the ones that fail. The error is only for the
+// batch as a whole, such as the book store failing
+func ApplyBatch(operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if len(operations) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	return applyBatch(operations, atomic, false, ValidateBook)
+}
+
+// CheckBatch returns the results that ApplyBatch would, without changing the
+// library. Books that fail validation aren't counted in ValidationFailures
+// since nothing was really sent to the library
+func CheckBatch(operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	return applyBatch(operations, atomic, true, model.Book.Validate)
+}
+
+// applyBatch is ApplyBatch without a limit on the size of the batch, when
+// dryRun is true the operations are worked through but nothing is written.
+// Every created or updated book is checked with validate
+func applyBatch(operations []BatchOperation, atomic, dryRun bool, validate func(model.Book) error) ([]BatchResult, error) {
+	// books that are checked out or have holds can't be deleted, so the
+	// circulation is locked for the whole batch so that nothing can be
+	// checked out between the check and the delete. Circulation takes its
+	// lock before the update lock, so the batch does too
+	circulation := GetCirculation()
+	circulation.Lock()
+	defer circulation.Unlock()
+
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	batch := &batchPlan{
+		library:     GetLibrary(),
+		pending:     make(map[uuid.UUID]*model.Book),
+		circulation: circulation,
+		validate:    validate,
+	}
+
+	results := make([]BatchResult, len(operations))
+	var changes []Change
+	failed := false
+
+	for i, operation := range operations {
+		change, err := batch.plan(operation)
+		results[i] = batchResult(operation, change, err)
+		if err != nil {
+			failed = true
+			continue
+		}
+
+		if !atomic && !dryRun {
+			err = batch.library.ApplyChanges([]Change{change})
+			if err != nil {
+				results[i] = batchResult(operation, change, err)
+				continue
+			}
+		}
+
+		batch.record(change)
+		changes = append(changes, change)
+	}
+
+	if !atomic || len(changes) == 0 {
+		return results, nil
+	}
+
+	if failed {
+		for i := range results {
+			if results[i].Error == nil {
+				results[i].Error = ErrBatchAborted
+				results[i].Book = nil
+			}
+		}
+		return results, nil
+	}
+
+	if dryRun {
+		return results, nil
+	}
+
+	err := batch.library.ApplyChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// batchResult returns the result of an operation given the change it made
+func batchResult(operation BatchOperation, change Change, err error) BatchResult {
+	result := BatchResult{Op: operation.Op, ID: change.Book.ID, Error: err}
+	if err != nil {
+		result.ID = operation.ID
+		if operation.Op == BatchCreate {
+			result.ID = operation.Book.ID
+		}
+		return result
+	}
+
+	if change.Type != BookDeleted {
+		book := change.Book
+		result.Book = &book
+	}
+
+	return result
+}
+
+// batchPlan tracks what the library will look like as the operations in a
+// batch are worked through
+type batchPlan struct {
+	library BookStore
+
+	// pending holds the books changed by earlier operations in the batch,
+	// nil for books that were deleted
+	pending map[uuid.UUID]*model.Book
+
+	// circulation says which books can't be deleted, it's locked for as
+	// long as the batch is being worked through
+	circulation *Circulation
+
+	// validate checks each book that's created or updated
+	validate func(model.Book) error
+}
+
+// lookup returns a book as the earlier operations in the batch left it
+func (b *batchPlan) lookup(id uuid.UUID) (model.Book, error) {
+	if book, found := b.pending[id]; found {
+		if book == nil {
+			return model.Book{}, ErrNoBookWithThatID
+		}
+		return *book, nil
+	}
+
+	return b.library.GetBookByID(id)
+}
+
+// record remembers the change made by an operation for the ones after it
+func (b *batchPlan) record(change Change) {
+	if change.Type == BookDeleted {
+		b.pending[change.Book.ID] = nil
+		return
+	}
+
+	book := change.Book
+	b.pending[book.ID] = &book
+}
+
+// plan works out the change an operation makes, or why it can't be made
+func (b *batchPlan) plan(operation BatchOperation) (Change, error) {
+	switch operation.Op {
+	case BatchCreate:
+		book := operation.Book
+		if uuid.Equal(book.ID, uuid.Nil) {
+			book.ID, _ = uuid.NewV4()
+		}
+
+		_, err := b.lookup(book.ID)
+		if err == nil {
+			return Change{}, ErrBookExists
+		}
+		if err != ErrNoBookWithThatID {
+			return Change{}, err
+		}
+
+		// the status comes from the book's loans, so new books start out
+		// CheckedIn
+		book.Status = model.CheckedIn
+		book.Revision = 1
+
+		if err = b.validate(book); err != nil {
+			return Change{}, err
+		}
+
+		return Change{Type: BookCreated, Book: book}, nil
+
+	case BatchUpdate:
+		current, err := b.lookup(operation.ID)
+		if err != nil {
+			return Change{}, err
+		}
+
+		if operation.Revision != 0 && operation.Revision != current.Revision {
+			return Change{}, ErrRevisionMismatch
+		}
+
+		book := operation.Book
+		book.ID = current.ID
+		book.Status = current.Status
+		book.Revision = current.Revision + 1
+
+		if err = b.validate(book); err != nil {
+			return Change{}, err
+		}
+
+		return Change{Type: BookUpdated, Book: book}, nil
+
+	case BatchDelete:
+		current, err := b.lookup(operation.ID)
+		if err != nil {
+			return Change{}, err
+		}
+
+		if operation.Revision != 0 && operation.Revision != current.Revision {
+			return Change{}, ErrRevisionMismatch
+		}
+
+		if err = b.circulation.removable(operation.ID); err != nil {
+			return Change{}, err
+		}
+
+		return Change{Type: BookDeleted, Book: current}, nil
+	}
+
+	return Change{}, ErrUnknownBatchOp
+}
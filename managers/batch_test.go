@@ -0,0 +1,120 @@
+package managers
+
+import (
+	"testing"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// newBatchBook returns a valid book with the given title for a batch
+func newBatchBook(title string) model.Book {
+	book := model.NewBook()
+	book.Title = title
+	book.Rating = 2
+
+	return book
+}
+
+func TestApplyBatch(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	existing := newBatchBook("MyExistingBook")
+	GetLibrary().AddBook(existing)
+
+	created := newBatchBook("MyCreatedBook")
+	results, err := ApplyBatch([]BatchOperation{
+		{Op: BatchCreate, Book: created},
+		// later operations see the earlier ones
+		{Op: BatchUpdate, ID: created.ID, Revision: 1, Book: newBatchBook("MyRenamedBook")},
+		{Op: BatchDelete, ID: existing.ID},
+	}, true)
+	if err != nil {
+		t.Errorf("Error applying batch: %v", err)
+		t.FailNow()
+	}
+
+	for i, result := range results {
+		if result.Error != nil {
+			t.Errorf("Operation %v failed: %v", i, result.Error)
+		}
+	}
+
+	books, _ := GetLibrary().GetBooks()
+	if len(books) != 1 || books[0].ID != created.ID || books[0].Title != "MyRenamedBook" || books[0].Revision != 2 {
+		t.Errorf("Expected just the renamed book after the batch, got %+v", books)
+	}
+
+	if results[1].Book == nil || *results[1].Book != books[0] {
+		t.Errorf("Expected the update's result to have the stored book, got %+v", results[1])
+	}
+}
+
+func TestApplyBatchAtomic(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	existing := newBatchBook("MyExistingBook")
+	GetLibrary().AddBook(existing)
+
+	invalid := newBatchBook("MyInvalidBook")
+	invalid.Rating = 0
+
+	operations := []BatchOperation{
+		{Op: BatchCreate, Book: newBatchBook("MyCreatedBook")},
+		{Op: BatchCreate, Book: invalid},
+		{Op: BatchUpdate, ID: existing.ID, Revision: 7, Book: newBatchBook("MyStaleBook")},
+		{Op: BatchCreate, Book: existing},
+		{Op: "rename"},
+	}
+
+	results, err := ApplyBatch(operations, true)
+	if err != nil {
+		t.Errorf("Error applying batch: %v", err)
+		t.FailNow()
+	}
+
+	expected := []error{ErrBatchAborted, model.ErrInvalidRating, ErrRevisionMismatch, ErrBookExists, ErrUnknownBatchOp}
+	for i, result := range results {
+		if result.Error != expected[i] {
+			t.Errorf("Expected operation %v to fail with %v, got %v", i, expected[i], result.Error)
+		}
+	}
+
+	if countBooks(t, GetLibrary()) != 1 {
+		t.Errorf("A failed atomic batch changed the library")
+	}
+
+	// the same batch in best effort mode applies the operation that can be
+	results, err = ApplyBatch(operations, false)
+	if err != nil {
+		t.Errorf("Error applying batch: %v", err)
+		t.FailNow()
+	}
+
+	if results[0].Error != nil || results[1].Error != model.ErrInvalidRating {
+		t.Errorf("Expected only the first operation to succeed, got %+v", results)
+	}
+
+	if countBooks(t, GetLibrary()) != 2 {
+		t.Errorf("A best effort batch didn't apply the operation that succeeded")
+	}
+}
+
+func TestApplyBatchCheckedOut(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	SetCirculation(circulation)
+	defer SetCirculation(NewCirculation())
+
+	circulation.Checkout(book.ID, patron.ID)
+
+	results, err := ApplyBatch([]BatchOperation{{Op: BatchDelete, ID: book.ID}}, true)
+	if err != nil || results[0].Error != ErrBookCheckedOut {
+		t.Errorf("Expected %v deleting a checked out book, got %+v (%v)", ErrBookCheckedOut, results, err)
+	}
+
+	tooMany := make([]BatchOperation, MaxBatchSize+1)
+	if _, err = ApplyBatch(tooMany, true); err != ErrBatchTooLarge {
+		t.Errorf("Expected %v for a batch that's too large, got %v", ErrBatchTooLarge, err)
+	}
+}
@@ -0,0 +1,337 @@
+package managers
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// The global book store instance
+	store BookStore
+
+	// guards store so that SetLibrary can be called while handlers are running
+	storeMu sync.RWMutex
+
+	// makes UpdateBook's read, change and write a single step
+	updateMu sync.Mutex
+
+	// held by observedStore from making a change until the listeners have
+	// been notified of it, so they hear about changes in the order they
+	// were made
+	changeMu sync.Mutex
+
+	// the functions that are called whenever the global book store changes
+	listeners   []func(Change)
+	listenersMu sync.RWMutex
+)
+
+// BookStore is the set of operations that every storage backend for the
+// library has to support, the api handlers only ever talk to a BookStore
+// so backends can be swapped out without touching the HTTP layer
+type BookStore interface {
+	// GetBooks returns a slice of all of the books in the store sorted by title
+	GetBooks() ([]model.Book, error)
+
+	// GetBookByID returns the book with the given id, or ErrNoBookWithThatID
+	GetBookByID(id uuid.UUID) (model.Book, error)
+
+	// AddBook stores a new book, overwriting any book with the same id
+	AddBook(book model.Book) error
+
+	// ModifyBook replaces the book with the same id as the given book, or
+	// returns ErrNoBookWithThatID
+	ModifyBook(book model.Book) error
+
+	// DeleteBook removes the book with the given id, or returns
+	// ErrNoBookWithThatID
+	DeleteBook(id uuid.UUID) error
+
+	// ApplyChanges makes all of the changes or, if any of them fail, none of
+	// them. Created and updated books are stored as they're given, replacing
+	// any book with the same id, and deleted books are removed if they exist
+	ApplyChanges(changes []Change) error
+}
+
+// Pinger is implemented by the BookStores that keep their books somewhere
+// that can become unavailable while the process is running
+type Pinger interface {
+	// Ping returns an error if the books can't be read or written
+	Ping() error
+}
+
+// Record is a json value kept by a RecordStore under a kind and an id, a
+// Record without a Value deletes the record when it's saved
+type Record struct {
+	Kind  string
+	ID    uuid.UUID
+	Value json.RawMessage
+}
+
+// RecordStore is implemented by the BookStores that can keep other records
+// alongside the books, which is how the circulation's patrons, loans, holds
+// and ledgers, and the webhooks and their deliveries, survive restarts in
+// the same place as the books
+type RecordStore interface {
+	// SaveRecords stores every record, replacing any with the same kind and
+	// id, or deletes them if they don't have a value. Either all of them are
+	// saved or, if any of them fail, none of them are
+	SaveRecords(records []Record) error
+
+	// GetRecords returns every record of the kind, in no particular order
+	GetRecords(kind string) ([]Record, error)
+}
+
+// UpdateBook reads a book from the global book store, lets update change it
+// and writes it back with the next revision. Calls to UpdateBook and
+// RemoveBook are serialized so that two changes to the same book can't
+// interleave and lose one of them, which means update can safely check the
+// book's revision. If update returns an error the book isn't written and the
+// error is returned. Circulation calls UpdateBook while holding its own lock,
+// so update must not call into the circulation
+func UpdateBook(id uuid.UUID, update func(book *model.Book) error) (model.Book, error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	library := GetLibrary()
+	book, err := library.GetBookByID(id)
+	if err != nil {
+		return book, err
+	}
+
+	revision := book.Revision
+
+	err = update(&book)
+	if err != nil {
+		return book, err
+	}
+
+	// the id and revision can't be changed by an update
+	book.ID = id
+	book.Revision = revision + 1
+
+	return book, library.ModifyBook(book)
+}
+
+// RemoveBook deletes a book from the global book store if check, which is
+// given the book as it is now, doesn't return an error. Like UpdateBook the
+// check and the delete happen without any other change getting in between.
+// Circulation.RemoveBook calls it while holding the circulation's lock, with
+// a check that looks at the book's loans and holds, so check must not take
+// the circulation's lock itself
+func RemoveBook(id uuid.UUID, check func(book model.Book) error) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	library := GetLibrary()
+	book, err := library.GetBookByID(id)
+	if err != nil {
+		return err
+	}
+
+	err = check(book)
+	if err != nil {
+		return err
+	}
+
+	return library.DeleteBook(id)
+}
+
+// ChangeType is the kind of change that was made to a book
+type ChangeType string
+
+// this const block holds the ChangeType values
+const (
+	BookCreated ChangeType = "created"
+	BookUpdated ChangeType = "updated"
+	BookDeleted ChangeType = "deleted"
+)
+
+// Change describes a book that was changed in the global book store, for
+// deleted books Book is the book as it was before it was deleted
+type Change struct {
+	Type ChangeType
+	Book model.Book
+
+	// Previous is the book as it was before it was updated, it's only set
+	// for the updates that listeners are notified of
+	Previous *model.Book
+}
+
+// OnChange registers a function that's called after every successful change
+// to the global book store. The function is called synchronously by the
+// goroutine that made the change, before any other change can be made, so
+// it has to be quick and mustn't change the books itself
+func OnChange(listener func(Change)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	listeners = append(listeners, listener)
+}
+
+// notify calls every listener with the change
+func notify(change Change) {
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// observedStore wraps the global BookStore so that every change made
+// through it is sent to the listeners. Changes are made one at a time, each
+// along with its notification, so a listener like the search index can't
+// hear about an add after the delete that followed it
+type observedStore struct {
+	BookStore
+}
+
+// AddBook adds the book as its first revision and notifies the listeners
+func (o observedStore) AddBook(book model.Book) error {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	book.Revision = 1
+
+	err := o.BookStore.AddBook(book)
+	if err != nil {
+		return err
+	}
+
+	notify(Change{Type: BookCreated, Book: book})
+	return nil
+}
+
+// ModifyBook modifies the book and notifies the listeners with the book as
+// it is after the modification
+func (o observedStore) ModifyBook(book model.Book) error {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	previous, err := o.BookStore.GetBookByID(book.ID)
+	if err != nil {
+		return err
+	}
+
+	err = o.BookStore.ModifyBook(book)
+	if err != nil {
+		return err
+	}
+
+	modified, err := o.BookStore.GetBookByID(book.ID)
+	if err != nil {
+		return err
+	}
+
+	notify(Change{Type: BookUpdated, Book: modified, Previous: &previous})
+	return nil
+}
+
+// DeleteBook deletes the book and notifies the listeners with the book as
+// it was before it was deleted
+func (o observedStore) DeleteBook(id uuid.UUID) error {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	book, err := o.BookStore.GetBookByID(id)
+	if err != nil {
+		return err
+	}
+
+	err = o.BookStore.DeleteBook(id)
+	if err != nil {
+		return err
+	}
+
+	notify(Change{Type: BookDeleted, Book: book})
+	return nil
+}
+
+// ApplyChanges makes the changes and then notifies the listeners of each of
+// them in order, along with the book each update replaced
+func (o observedStore) ApplyChanges(changes []Change) error {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	previous := make([]*model.Book, len(changes))
+	for i, change := range changes {
+		if change.Type != BookUpdated {
+			continue
+		}
+		if book, err := o.BookStore.GetBookByID(change.Book.ID); err == nil {
+			previous[i] = &book
+		}
+	}
+
+	err := o.BookStore.ApplyChanges(changes)
+	if err != nil {
+		return err
+	}
+
+	for i, change := range changes {
+		change.Previous = previous[i]
+		notify(change)
+	}
+	return nil
+}
+
+// GetLibrary is a thread safe singleton which will, on the first time being
+// called, initalize a new in memory library, and on subsequent calls return
+// that same BookStore (or whichever store was given to SetLibrary)
+func GetLibrary() BookStore {
+	storeMu.RLock()
+	s := store
+	storeMu.RUnlock()
+
+	if s != nil {
+		return s
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	// if this is the first time this function has been called
+	// then create a new in memory library
+	if store == nil {
+		store = observedStore{NewLibrary()}
+		searchIndex.Reset(nil)
+	}
+
+	return store
+}
+
+// PingLibrary returns an error if the global BookStore is unavailable, stores
+// that aren't Pingers are always available
+func PingLibrary() error {
+	s := GetLibrary()
+	if observed, ok := s.(observedStore); ok {
+		s = observed.BookStore
+	}
+
+	if pinger, ok := s.(Pinger); ok {
+		return pinger.Ping()
+	}
+
+	return nil
+}
+
+// SetLibrary replaces the global BookStore, it's meant to be called once at
+// startup to select a storage backend, and by tests to start from a clean
+// store. The search index is rebuilt from the books already in the store
+func SetLibrary(s BookStore) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	books, err := s.GetBooks()
+	if err != nil {
+		return err
+	}
+
+	store = observedStore{s}
+	searchIndex.Reset(books)
+
+	return nil
+}
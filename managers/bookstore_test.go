@@ -0,0 +1,375 @@
+package managers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/satori/go.uuid"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// testBookStore is the conformance suite that every BookStore implementation
+// has to pass, newStore has to return a new empty store each time it's called
+func testBookStore(t *testing.T, newStore func(t *testing.T) BookStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store BookStore)
+	}{
+		{"AddBook", testAddBook},
+		{"GetBooks", testGetBooks},
+		{"GetBookByID", testGetBookByID},
+		{"Modify", testModify},
+		{"Delete", testDelete},
+		{"ApplyChanges", testApplyChanges},
+		{"Records", testRecords},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newStore(t))
+		})
+	}
+}
+
+// countBooks returns the number of books in the store, failing the test if
+// the store can't list its books
+func countBooks(t *testing.T, store BookStore) int {
+	books, err := store.GetBooks()
+	if err != nil {
+		t.Errorf("Error getting books: %v", err)
+		t.FailNow()
+	}
+
+	return len(books)
+}
+
+func testAddBook(t *testing.T, store BookStore) {
+	// verify the store is empty
+	if countBooks(t, store) != 0 {
+		t.Errorf("Store wasn't empty to begin with")
+		t.FailNow()
+	}
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+
+	err := store.AddBook(book)
+	if err != nil {
+		t.Errorf("Error adding book: %v", err)
+		t.FailNow()
+	}
+
+	found, err := store.GetBookByID(book.ID)
+	if err != nil || found.Title != "MyBook" {
+		t.Errorf("Added in a book using AddBook but the book wasn't found after the fact")
+	}
+}
+
+func testGetBooks(t *testing.T, store BookStore) {
+	book := model.NewBook()
+	book.Title = "MyBook"
+
+	store.AddBook(book)
+
+	otherBook := model.NewBook()
+	otherBook.Title = "AnotherBook"
+
+	store.AddBook(otherBook)
+
+	books, err := store.GetBooks()
+	if err != nil {
+		t.Errorf("Error getting books: %v", err)
+		t.FailNow()
+	}
+
+	// verify both books are there
+	if len(books) != 2 {
+		t.Errorf("Didn't have 2 books in the store after adding 2 books")
+		t.FailNow()
+	}
+
+	if books[0].Title != otherBook.Title || books[1].Title != book.Title {
+		t.Errorf("GetBooks didn't return the books sorted by title, got %+v", books)
+	}
+}
+
+func testGetBookByID(t *testing.T, store BookStore) {
+	book := model.NewBook()
+	book.Title = "MyBook"
+	book.Author = "me"
+	book.Rating = 2
+
+	store.AddBook(book)
+
+	found, err := store.GetBookByID(book.ID)
+	if err != nil {
+		t.Errorf("Error getting book by ID: %v", err)
+		t.FailNow()
+	}
+
+	if found.Title != book.Title || found.Author != book.Author || found.Rating != book.Rating {
+		t.Errorf("GetBookByID returned %+v, expected %+v", found, book)
+	}
+
+	// try to get a non-existing book and make sure it errors
+	bogusID, _ := uuid.NewV4()
+	_, err = store.GetBookByID(bogusID)
+	if err != ErrNoBookWithThatID {
+		t.Errorf("Expected to get %v error when calling GetBookByID with bogus ID but got %v", ErrNoBookWithThatID, err)
+	}
+}
+
+func testModify(t *testing.T, store BookStore) {
+	// create and add a known book
+	book := model.NewBook()
+	book.Title = "MyBook"
+	book.Author = "me"
+
+	store.AddBook(book)
+
+	// verify the book was added
+	if countBooks(t, store) != 1 {
+		t.Errorf("Didn't have 1 book in the store after adding 1 book")
+		t.FailNow()
+	}
+
+	// replace the book with one that only has a title
+	modBook := model.Book{ID: book.ID, Title: "MyNewBook"}
+	err := store.ModifyBook(modBook)
+	if err != nil {
+		t.Errorf("Error modifing book: %v", err)
+		t.FailNow()
+	}
+
+	newBook, err := store.GetBookByID(book.ID)
+	if err != nil {
+		t.Errorf("Error getting book by ID: %v", err)
+		t.FailNow()
+	}
+
+	if newBook.Title != modBook.Title {
+		t.Errorf("ModifyBook failed to modify a given attribute")
+	}
+
+	if newBook.Author != "" {
+		t.Errorf("ModifyBook kept a field that wasn't in the new book")
+	}
+
+	// try to modify a non-existing book and make sure it errors
+	bogusID, _ := uuid.NewV4()
+	modBook.ID = bogusID
+	err = store.ModifyBook(modBook)
+	if err != ErrNoBookWithThatID {
+		t.Errorf("Expected to get %v error when calling ModifyBook with bogus ID but got %v", ErrNoBookWithThatID, err)
+	}
+}
+
+func testDelete(t *testing.T, store BookStore) {
+	// create and add a known book
+	book := model.NewBook()
+	book.Title = "MyBook"
+
+	store.AddBook(book)
+
+	// verify the book was added
+	if countBooks(t, store) != 1 {
+		t.Errorf("Didn't have 1 book in the store after adding 1 book")
+		t.FailNow()
+	}
+
+	// try to delete the book
+	err := store.DeleteBook(book.ID)
+	if err != nil {
+		t.Errorf("Got an error while trying to delete a book: %v", err)
+	}
+
+	if countBooks(t, store) != 0 {
+		t.Errorf("Didn't correctly delete the book from the store, still had 1 book after delete")
+	}
+
+	// try to delete a non-existing book and make sure it errors
+	bogusID, _ := uuid.NewV4()
+	err = store.DeleteBook(bogusID)
+	if err != ErrNoBookWithThatID {
+		t.Errorf("Expected to get %v error when calling DeleteBook with bogus ID but got %v", ErrNoBookWithThatID, err)
+	}
+}
+
+func testApplyChanges(t *testing.T, store BookStore) {
+	kept := model.NewBook()
+	kept.Title = "MyKeptBook"
+	store.AddBook(kept)
+
+	deleted := model.NewBook()
+	deleted.Title = "MyDeletedBook"
+	store.AddBook(deleted)
+
+	created := model.NewBook()
+	created.Title = "MyCreatedBook"
+
+	updated := kept
+	updated.Title = "MyUpdatedBook"
+	updated.Revision = 2
+
+	err := store.ApplyChanges([]Change{
+		{Type: BookCreated, Book: created},
+		{Type: BookUpdated, Book: updated},
+		{Type: BookDeleted, Book: deleted},
+	})
+	if err != nil {
+		t.Errorf("Error applying changes: %v", err)
+		t.FailNow()
+	}
+
+	books, _ := store.GetBooks()
+	if len(books) != 2 || books[0].ID != created.ID || books[1] != updated {
+		t.Errorf("Expected the created and updated books after applying changes, got %+v", books)
+	}
+
+	if _, err = store.GetBookByID(deleted.ID); err != ErrNoBookWithThatID {
+		t.Errorf("Expected the deleted book to be gone after applying changes, got %v", err)
+	}
+}
+
+func testRecords(t *testing.T, store BookStore) {
+	records, ok := store.(RecordStore)
+	if !ok {
+		t.Skip("The store doesn't keep records")
+	}
+
+	kept, _ := uuid.NewV4()
+	deleted, _ := uuid.NewV4()
+
+	err := records.SaveRecords([]Record{
+		{Kind: "patron", ID: kept, Value: json.RawMessage(`{"name":"Me"}`)},
+		{Kind: "patron", ID: deleted, Value: json.RawMessage(`{"name":"You"}`)},
+		{Kind: "loan", ID: kept, Value: json.RawMessage(`{}`)},
+	})
+	if err != nil {
+		t.Errorf("Error saving records: %v", err)
+		t.FailNow()
+	}
+
+	err = records.SaveRecords([]Record{
+		{Kind: "patron", ID: kept, Value: json.RawMessage(`{"name":"Still me"}`)},
+		{Kind: "patron", ID: deleted},
+	})
+	if err != nil {
+		t.Errorf("Error saving records: %v", err)
+		t.FailNow()
+	}
+
+	patrons, err := records.GetRecords("patron")
+	if err != nil || len(patrons) != 1 || patrons[0].ID != kept || string(patrons[0].Value) != `{"name":"Still me"}` {
+		t.Errorf("Expected only the replaced patron record, got %+v (%v)", patrons, err)
+	}
+
+	loans, err := records.GetRecords("loan")
+	if err != nil || len(loans) != 1 || loans[0].Kind != "loan" {
+		t.Errorf("Expected the loan record to be kept separately, got %+v (%v)", loans, err)
+	}
+
+	// records aren't books
+	if countBooks(t, store) != 0 {
+		t.Errorf("Saving records added books to the store")
+	}
+}
+
+func TestUpdateBook(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	book.Author = "me"
+	GetLibrary().AddBook(book)
+
+	updated, err := UpdateBook(book.ID, func(b *model.Book) error {
+		b.Title = "MyNewBook"
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Error updating book: %v", err)
+		t.FailNow()
+	}
+
+	stored, _ := GetLibrary().GetBookByID(book.ID)
+	if updated != stored || stored.Title != "MyNewBook" || stored.Author != "me" {
+		t.Errorf("UpdateBook didn't store the updated book, got %+v", stored)
+	}
+
+	if stored.Revision != 2 {
+		t.Errorf("Expected the book to be at revision 2 after being added and updated, got %v", stored.Revision)
+	}
+
+	// an error from the update leaves the book alone
+	_, err = UpdateBook(book.ID, func(b *model.Book) error {
+		b.Title = "Discarded"
+		return ErrInvalidSortField
+	})
+	if err != ErrInvalidSortField {
+		t.Errorf("Expected UpdateBook to return the update's error, got %v", err)
+	}
+
+	stored, _ = GetLibrary().GetBookByID(book.ID)
+	if stored.Title != "MyNewBook" {
+		t.Errorf("UpdateBook stored a book after the update failed, got %+v", stored)
+	}
+
+	bogusID, _ := uuid.NewV4()
+	if _, err = UpdateBook(bogusID, func(b *model.Book) error { return nil }); err != ErrNoBookWithThatID {
+		t.Errorf("Expected to get %v error when calling UpdateBook with bogus ID but got %v", ErrNoBookWithThatID, err)
+	}
+}
+
+func TestRemoveBook(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	book := model.NewBook()
+	GetLibrary().AddBook(book)
+
+	err := RemoveBook(book.ID, func(b model.Book) error { return ErrInvalidSortField })
+	if err != ErrInvalidSortField {
+		t.Errorf("Expected RemoveBook to return the check's error, got %v", err)
+	}
+
+	if countBooks(t, GetLibrary()) != 1 {
+		t.Errorf("RemoveBook deleted the book after the check failed")
+	}
+
+	err = RemoveBook(book.ID, func(b model.Book) error { return nil })
+	if err != nil || countBooks(t, GetLibrary()) != 0 {
+		t.Errorf("RemoveBook didn't delete the book: %v", err)
+	}
+}
+
+func TestListenersHearChangesInOrder(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	// an add racing a delete of the same book leaves the search index
+	// agreeing with the store, whichever of them wins
+	for i := 0; i < 200; i++ {
+		book := model.NewBook()
+		book.Title = "MyRacingBook"
+
+		done := make(chan struct{})
+		go func() {
+			GetLibrary().AddBook(book)
+			close(done)
+		}()
+		GetLibrary().DeleteBook(book.ID)
+		<-done
+
+		_, err := GetLibrary().GetBookByID(book.ID)
+		indexed := len(GetSearchIndex().Search("MyRacingBook")) > 0
+		if indexed != (err == nil) {
+			t.Errorf("The search index has the book %v, but the store has it %v", indexed, err == nil)
+			t.FailNow()
+		}
+
+		GetLibrary().DeleteBook(book.ID)
+	}
+}
@@ -0,0 +1,376 @@
+package managers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// DefaultLoanPeriod is how long a book can be checked out for
+const DefaultLoanPeriod = 14 * 24 * time.Hour
+
+// DefaultMaxRenewals is how many times a loan can be renewed
+const DefaultMaxRenewals = 2
+
+// DefaultPickupWindow is how long a returned book is reserved for the patron
+// at the front of its holds queue
+const DefaultPickupWindow = 3 * 24 * time.Hour
+
+// LoanPolicy holds the rules for lending books
+type LoanPolicy struct {
+	// LoanPeriod is how long after being checked out, or renewed, a book is due
+	LoanPeriod time.Duration
+
+	// MaxRenewals is how many times a single loan can be renewed
+	MaxRenewals int
+
+	// PickupWindow is how long a returned book is reserved for the patron at
+	// the front of its holds queue before it moves on to the next patron
+	PickupWindow time.Duration
+}
+
+// DefaultLoanPolicy returns the LoanPolicy that new circulations start with
+func DefaultLoanPolicy() LoanPolicy {
+	return LoanPolicy{
+		LoanPeriod:   DefaultLoanPeriod,
+		MaxRenewals:  DefaultMaxRenewals,
+		PickupWindow: DefaultPickupWindow,
+	}
+}
+
+var (
+	// The global circulation instance
+	circulation *Circulation
+
+	// guards circulation so that SetCirculation can be called while
+	// handlers are running
+	circulationMu sync.RWMutex
+
+	// ErrNoPatronWithThatID is the error returned whenever someone tries to
+	// use a patron with an id that isn't found
+	ErrNoPatronWithThatID = model.ErrNoPatronWithThatID
+
+	// ErrBookCheckedOut is returned when trying to check out a book that
+	// already has an active loan
+	ErrBookCheckedOut = model.ErrBookCheckedOut
+
+	// ErrBookNotCheckedOut is returned when trying to return or renew a book
+	// that doesn't have an active loan
+	ErrBookNotCheckedOut = model.ErrBookNotCheckedOut
+
+	// ErrMaxRenewals is returned when trying to renew a loan that's already
+	// been renewed as many times as the policy allows
+	ErrMaxRenewals = model.ErrMaxRenewals
+
+	// ErrRenewalBlockedByHolds is returned when trying to renew a loan on a
+	// book that other patrons are waiting for
+	ErrRenewalBlockedByHolds = model.ErrRenewalBlockedByHolds
+)
+
+// Circulation holds the library's patrons and the loans of books to them.
+// The loans are the source of truth for a book's status, checking a book
+// out or returning it updates the book's status in the global book store
+type Circulation struct {
+	sync.Mutex
+
+	patrons map[uuid.UUID]model.Patron
+	loans   map[uuid.UUID]model.Loan
+
+	// active maps the id of each checked out book to the id of its loan
+	active map[uuid.UUID]uuid.UUID
+
+	// holds maps the id of each book to its queue of holds, first in first out
+	holds map[uuid.UUID][]model.Hold
+
+	// ledgers maps the id of each patron to the charges and credits on their
+	// account, in the order they were made
+	ledgers map[uuid.UUID][]model.LedgerEntry
+
+	// Policy holds the rules for lending books
+	Policy LoanPolicy
+
+	// FinePolicy holds the rules for charging patrons
+	FinePolicy FinePolicy
+
+	// Clock is where the current time comes from
+	Clock Clock
+
+	// records is where the circulation is saved, it's nil until Load is
+	// called and then nothing is saved. pending holds the records changed
+	// since they were last saved
+	records RecordStore
+	pending []Record
+}
+
+// NewCirculation returns a new circulation with no patrons or loans
+func NewCirculation() *Circulation {
+	return &Circulation{
+		patrons: make(map[uuid.UUID]model.Patron),
+		loans:   make(map[uuid.UUID]model.Loan),
+		active:  make(map[uuid.UUID]uuid.UUID),
+		holds:   make(map[uuid.UUID][]model.Hold),
+		ledgers: make(map[uuid.UUID][]model.LedgerEntry),
+
+		Policy:     DefaultLoanPolicy(),
+		FinePolicy: DefaultFinePolicy(),
+		Clock:      realClock{},
+	}
+}
+
+// GetCirculation is a thread safe singleton which will, on the first time
+// being called, initalize a new circulation, and on subsequent calls return
+// that same instance (or whichever was given to SetCirculation)
+func GetCirculation() *Circulation {
+	circulationMu.RLock()
+	c := circulation
+	circulationMu.RUnlock()
+
+	if c != nil {
+		return c
+	}
+
+	circulationMu.Lock()
+	defer circulationMu.Unlock()
+
+	if circulation == nil {
+		circulation = NewCirculation()
+	}
+
+	return circulation
+}
+
+// SetCirculation replaces the global circulation, it's used by tests to start
+// from a clean slate
+func SetCirculation(c *Circulation) {
+	circulationMu.Lock()
+	defer circulationMu.Unlock()
+
+	circulation = c
+}
+
+// AddPatron stores a new patron
+func (c *Circulation) AddPatron(patron model.Patron) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.patrons[patron.ID] = patron
+	c.putRecord(patronRecord, patron.ID, patron)
+
+	return c.save()
+}
+
+// GetPatrons returns all of the patrons sorted by name
+func (c *Circulation) GetPatrons() []model.Patron {
+	c.Lock()
+	defer c.Unlock()
+
+	patrons := make([]model.Patron, 0, len(c.patrons))
+	for _, patron := range c.patrons {
+		patrons = append(patrons, patron)
+	}
+
+	sort.Slice(patrons, func(i, j int) bool {
+		return patrons[i].Name < patrons[j].Name
+	})
+
+	return patrons
+}
+
+// GetPatronByID returns the patron with the given id
+func (c *Circulation) GetPatronByID(id uuid.UUID) (model.Patron, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	patron, found := c.patrons[id]
+	if !found {
+		return patron, ErrNoPatronWithThatID
+	}
+
+	return patron, nil
+}
+
+// ActiveLoan returns the active loan for a book, and false if the book isn't
+// checked out
+func (c *Circulation) ActiveLoan(bookID uuid.UUID) (model.Loan, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	loanID, found := c.active[bookID]
+	if !found {
+		return model.Loan{}, false
+	}
+
+	return c.loans[loanID], true
+}
+
+// ActiveLoanCount returns how many books are checked out
+func (c *Circulation) ActiveLoanCount() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.active)
+}
+
+// Checkout lends a book to a patron, creating an active loan that's due after
+// the loan period, and marks the book as CheckedOut. A reserved book can only
+// be checked out by the patron it's reserved for, which fulfills their hold.
+// Patrons who owe more than the fine policy allows can't check out books
+func (c *Circulation) Checkout(bookID, patronID uuid.UUID) (model.Loan, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	if _, found := c.patrons[patronID]; !found {
+		return model.Loan{}, ErrNoPatronWithThatID
+	}
+
+	book, err := GetLibrary().GetBookByID(bookID)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	if book.Status == model.Lost {
+		return model.Loan{}, ErrBookLost
+	}
+
+	if c.blocked(patronID, now) {
+		return model.Loan{}, ErrPatronBlocked
+	}
+
+	if _, found := c.active[bookID]; found {
+		return model.Loan{}, ErrBookCheckedOut
+	}
+
+	err = c.expireHold(bookID, now)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	queue := c.holds[bookID]
+	if len(queue) > 0 && queue[0].PatronID != patronID {
+		return model.Loan{}, ErrBookReserved
+	}
+
+	err = setStatus(bookID, model.CheckedOut)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	// the patron's hold has been fulfilled
+	if len(queue) > 0 {
+		c.popHold(bookID)
+	}
+
+	loan := model.NewLoan()
+	loan.BookID = bookID
+	loan.Title = book.Title
+	loan.Author = book.Author
+	loan.PatronID = patronID
+	loan.CheckedOutAt = now
+	loan.DueAt = now.Add(c.Policy.LoanPeriod)
+
+	c.loans[loan.ID] = loan
+	c.active[bookID] = loan.ID
+	c.putRecord(loanRecord, loan.ID, loan)
+
+	return loan, c.save()
+}
+
+// Return ends the active loan for a book and marks the book as CheckedIn, or
+// if there are holds on the book reserves it for the first patron in line.
+// If the book is overdue the patron is charged the overdue fine
+func (c *Circulation) Return(bookID uuid.UUID) (model.Loan, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	loanID, found := c.active[bookID]
+	if !found {
+		return model.Loan{}, ErrBookNotCheckedOut
+	}
+
+	err := c.reserveNext(bookID, now)
+	if err != nil && err != ErrNoBookWithThatID {
+		return model.Loan{}, err
+	}
+
+	loan := c.loans[loanID]
+	loan.ReturnedAt = &now
+
+	c.loans[loanID] = loan
+	delete(c.active, bookID)
+	c.putRecord(loanRecord, loan.ID, loan)
+
+	c.chargeOverdueFine(loan, now)
+
+	return loan, c.save()
+}
+
+// setStatus changes just the status of a book in the global book store
+func setStatus(bookID uuid.UUID, status model.Status) error {
+	_, err := UpdateBook(bookID, func(book *model.Book) error {
+		book.Status = status
+		return nil
+	})
+
+	return err
+}
+
+// Renew pushes the due date of a book's active loan out to a full loan period
+// from now, as long as the loan hasn't hit the maximum number of renewals
+// and no other patrons are waiting for the book
+func (c *Circulation) Renew(bookID uuid.UUID) (model.Loan, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	loanID, found := c.active[bookID]
+	if !found {
+		return model.Loan{}, ErrBookNotCheckedOut
+	}
+
+	if len(c.holds[bookID]) > 0 {
+		return model.Loan{}, ErrRenewalBlockedByHolds
+	}
+
+	loan := c.loans[loanID]
+	if loan.Renewals >= c.Policy.MaxRenewals {
+		return model.Loan{}, ErrMaxRenewals
+	}
+
+	loan.Renewals++
+	loan.DueAt = c.Clock.Now().Add(c.Policy.LoanPeriod)
+	c.loans[loanID] = loan
+	c.putRecord(loanRecord, loan.ID, loan)
+
+	return loan, c.save()
+}
+
+// OverdueLoans returns every active loan that's past its due date, the most
+// overdue first
+func (c *Circulation) OverdueLoans() []model.Loan {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.Clock.Now()
+
+	overdue := []model.Loan{}
+	for _, loanID := range c.active {
+		loan := c.loans[loanID]
+		if loan.Overdue(now) {
+			overdue = append(overdue, loan)
+		}
+	}
+
+	sort.Slice(overdue, func(i, j int) bool {
+		return overdue[i].DueAt.Before(overdue[j].DueAt)
+	})
+
+	return overdue
+}
@@ -0,0 +1,179 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// testClock returns the FakeClock that newTestCirculation gives circulations
+func testClock(c *Circulation) *FakeClock {
+	return c.Clock.(*FakeClock)
+}
+
+// newTestCirculation resets the global library and returns a new circulation,
+// running on a FakeClock, with a single patron and a single book
+func newTestCirculation(t *testing.T) (*Circulation, model.Patron, model.Book) {
+	SetLibrary(NewLibrary())
+	t.Cleanup(func() { SetLibrary(NewLibrary()) })
+
+	circulation := NewCirculation()
+	circulation.Clock = NewFakeClock(time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	patron := model.NewPatron()
+	patron.Name = "Me"
+	circulation.AddPatron(patron)
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	GetLibrary().AddBook(book)
+
+	return circulation, patron, book
+}
+
+// bookStatus returns the status of the book in the global library
+func bookStatus(t *testing.T, id uuid.UUID) model.Status {
+	book, err := GetLibrary().GetBookByID(id)
+	if err != nil {
+		t.Errorf("Error getting book by ID: %v", err)
+		t.FailNow()
+	}
+
+	return book.Status
+}
+
+func TestCheckoutAndReturn(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	loan, err := circulation.Checkout(book.ID, patron.ID)
+	if err != nil {
+		t.Errorf("Error checking out book: %v", err)
+		t.FailNow()
+	}
+
+	if loan.BookID != book.ID || loan.Title != book.Title || loan.PatronID != patron.ID || !loan.Active() {
+		t.Errorf("Checkout returned the wrong loan %+v", loan)
+	}
+
+	if loan.DueAt.Sub(loan.CheckedOutAt) != DefaultLoanPeriod {
+		t.Errorf("Expected the loan to be due after %v, got %v", DefaultLoanPeriod, loan.DueAt.Sub(loan.CheckedOutAt))
+	}
+
+	if bookStatus(t, book.ID) != model.CheckedOut {
+		t.Errorf("Checking out a book didn't mark it as CheckedOut")
+	}
+
+	if _, err = circulation.Checkout(book.ID, patron.ID); err != ErrBookCheckedOut {
+		t.Errorf("Expected %v checking out a checked out book, got %v", ErrBookCheckedOut, err)
+	}
+
+	returned, err := circulation.Return(book.ID)
+	if err != nil {
+		t.Errorf("Error returning book: %v", err)
+		t.FailNow()
+	}
+
+	if returned.ID != loan.ID || returned.Active() {
+		t.Errorf("Return didn't end the loan, got %+v", returned)
+	}
+
+	if bookStatus(t, book.ID) != model.CheckedIn {
+		t.Errorf("Returning a book didn't mark it as CheckedIn")
+	}
+
+	if _, found := circulation.ActiveLoan(book.ID); found {
+		t.Errorf("The book still had an active loan after being returned")
+	}
+
+	if _, err = circulation.Return(book.ID); err != ErrBookNotCheckedOut {
+		t.Errorf("Expected %v returning a book that isn't checked out, got %v", ErrBookNotCheckedOut, err)
+	}
+}
+
+func TestCheckoutBadIDs(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	bogusID, _ := uuid.NewV4()
+
+	if _, err := circulation.Checkout(bogusID, patron.ID); err != ErrNoBookWithThatID {
+		t.Errorf("Expected %v checking out a missing book, got %v", ErrNoBookWithThatID, err)
+	}
+
+	if _, err := circulation.Checkout(book.ID, bogusID); err != ErrNoPatronWithThatID {
+		t.Errorf("Expected %v checking out to a missing patron, got %v", ErrNoPatronWithThatID, err)
+	}
+
+	if bookStatus(t, book.ID) != model.CheckedIn {
+		t.Errorf("A failed checkout changed the book's status")
+	}
+}
+
+func TestRenew(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	circulation.Policy.MaxRenewals = 1
+
+	if _, err := circulation.Renew(book.ID); err != ErrBookNotCheckedOut {
+		t.Errorf("Expected %v renewing a book that isn't checked out, got %v", ErrBookNotCheckedOut, err)
+	}
+
+	circulation.Checkout(book.ID, patron.ID)
+	testClock(circulation).Advance(10 * 24 * time.Hour)
+
+	loan, err := circulation.Renew(book.ID)
+	if err != nil {
+		t.Errorf("Error renewing a loan: %v", err)
+		t.FailNow()
+	}
+
+	expected := testClock(circulation).Now().Add(DefaultLoanPeriod)
+	if loan.Renewals != 1 || !loan.DueAt.Equal(expected) {
+		t.Errorf("Expected the loan to be renewed once and due at %v, got %+v", expected, loan)
+	}
+
+	if _, err = circulation.Renew(book.ID); err != ErrMaxRenewals {
+		t.Errorf("Expected %v renewing past the maximum, got %v", ErrMaxRenewals, err)
+	}
+}
+
+func TestRenewBlockedByHolds(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	waiting := addTestPatron(circulation, "Waiting")
+
+	circulation.Checkout(book.ID, patron.ID)
+	circulation.PlaceHold(book.ID, waiting.ID)
+
+	if _, err := circulation.Renew(book.ID); err != ErrRenewalBlockedByHolds {
+		t.Errorf("Expected %v renewing a book with holds, got %v", ErrRenewalBlockedByHolds, err)
+	}
+}
+
+func TestOverdueLoans(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	onTime := model.NewBook()
+	GetLibrary().AddBook(onTime)
+
+	circulation.Checkout(book.ID, patron.ID)
+	testClock(circulation).Advance(7 * 24 * time.Hour)
+	circulation.Checkout(onTime.ID, patron.ID)
+
+	if overdue := circulation.OverdueLoans(); len(overdue) != 0 {
+		t.Errorf("Expected no overdue loans before the due date, got %+v", overdue)
+	}
+
+	// the first loan is now a week overdue, the second is due today
+	testClock(circulation).Advance(14 * 24 * time.Hour)
+
+	overdue := circulation.OverdueLoans()
+	if len(overdue) != 1 || overdue[0].BookID != book.ID {
+		t.Errorf("Expected just the first loan to be overdue, got %+v", overdue)
+	}
+
+	// returned loans are never overdue
+	circulation.Return(book.ID)
+	if overdue = circulation.OverdueLoans(); len(overdue) != 0 {
+		t.Errorf("Expected no overdue loans after returning the book, got %+v", overdue)
+	}
+}
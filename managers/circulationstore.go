@@ -0,0 +1,246 @@
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// the kinds of record the circulation saves in its RecordStore
+const (
+	patronRecord      = "patron"
+	loanRecord        = "loan"
+	holdRecord        = "hold"
+	ledgerEntryRecord = "ledger_entry"
+)
+
+// Load reads the patrons, loans, holds and ledgers saved in the RecordStore
+// into the circulation, and from then on saves every change to the
+// circulation there. It's meant to be called once at startup, after the
+// global book store has been set.
+//
+// The books' statuses are then brought back in line with the loans and holds,
+// since a book's status and the loan that changed it aren't saved together
+func (c *Circulation) Load(records RecordStore) error {
+	c.Lock()
+	defer c.Unlock()
+
+	err := loadRecords(records, patronRecord, func(value []byte) error {
+		var patron model.Patron
+		err := json.Unmarshal(value, &patron)
+		if err != nil {
+			return err
+		}
+
+		c.patrons[patron.ID] = patron
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadRecords(records, loanRecord, func(value []byte) error {
+		var loan model.Loan
+		err := json.Unmarshal(value, &loan)
+		if err != nil {
+			return err
+		}
+
+		c.loans[loan.ID] = loan
+		if loan.Active() {
+			c.active[loan.BookID] = loan.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadRecords(records, holdRecord, func(value []byte) error {
+		var hold model.Hold
+		err := json.Unmarshal(value, &hold)
+		if err != nil {
+			return err
+		}
+
+		c.holds[hold.BookID] = append(c.holds[hold.BookID], hold)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadRecords(records, ledgerEntryRecord, func(value []byte) error {
+		var entry model.LedgerEntry
+		err := json.Unmarshal(value, &entry)
+		if err != nil {
+			return err
+		}
+
+		c.ledgers[entry.PatronID] = append(c.ledgers[entry.PatronID], entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the ready hold is always at the front of the queue, and the rest are
+	// in the order they were placed
+	for _, queue := range c.holds {
+		sort.SliceStable(queue, func(i, j int) bool {
+			if queue[i].Ready() != queue[j].Ready() {
+				return queue[i].Ready()
+			}
+			return queue[i].PlacedAt.Before(queue[j].PlacedAt)
+		})
+	}
+
+	for _, entries := range c.ledgers {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		})
+	}
+
+	c.records = records
+
+	err = c.reconcile()
+	if err != nil {
+		return err
+	}
+
+	return c.save()
+}
+
+// loadRecords passes the value of every record of the kind to add, which
+// returns an error if the value can't be decoded
+func loadRecords(records RecordStore, kind string, add func(value []byte) error) error {
+	saved, err := records.GetRecords(kind)
+	if err != nil {
+		return fmt.Errorf("Unable to read the %v records: %v", kind, err)
+	}
+
+	for _, record := range saved {
+		err = add(record.Value)
+		if err != nil {
+			return fmt.Errorf("Unable to read the %v record %v: %v", kind, record.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcile sets the status of every book to match the circulation, books
+// with an active loan are CheckedOut, books that patrons are waiting for are
+// Reserved for the first of them, and the rest are CheckedIn unless they've
+// been lost. Holds on books that aren't in the library anymore are dropped
+func (c *Circulation) reconcile() error {
+	books, err := GetLibrary().GetBooks()
+	if err != nil {
+		return err
+	}
+
+	now := c.Clock.Now()
+
+	found := make(map[uuid.UUID]bool, len(books))
+	for _, book := range books {
+		found[book.ID] = true
+
+		_, checkedOut := c.active[book.ID]
+		queue := c.holds[book.ID]
+
+		status := model.CheckedIn
+		switch {
+		case checkedOut:
+			status = model.CheckedOut
+		case len(queue) > 0 && !queue[0].Ready():
+			// the book was returned without the next patron being told
+			err = c.reserveNext(book.ID, now)
+			if err != nil {
+				return err
+			}
+			continue
+		case len(queue) > 0:
+			status = model.Reserved
+		case book.Status == model.Lost:
+			continue
+		}
+
+		if book.Status == status {
+			continue
+		}
+
+		log.Warnf("Changing the status of book %v from %v to %v to match its loans and holds", book.ID, book.Status, status)
+		err = setStatus(book.ID, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	for bookID, queue := range c.holds {
+		if found[bookID] {
+			continue
+		}
+
+		for _, hold := range queue {
+			c.deleteRecord(holdRecord, hold.ID)
+		}
+		delete(c.holds, bookID)
+	}
+
+	return nil
+}
+
+// putRecord notes that a record has changed, it's written to the RecordStore
+// by the next save
+func (c *Circulation) putRecord(kind string, id uuid.UUID, value interface{}) {
+	if c.records == nil {
+		return
+	}
+
+	// the circulation's records are all plain structs, which can't fail to
+	// marshal
+	b, _ := json.Marshal(value)
+	c.pending = append(c.pending, Record{Kind: kind, ID: id, Value: b})
+}
+
+// deleteRecord notes that a record is gone, it's deleted from the
+// RecordStore by the next save
+func (c *Circulation) deleteRecord(kind string, id uuid.UUID) {
+	if c.records == nil {
+		return
+	}
+
+	c.pending = append(c.pending, Record{Kind: kind, ID: id})
+}
+
+// save writes the records that have changed to the RecordStore, all at once
+// so that a change is either saved completely or not at all. If they can't
+// be written they're kept, and tried again with the next change
+func (c *Circulation) save() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	err := c.records.SaveRecords(c.pending)
+	if err != nil {
+		return fmt.Errorf("Unable to save the circulation: %v", err)
+	}
+
+	c.pending = nil
+	return nil
+}
+
+// flush saves whatever was changed by a change that failed part way, like a
+// checkout that expired a hold before finding the book reserved for someone
+// else. It's deferred, so there's nothing to return its error to and it's
+// logged instead
+func (c *Circulation) flush() {
+	err := c.save()
+	if err != nil {
+		log.Errorf("%v", err)
+	}
+}
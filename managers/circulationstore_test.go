@@ -0,0 +1,153 @@
+package managers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// loadTestCirculation returns a new circulation loaded from the records, as
+// if the process had restarted, running on the clock
+func loadTestCirculation(t *testing.T, records RecordStore, clock *FakeClock) *Circulation {
+	circulation := NewCirculation()
+	circulation.Clock = clock
+
+	err := circulation.Load(records)
+	if err != nil {
+		t.Errorf("Error loading the circulation: %v", err)
+		t.FailNow()
+	}
+
+	return circulation
+}
+
+func TestCirculationLoad(t *testing.T) {
+	library := NewLibrary()
+	SetLibrary(library)
+	t.Cleanup(func() { SetLibrary(NewLibrary()) })
+
+	clock := NewFakeClock(time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC))
+	circulation := loadTestCirculation(t, library, clock)
+
+	me := model.NewPatron()
+	me.Name = "Me"
+	circulation.AddPatron(me)
+
+	you := model.NewPatron()
+	you.Name = "You"
+	circulation.AddPatron(you)
+
+	held := model.NewBook()
+	held.Title = "MyHeldBook"
+	GetLibrary().AddBook(held)
+
+	late := model.NewBook()
+	late.Title = "MyLateBook"
+	GetLibrary().AddBook(late)
+
+	circulation.Checkout(held.ID, me.ID)
+	circulation.PlaceHold(held.ID, you.ID)
+	circulation.Checkout(late.ID, me.ID)
+
+	clock.Advance(DefaultLoanPeriod + 24*time.Hour)
+	circulation.Return(late.ID)
+	circulation.Pay(me.ID, 10, "")
+
+	ledger, _ := circulation.GetLedger(me.ID)
+
+	// everything has to be there after a restart
+	circulation = loadTestCirculation(t, library, clock)
+
+	if len(circulation.GetPatrons()) != 2 {
+		t.Errorf("Expected 2 patrons after loading, got %+v", circulation.GetPatrons())
+	}
+
+	if loan, found := circulation.ActiveLoan(held.ID); !found || loan.PatronID != me.ID {
+		t.Errorf("Expected the held book to still be checked out to me after loading, got %+v", loan)
+	}
+
+	if loans, _ := circulation.PatronLoans(me.ID); len(loans) != 2 {
+		t.Errorf("Expected my 2 loans after loading, got %+v", loans)
+	}
+
+	loaded, _ := circulation.GetLedger(me.ID)
+	if loaded.Balance != ledger.Balance || len(loaded.Entries) != len(ledger.Entries) {
+		t.Errorf("Expected my ledger %+v after loading, got %+v", ledger, loaded)
+	}
+
+	if _, err := circulation.Return(held.ID); err != nil {
+		t.Errorf("Error returning a book checked out before loading: %v", err)
+		t.FailNow()
+	}
+
+	if bookStatus(t, held.ID) != model.Reserved {
+		t.Errorf("Expected the returned book to be reserved for the patron holding it")
+	}
+
+	// and the reservation has to survive another restart
+	circulation = loadTestCirculation(t, library, clock)
+
+	if _, err := circulation.Checkout(held.ID, me.ID); err != ErrBookReserved {
+		t.Errorf("Expected %v checking out a book reserved for someone else, got %v", ErrBookReserved, err)
+	}
+
+	if _, err := circulation.Checkout(held.ID, you.ID); err != nil {
+		t.Errorf("Error checking out the book reserved for me: %v", err)
+	}
+
+	if holds, _ := circulation.GetHolds(held.ID); len(holds) != 0 {
+		t.Errorf("Expected the hold to be fulfilled, got %+v", holds)
+	}
+}
+
+func TestCirculationLoadFixesStatuses(t *testing.T) {
+	library := NewLibrary()
+	SetLibrary(library)
+	t.Cleanup(func() { SetLibrary(NewLibrary()) })
+
+	// books whose loans and holds weren't saved
+	books := map[model.Status]model.Status{
+		model.CheckedIn:  model.CheckedIn,
+		model.CheckedOut: model.CheckedIn,
+		model.Reserved:   model.CheckedIn,
+		model.Lost:       model.Lost,
+	}
+
+	added := make(map[model.Status]model.Book)
+	for status := range books {
+		book := model.NewBook()
+		book.Title = "MyBook"
+		book.Status = status
+		GetLibrary().AddBook(book)
+		added[status] = book
+	}
+
+	loadTestCirculation(t, library, NewFakeClock(time.Now()))
+
+	for status, expected := range books {
+		if got := bookStatus(t, added[status].ID); got != expected {
+			t.Errorf("Expected a %v book without a loan or holds to be %v after loading, got %v", status, expected, got)
+		}
+	}
+}
+
+func TestCirculationLoadCorruptRecord(t *testing.T) {
+	library := NewLibrary()
+	SetLibrary(library)
+	t.Cleanup(func() { SetLibrary(NewLibrary()) })
+
+	id, _ := uuid.NewV4()
+	library.SaveRecords([]Record{{Kind: patronRecord, ID: id, Value: json.RawMessage(`{"id": 7}`)}})
+
+	circulation := NewCirculation()
+	if err := circulation.Load(library); err == nil {
+		t.Errorf("Expected an error loading a corrupt patron")
+	}
+
+	if patrons := circulation.GetPatrons(); len(patrons) != 0 {
+		t.Errorf("Expected the corrupt patron not to be loaded, got %+v", patrons)
+	}
+}
@@ -0,0 +1,47 @@
+package managers
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the managers what time it is, it's swapped out by tests so
+// that due dates and expiries can be checked without waiting on them
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock that reads the system time
+type realClock struct{}
+
+// Now returns the current system time in UTC
+func (realClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// FakeClock is a Clock that only moves when it's told to
+type FakeClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock stopped at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now.UTC()}
+}
+
+// Now returns the time the clock is stopped at
+func (f *FakeClock) Now() time.Time {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.now
+}
+
+// Advance moves the clock forward by the given duration
+func (f *FakeClock) Advance(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.now = f.now.Add(d)
+}
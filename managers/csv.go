@@ -0,0 +1,229 @@
+package managers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// CSVColumns are the columns of an exported catalog in the order they're
+// written, new columns must only ever be added to the end
+var CSVColumns = []string{"id", "title", "author", "publisher", "publish_date", "rating", "status", "revision", "isbn"}
+
+var (
+	// ErrEmptyCSV is returned when importing a csv that doesn't even have a
+	// header row
+	ErrEmptyCSV = model.ErrEmptyCSV
+
+	// ErrUnknownColumn is returned when an import's mapping maps a header to
+	// a column that isn't in CSVColumns
+	ErrUnknownColumn = model.ErrUnknownColumn
+)
+
+// WriteCSV writes a header row of CSVColumns and then a row for each book
+func WriteCSV(w io.Writer, books []model.Book) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(CSVColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		publishDate := ""
+		if book.PublishDate != nil {
+			publishDate = book.PublishDate.UTC().Format(time.RFC3339)
+		}
+
+		err = writer.Write([]string{
+			book.ID.String(),
+			escapeCSVFormula(book.Title),
+			escapeCSVFormula(book.Author),
+			escapeCSVFormula(book.Publisher),
+			publishDate,
+			strconv.Itoa(int(book.Rating)),
+			book.Status.String(),
+			strconv.FormatUint(book.Revision, 10),
+			book.ISBN,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formulaPrefixes are the characters that make a spreadsheet read a cell as
+// a formula rather than as text
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula puts a ' in front of a value that a spreadsheet would
+// otherwise run as a formula, so that a book's title can't run code on a
+// librarian's computer when the export is opened
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// unescapeCSVFormula takes off the ' that escapeCSVFormula put in front of
+// a value, values that just happen to start with a ' are left alone
+func unescapeCSVFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+
+	return value
+}
+
+// ImportCSV reads books from a csv into the global book store, the header
+// is row 1 and each book is a row after it. Like the api, the status and
+// revision columns are ignored
+func ImportCSV(r io.Reader, options ImportOptions) (ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return ImportReport{}, ErrEmptyCSV
+	}
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	columns, err := csvColumns(header, options.Mapping)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	im := newImporter(options)
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// a row that isn't valid csv can be skipped, but anything else
+		// means the csv can't be read any further
+		if _, ok := err.(*csv.ParseError); err != nil && !ok {
+			return im.report, err
+		}
+
+		if err == nil {
+			var book model.Book
+			var hasID bool
+			book, hasID, err = csvBook(columns, record)
+			if err == nil {
+				im.add(row, book, hasID)
+				continue
+			}
+		}
+
+		im.fail(row, err)
+	}
+
+	return im.apply()
+}
+
+// csvColumns returns the column each header in a csv is for, "" for headers
+// that aren't for any of the columns
+func csvColumns(header []string, mapping map[string]string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, column := range CSVColumns {
+		known[column] = true
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		if column, found := mapping[name]; found {
+			if !known[column] {
+				return nil, ErrUnknownColumn
+			}
+			columns[i] = column
+			continue
+		}
+
+		column := strings.ToLower(strings.TrimSpace(name))
+		if known[column] {
+			columns[i] = column
+		}
+	}
+
+	return columns, nil
+}
+
+// csvBook reads the book in a row of a csv, and whether the row has an id
+func csvBook(columns, record []string) (model.Book, bool, error) {
+	var book model.Book
+	hasID := false
+
+	for i, value := range record {
+		if i >= len(columns) {
+			break
+		}
+		value = strings.TrimSpace(value)
+
+		switch columns[i] {
+		case "id":
+			if value == "" {
+				continue
+			}
+			id, err := uuid.FromString(value)
+			if err != nil {
+				return book, false, fmt.Errorf("The id %q isn't a valid UUID", value)
+			}
+			book.ID = id
+			hasID = true
+		case "title":
+			book.Title = unescapeCSVFormula(value)
+		case "author":
+			book.Author = unescapeCSVFormula(value)
+		case "publisher":
+			book.Publisher = unescapeCSVFormula(value)
+		case "publish_date":
+			if value == "" {
+				continue
+			}
+			date, err := parseCSVDate(value)
+			if err != nil {
+				return book, false, err
+			}
+			book.PublishDate = &date
+		case "isbn":
+			book.ISBN = value
+		case "rating":
+			if value == "" {
+				continue
+			}
+			rating, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return book, false, model.ErrInvalidRating
+			}
+			book.Rating = uint8(rating)
+		}
+	}
+
+	return book, hasID, nil
+}
+
+// parseCSVDate reads a publish date given either as a full RFC 3339
+// timestamp or just as a date
+func parseCSVDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("The publish_date %q must be formatted like 2018-01-02 or 2018-01-02T15:04:05Z", s)
+}
@@ -0,0 +1,166 @@
+package managers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	date := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+	book := model.NewBook()
+	book.Title = "Dune, the \"first\" book"
+	book.Author = "Herbert"
+	book.PublishDate = &date
+	book.Rating = 3
+	GetLibrary().AddBook(book)
+
+	var buf bytes.Buffer
+	books, _ := GetLibrary().GetBooks()
+	err := WriteCSV(&buf, books)
+	if err != nil {
+		t.Errorf("Error writing csv: %v", err)
+		t.FailNow()
+	}
+
+	header := strings.SplitN(buf.String(), "\n", 2)[0]
+	if header != strings.Join(CSVColumns, ",") {
+		t.Errorf("Expected the header %v, got %v", CSVColumns, header)
+	}
+
+	// importing the export into an empty library recreates the same books
+	SetLibrary(NewLibrary())
+	report, err := ImportCSV(&buf, ImportOptions{})
+	if err != nil {
+		t.Errorf("Error importing csv: %v", err)
+		t.FailNow()
+	}
+
+	if report.Rows != 1 || report.Created != 1 || report.Failed != 0 || !report.Applied {
+		t.Errorf("Expected 1 book to be created, got %+v", report)
+	}
+
+	imported, err := GetLibrary().GetBookByID(book.ID)
+	if err != nil || imported.Title != book.Title || !imported.PublishDate.Equal(date) || imported.Rating != 3 {
+		t.Errorf("Expected the exported book to be imported, got %+v (%v)", imported, err)
+	}
+}
+
+func TestCSVFormulaEscaping(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	book := model.NewBook()
+	book.Title = "=HYPERLINK(\"http://example.com\")"
+	book.Author = "@me"
+	book.Publisher = "'Tis Press"
+	book.Rating = 1
+	GetLibrary().AddBook(book)
+
+	var buf bytes.Buffer
+	books, _ := GetLibrary().GetBooks()
+	WriteCSV(&buf, books)
+
+	if !strings.Contains(buf.String(), `"'=HYPERLINK(""http://example.com"")",'@me,'Tis Press`) {
+		t.Errorf("Expected the title and author to be escaped, got %v", buf.String())
+	}
+
+	SetLibrary(NewLibrary())
+	_, err := ImportCSV(&buf, ImportOptions{})
+	if err != nil {
+		t.Errorf("Error importing csv: %v", err)
+		t.FailNow()
+	}
+
+	imported, _ := GetLibrary().GetBookByID(book.ID)
+	if imported.Title != book.Title || imported.Author != book.Author || imported.Publisher != book.Publisher {
+		t.Errorf("Expected the escaping to be taken off on import, got %+v", imported)
+	}
+}
+
+func TestImportCSVDryRunValidation(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	before := ValidationFailures()["rating"]
+
+	report, err := ImportCSV(strings.NewReader("title,rating\nMyUnratedBook,\nMyOtherBook,9\n"), ImportOptions{DryRun: true})
+	if err != nil || report.Failed != 2 {
+		t.Errorf("Expected both rows to fail, got %+v (%v)", report, err)
+	}
+
+	if after := ValidationFailures()["rating"]; after != before {
+		t.Errorf("Expected a dry run not to count validation failures, went from %v to %v", before, after)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	existing := model.NewBook()
+	existing.Title = "MyExistingBook"
+	existing.Rating = 1
+	GetLibrary().AddBook(existing)
+
+	csv := "Book Title,Writer,Rating,Shelf,id\n" +
+		"MyNewBook,Me,2,A1,\n" +
+		"MyUpdatedBook,Me,3,A2," + existing.ID.String() + "\n" +
+		"MyBadRating,Me,9,A3,\n" +
+		"MyBadID,Me,2,A4,nope\n"
+
+	options := ImportOptions{
+		Mapping: map[string]string{"Book Title": "title", "Writer": "author"},
+		DryRun:  true,
+	}
+
+	report, err := ImportCSV(strings.NewReader(csv), options)
+	if err != nil {
+		t.Errorf("Error importing csv: %v", err)
+		t.FailNow()
+	}
+
+	if report.Rows != 4 || report.Created != 1 || report.Updated != 1 || report.Failed != 2 || report.Applied {
+		t.Errorf("Wrong report from a dry run, got %+v", report)
+	}
+
+	if len(report.Errors) != 2 || report.Errors[0].Row != 4 || report.Errors[1].Row != 5 {
+		t.Errorf("Expected errors for rows 4 and 5, got %+v", report.Errors)
+	}
+
+	if countBooks(t, GetLibrary()) != 1 {
+		t.Errorf("A dry run changed the library")
+	}
+
+	options.DryRun = false
+	options.Atomic = true
+	if report, _ = ImportCSV(strings.NewReader(csv), options); report.Applied || countBooks(t, GetLibrary()) != 1 {
+		t.Errorf("An atomic import with bad rows changed the library, got %+v", report)
+	}
+
+	options.Atomic = false
+	report, err = ImportCSV(strings.NewReader(csv), options)
+	if err != nil || !report.Applied {
+		t.Errorf("Expected the good rows to be imported, got %+v (%v)", report, err)
+	}
+
+	updated, _ := GetLibrary().GetBookByID(existing.ID)
+	if updated.Title != "MyUpdatedBook" || updated.Author != "Me" || countBooks(t, GetLibrary()) != 2 {
+		t.Errorf("Expected the existing book to be replaced and a new book to be added, got %+v", updated)
+	}
+
+	if _, err = ImportCSV(strings.NewReader(""), ImportOptions{}); err != ErrEmptyCSV {
+		t.Errorf("Expected %v importing an empty csv, got %v", ErrEmptyCSV, err)
+	}
+
+	badMapping := ImportOptions{Mapping: map[string]string{"Writer": "writer"}}
+	if _, err = ImportCSV(strings.NewReader(csv), badMapping); err != ErrUnknownColumn {
+		t.Errorf("Expected %v importing with a bad mapping, got %v", ErrUnknownColumn, err)
+	}
+}
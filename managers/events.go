@@ -0,0 +1,209 @@
+package managers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+)
+
+// EventType is the kind of change an Event is for
+type EventType = model.EventType
+
+// this const block holds the EventType values
+const (
+	EventCreated       = model.EventCreated
+	EventUpdated       = model.EventUpdated
+	EventDeleted       = model.EventDeleted
+	EventStatusChanged = model.EventStatusChanged
+)
+
+// DefaultEventHistory is how many events are kept for subscribers that
+// resume after missing some
+const DefaultEventHistory = 1024
+
+// subscriptionBuffer is how many events a subscriber can fall behind by
+// before it's dropped
+const subscriptionBuffer = 64
+
+// Event is a change to a book in the global book store, it's kept with the
+// models so that the client can read them without importing the managers
+type Event = model.Event
+
+// the global event stream, fed by every change to the global book store
+var events = NewEventStream(DefaultEventHistory)
+
+func init() {
+	OnChange(events.Publish)
+}
+
+// GetEvents returns the stream of changes to the global book store
+func GetEvents() *EventStream {
+	return events
+}
+
+// EventStream numbers the changes made to the books, keeps the latest of
+// them so subscribers can catch up on what they missed, and sends them to
+// every subscriber. Publishing never waits on a subscriber, one that falls
+// too far behind is dropped and can resume from the last event it got
+type EventStream struct {
+	sync.Mutex
+
+	// start is the id before the first event, it comes from the time the
+	// stream was made, so an id from before the process restarted is lower
+	// than it and can be told apart from one of this process's
+	start   uint64
+	lastID  uint64
+	history []Event
+	size    int
+
+	subscribers map[*Subscription]bool
+
+	// Clock is where each event's time comes from
+	Clock Clock
+}
+
+// Subscription receives the events published after it was made, Events is
+// closed when the subscription is dropped, either for falling behind or by
+// Unsubscribe or Disconnect
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+}
+
+// NewEventStream returns an EventStream that keeps the latest history events
+func NewEventStream(history int) *EventStream {
+	// a process would have to publish over a million events a second for
+	// its ids to catch up with the next process's start, and the ids stay
+	// small enough for javascript to read them exactly
+	start := uint64(time.Now().UnixMilli()) << 10
+
+	return &EventStream{
+		start:       start,
+		lastID:      start,
+		size:        history,
+		subscribers: make(map[*Subscription]bool),
+		Clock:       realClock{},
+	}
+}
+
+// Publish sends the events for a change to every subscriber
+func (s *EventStream) Publish(change Change) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch change.Type {
+	case BookCreated:
+		s.publish(Event{Type: EventCreated, Book: change.Book})
+	case BookDeleted:
+		s.publish(Event{Type: EventDeleted, Book: change.Book})
+	case BookUpdated:
+		s.publish(Event{Type: EventUpdated, Book: change.Book})
+
+		if change.Previous != nil && change.Previous.Status != change.Book.Status {
+			s.publish(Event{Type: EventStatusChanged, Book: change.Book, PreviousStatus: change.Previous.Status.String()})
+		}
+	}
+}
+
+// publish numbers the event, adds it to the history and sends it to the
+// subscribers, dropping any that are full
+func (s *EventStream) publish(event Event) {
+	s.lastID++
+	event.ID = s.lastID
+	event.Time = s.Clock.Now()
+
+	s.history = append(s.history, event)
+	if len(s.history) > s.size {
+		s.history = s.history[len(s.history)-s.size:]
+	}
+
+	for sub := range s.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			s.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on
+func (s *EventStream) Subscribe() *Subscription {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.subscribe()
+}
+
+// Resume returns a subscription to the events published from now on and the
+// events after lastID that have already been published, 0 for all of them.
+// resumed is false if some of the events after lastID are no longer in the
+// history, or lastID is from before the process started, in which case the
+// subscriber has to read the books again rather than rely on the events
+func (s *EventStream) Resume(lastID uint64) (sub *Subscription, missed []Event, resumed bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	sub = s.subscribe()
+
+	if lastID == 0 {
+		lastID = s.start
+	}
+
+	if lastID == s.lastID {
+		return sub, nil, true
+	}
+
+	// an id below start was given out before the process restarted, and
+	// one above the latest was never given out at all
+	if lastID < s.start || lastID > s.lastID || lastID+1 < s.history[0].ID {
+		return sub, nil, false
+	}
+
+	missed = append(missed, s.history[lastID+1-s.history[0].ID:]...)
+	return sub, missed, true
+}
+
+// subscribe adds a new subscriber
+func (s *EventStream) subscribe() *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: ch, events: ch}
+	s.subscribers[sub] = true
+
+	return sub
+}
+
+// Unsubscribe stops sending events to the subscription
+func (s *EventStream) Unsubscribe(sub *Subscription) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.drop(sub)
+}
+
+// Disconnect drops every subscription, so that the streams reading them
+// finish, it's used when the server is shutting down
+func (s *EventStream) Disconnect() {
+	s.Lock()
+	defer s.Unlock()
+
+	for sub := range s.subscribers {
+		s.drop(sub)
+	}
+}
+
+// drop closes and forgets a subscription
+func (s *EventStream) drop(sub *Subscription) {
+	if s.subscribers[sub] {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// LastID returns the id of the latest event
+func (s *EventStream) LastID() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.lastID
+}
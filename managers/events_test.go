@@ -0,0 +1,154 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// eventTypes returns the type of each event
+func eventTypes(events []Event) []EventType {
+	types := []EventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestEventStream(t *testing.T) {
+	stream := NewEventStream(3)
+	_, missed, resumed := stream.Resume(0)
+	if len(missed) != 0 || !resumed {
+		t.Errorf("Expected to resume with nothing missed before any events, got %v %v", missed, resumed)
+	}
+
+	sub := stream.Subscribe()
+	start := stream.LastID()
+
+	book := model.NewBook()
+	stream.Publish(Change{Type: BookCreated, Book: book})
+
+	checkedOut := book
+	checkedOut.Status = model.CheckedOut
+	stream.Publish(Change{Type: BookUpdated, Book: checkedOut, Previous: &book})
+	stream.Publish(Change{Type: BookDeleted, Book: checkedOut})
+
+	var got []Event
+	for len(got) < 4 {
+		got = append(got, <-sub.Events)
+	}
+
+	want := []EventType{EventCreated, EventUpdated, EventStatusChanged, EventDeleted}
+	for i, event := range got {
+		if event.ID != start+uint64(i+1) || event.Type != want[i] {
+			t.Errorf("Expected event %v to be %v, got %v %v", start+uint64(i+1), want[i], event.ID, event.Type)
+		}
+	}
+	if got[2].PreviousStatus != "CheckedIn" || got[2].Book.Status != model.CheckedOut {
+		t.Errorf("Expected the status change to be from CheckedIn to CheckedOut, got %+v", got[2])
+	}
+
+	// only the last 3 events are kept
+	_, missed, resumed = stream.Resume(start + 1)
+	if !resumed || len(missed) != 3 || missed[0].ID != start+2 {
+		t.Errorf("Expected to resume after event 1 with events 2-4, got %v %v", eventTypes(missed), resumed)
+	}
+
+	_, missed, resumed = stream.Resume(start + 4)
+	if !resumed || len(missed) != 0 {
+		t.Errorf("Expected to resume after the latest event with nothing missed, got %v", eventTypes(missed))
+	}
+
+	for _, lastID := range []uint64{start + 5, start + 100} {
+		if _, _, resumed = stream.Resume(lastID); resumed {
+			t.Errorf("Expected an event id from the future, %v, not to be resumed", lastID)
+		}
+	}
+
+	stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+	if _, _, resumed = stream.Resume(start + 1); resumed {
+		t.Errorf("Expected event 1 to be too old to resume from")
+	}
+}
+
+func TestEventStreamRestart(t *testing.T) {
+	before := NewEventStream(DefaultEventHistory)
+	for i := 0; i < 5; i++ {
+		before.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+	}
+	lastID := before.LastID() - 3
+
+	// the process restarts, and has as many events again
+	time.Sleep(time.Millisecond)
+	after := NewEventStream(DefaultEventHistory)
+	for i := 0; i < 5; i++ {
+		after.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+	}
+
+	if _, missed, resumed := after.Resume(lastID); resumed {
+		t.Errorf("Expected an event id from before the restart not to be resumed, got %v", eventTypes(missed))
+	}
+
+	if after.LastID() <= before.LastID() {
+		t.Errorf("Expected the ids to carry on going up after the restart, went from %v to %v", before.LastID(), after.LastID())
+	}
+}
+
+func TestEventStreamSlowSubscriber(t *testing.T) {
+	stream := NewEventStream(DefaultEventHistory)
+	slow := stream.Subscribe()
+	fast := stream.Subscribe()
+
+	received := 0
+	for i := 0; i < subscriptionBuffer+10; i++ {
+		stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+		<-fast.Events
+		received++
+	}
+
+	if received != subscriptionBuffer+10 {
+		t.Errorf("Expected the fast subscriber to get every event")
+	}
+
+	count := 0
+	for range slow.Events {
+		count++
+	}
+	if count != subscriptionBuffer {
+		t.Errorf("Expected the slow subscriber to get %v events and be dropped, got %v", subscriptionBuffer, count)
+	}
+
+	stream.Disconnect()
+	if _, open := <-fast.Events; open {
+		t.Errorf("Expected Disconnect to close every subscription")
+	}
+}
+
+func TestLibraryEvents(t *testing.T) {
+	SetLibrary(NewLibrary())
+	sub := GetEvents().Subscribe()
+	defer GetEvents().Unsubscribe(sub)
+
+	book := model.NewBook()
+	book.Rating = 1
+	GetLibrary().AddBook(book)
+	UpdateBook(book.ID, func(book *model.Book) error {
+		book.Status = model.Lost
+		return nil
+	})
+
+	var got []Event
+	for len(got) < 3 {
+		got = append(got, <-sub.Events)
+	}
+
+	types := eventTypes(got)
+	if types[0] != EventCreated || types[1] != EventUpdated || types[2] != EventStatusChanged {
+		t.Errorf("Expected the library's changes to be created, updated and status-changed, got %v", types)
+	}
+	if got[2].PreviousStatus != "CheckedIn" || got[2].Book.Revision != 2 {
+		t.Errorf("Expected the status change to be from CheckedIn to revision 2, got %+v", got[2])
+	}
+}
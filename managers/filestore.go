@@ -0,0 +1,468 @@
+package managers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCompactThreshold is the smallest number of records the log has to
+// hold before a FileStore will think about compacting it
+const DefaultCompactThreshold = 1000
+
+// the operations that can be written to the log
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opBatch  = "batch"
+
+	// the records saved with SaveRecords have their own put and delete
+	opPutRecord    = "put-record"
+	opDeleteRecord = "delete-record"
+)
+
+// ErrCorruptLog is returned when a record in the log can be read but doesn't
+// make sense, like a put without a book
+var ErrCorruptLog = errors.New("The log file holds a record that isn't valid")
+
+// logRecord is a single line in the FileStore's log, a batch record holds
+// other records that are all applied together. Kind and Value are only used
+// by the records saved with SaveRecords
+type logRecord struct {
+	Op      string          `json:"op"`
+	ID      uuid.UUID       `json:"id"`
+	Book    *model.Book     `json:"book,omitempty"`
+	Kind    string          `json:"kind,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Records []logRecord     `json:"records,omitempty"`
+}
+
+// changes returns how many changes the record makes to the books, which is
+// what's counted when deciding whether to compact the log
+func (r logRecord) changes() int {
+	if r.Op == opBatch {
+		return len(r.Records)
+	}
+
+	return 1
+}
+
+// FileStore is a BookStore that keeps the books in memory like Library, but
+// also appends every change to a log file on disk which is replayed when the
+// store is opened, so the books survive restarts.
+//
+// Every record is fsynced before the call that wrote it returns, and once the
+// log holds more than twice as many records as there are books and saved
+// records it's compacted down to one record for each of them
+type FileStore struct {
+	sync.Mutex
+	books map[uuid.UUID]model.Book
+
+	// saved holds the records saved with SaveRecords by kind and id
+	saved map[string]map[uuid.UUID]json.RawMessage
+
+	path    string
+	file    *os.File
+	records int
+
+	// CompactThreshold is the smallest number of records the log has to
+	// hold before it's compacted
+	CompactThreshold int
+}
+
+// OpenFileStore opens the log at the given path, creating it if it doesn't
+// exist, and replays it to recover the books. A partially written record at
+// the end of the log, left behind by a crash, is discarded
+func OpenFileStore(path string) (*FileStore, error) {
+	f := &FileStore{
+		books:            make(map[uuid.UUID]model.Book),
+		saved:            make(map[string]map[uuid.UUID]json.RawMessage),
+		path:             path,
+		CompactThreshold: DefaultCompactThreshold,
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open the log file: %v", err)
+	}
+
+	err = f.replay(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f.file = file
+	return f, nil
+}
+
+// replay reads every record in the log and applies it to the books map,
+// leaving the file positioned at the end of the last good record
+func (f *FileStore) replay(file *os.File) error {
+	reader := bufio.NewReader(file)
+
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to read the log file: %v", err)
+		}
+
+		var record logRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			// a bad record is only expected at the very end of the log,
+			// anywhere else and the log has been corrupted
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				return fmt.Errorf("The log file is corrupt at offset %v: %v", offset, err)
+			}
+			break
+		}
+
+		err = record.validate()
+		if err != nil {
+			return fmt.Errorf("The log file is corrupt at offset %v: %w", offset, err)
+		}
+
+		f.apply(record)
+		f.records += record.changes()
+		offset += int64(len(line))
+	}
+
+	// throw away anything after the last complete record
+	err := file.Truncate(offset)
+	if err != nil {
+		return fmt.Errorf("Unable to truncate the log file: %v", err)
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// validate returns ErrCorruptLog if the record, or any record in a batch,
+// can't be applied
+func (r logRecord) validate() error {
+	switch r.Op {
+	case opPut:
+		if r.Book == nil {
+			return ErrCorruptLog
+		}
+	case opDelete:
+	case opPutRecord:
+		if r.Kind == "" || r.Value == nil {
+			return ErrCorruptLog
+		}
+	case opDeleteRecord:
+		if r.Kind == "" {
+			return ErrCorruptLog
+		}
+	case opBatch:
+		for _, record := range r.Records {
+			err := record.validate()
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrCorruptLog
+	}
+
+	return nil
+}
+
+// apply makes the change in a record to the books map
+func (f *FileStore) apply(record logRecord) {
+	switch record.Op {
+	case opPut:
+		f.books[record.ID] = *record.Book
+	case opDelete:
+		delete(f.books, record.ID)
+	case opPutRecord:
+		if f.saved[record.Kind] == nil {
+			f.saved[record.Kind] = make(map[uuid.UUID]json.RawMessage)
+		}
+		f.saved[record.Kind][record.ID] = record.Value
+	case opDeleteRecord:
+		delete(f.saved[record.Kind], record.ID)
+	case opBatch:
+		for _, r := range record.Records {
+			f.apply(r)
+		}
+	}
+}
+
+// write appends a record to the log and syncs it to disk, the books map is
+// only updated once the record is safely written
+func (f *FileStore) write(record logRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal the log record: %v", err)
+	}
+
+	offset, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("Unable to find the end of the log file: %v", err)
+	}
+
+	_, err = f.file.Write(append(b, '\n'))
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if err != nil {
+		// cut off whatever part of the record made it into the file so the
+		// next record doesn't get appended after garbage
+		f.file.Truncate(offset)
+		f.file.Seek(offset, io.SeekStart)
+		return fmt.Errorf("Unable to write to the log file: %v", err)
+	}
+
+	f.apply(record)
+	f.records += record.changes()
+
+	if f.records > f.CompactThreshold && f.records > 2*f.size() {
+		// the record is already safely in the log, so a failed compaction
+		// isn't a failed write, it'll just be tried again on the next one
+		err = f.compact()
+		if err != nil {
+			log.Errorf("Error compacting the log file: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// size returns how many records the log would hold once it's compacted
+func (f *FileStore) size() int {
+	size := len(f.books)
+	for _, records := range f.saved {
+		size += len(records)
+	}
+
+	return size
+}
+
+// Compact rewrites the log so it only holds one record for each book and
+// saved record
+func (f *FileStore) Compact() error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.compact()
+}
+
+// compact writes every book and saved record to a new log file and then
+// renames it over the old log, so a crash part way through leaves the old log
+// in place
+func (f *FileStore) compact() error {
+	tmpPath := f.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to create the compacted log file: %v", err)
+	}
+
+	records := make([]logRecord, 0, f.size())
+	for id := range f.books {
+		book := f.books[id]
+		records = append(records, logRecord{Op: opPut, ID: id, Book: &book})
+	}
+	for kind, saved := range f.saved {
+		for id, value := range saved {
+			records = append(records, logRecord{Op: opPutRecord, ID: id, Kind: kind, Value: value})
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		b, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("Unable to marshal the log record: %v", err)
+		}
+		buf.Write(append(b, '\n'))
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("Unable to write the compacted log file: %v", err)
+	}
+
+	err = os.Rename(tmpPath, f.path)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("Unable to replace the log file: %v", err)
+	}
+
+	// sync the directory so that the rename itself is durable
+	if dir, err := os.Open(filepath.Dir(f.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	f.file.Close()
+	f.file = tmp
+	f.records = len(records)
+
+	return nil
+}
+
+// Close compacts the log and closes the file, the store can't be used after
+// it's been closed
+func (f *FileStore) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	err := f.compact()
+	if err != nil {
+		f.file.Close()
+		return err
+	}
+
+	return f.file.Close()
+}
+
+// Ping returns an error if the store has been closed or its log file is
+// gone, for example because the volume it was on was unmounted
+func (f *FileStore) Ping() error {
+	f.Lock()
+	defer f.Unlock()
+
+	_, err := f.file.Stat()
+	if err == nil {
+		_, err = os.Stat(f.path)
+	}
+	if err != nil {
+		return fmt.Errorf("The log file is unavailable: %v", err)
+	}
+
+	return nil
+}
+
+// GetBooks returns a sorted slice of all of the books in the store
+func (f *FileStore) GetBooks() ([]model.Book, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	books := make([]model.Book, 0, len(f.books))
+	for _, book := range f.books {
+		books = append(books, book)
+	}
+
+	sortBooks(books)
+
+	return books, nil
+}
+
+// GetBookByID returns the book with the given id
+func (f *FileStore) GetBookByID(id uuid.UUID) (model.Book, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	book, found := f.books[id]
+	if !found {
+		return book, ErrNoBookWithThatID
+	}
+
+	return book, nil
+}
+
+// AddBook writes the book to the log
+func (f *FileStore) AddBook(book model.Book) error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.write(logRecord{Op: opPut, ID: book.ID, Book: &book})
+}
+
+// ModifyBook replaces the book with the same id and writes the new book
+// to the log
+func (f *FileStore) ModifyBook(book model.Book) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, found := f.books[book.ID]; !found {
+		return ErrNoBookWithThatID
+	}
+
+	return f.write(logRecord{Op: opPut, ID: book.ID, Book: &book})
+}
+
+// DeleteBook writes the removal of a book to the log if the book exists
+func (f *FileStore) DeleteBook(id uuid.UUID) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, found := f.books[id]; !found {
+		return ErrNoBookWithThatID
+	}
+
+	return f.write(logRecord{Op: opDelete, ID: id})
+}
+
+// ApplyChanges writes all of the changes to the log as a single record, so
+// that after a crash either all of them or none of them are replayed
+func (f *FileStore) ApplyChanges(changes []Change) error {
+	f.Lock()
+	defer f.Unlock()
+
+	batch := logRecord{Op: opBatch}
+	for _, change := range changes {
+		book := change.Book
+		if change.Type == BookDeleted {
+			batch.Records = append(batch.Records, logRecord{Op: opDelete, ID: book.ID})
+		} else {
+			batch.Records = append(batch.Records, logRecord{Op: opPut, ID: book.ID, Book: &book})
+		}
+	}
+
+	return f.write(batch)
+}
+
+// SaveRecords writes all of the records to the log as a single record, like
+// ApplyChanges
+func (f *FileStore) SaveRecords(records []Record) error {
+	f.Lock()
+	defer f.Unlock()
+
+	batch := logRecord{Op: opBatch}
+	for _, record := range records {
+		if record.Value == nil {
+			batch.Records = append(batch.Records, logRecord{Op: opDeleteRecord, ID: record.ID, Kind: record.Kind})
+		} else {
+			batch.Records = append(batch.Records, logRecord{Op: opPutRecord, ID: record.ID, Kind: record.Kind, Value: record.Value})
+		}
+	}
+
+	return f.write(batch)
+}
+
+// GetRecords returns every record of the kind
+func (f *FileStore) GetRecords(kind string) ([]Record, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	records := make([]Record, 0, len(f.saved[kind]))
+	for id, value := range f.saved[kind] {
+		records = append(records, Record{Kind: kind, ID: id, Value: value})
+	}
+
+	return records, nil
+}
@@ -0,0 +1,227 @@
+package managers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// openTestFileStore opens a FileStore at the given path, failing the test if
+// the store can't be opened
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Errorf("Error opening the file store: %v", err)
+		t.FailNow()
+	}
+
+	return store
+}
+
+func TestFileStore(t *testing.T) {
+	testBookStore(t, func(t *testing.T) BookStore {
+		store := openTestFileStore(t, filepath.Join(t.TempDir(), "books.log"))
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	store.AddBook(book)
+
+	deleted := model.NewBook()
+	deleted.Title = "MyDeletedBook"
+	store.AddBook(deleted)
+	store.DeleteBook(deleted.ID)
+
+	modBook := book
+	modBook.Author = "me"
+	store.ModifyBook(modBook)
+
+	// don't close the store so nothing gets compacted, just like a crash
+	store.file.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	books, _ := store.GetBooks()
+	if len(books) != 1 {
+		t.Errorf("Expected 1 book after replaying the log, got %v", len(books))
+		t.FailNow()
+	}
+
+	if books[0].Title != "MyBook" || books[0].Author != "me" {
+		t.Errorf("Replaying the log didn't recover the modified book, got %+v", books[0])
+	}
+}
+
+func TestFileStoreBatchRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+
+	first := model.NewBook()
+	second := model.NewBook()
+	store.ApplyChanges([]Change{
+		{Type: BookCreated, Book: first},
+		{Type: BookCreated, Book: second},
+	})
+	store.ApplyChanges([]Change{{Type: BookDeleted, Book: first}})
+
+	// don't close the store so nothing gets compacted, just like a crash
+	store.file.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	books, _ := store.GetBooks()
+	if len(books) != 1 || books[0].ID != second.ID {
+		t.Errorf("Expected just the second book after replaying the batches, got %+v", books)
+	}
+}
+
+func TestFileStoreTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	store.AddBook(book)
+	store.file.Close()
+
+	// simulate a crash part way through writing a record
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"op":"put","id":"`)
+	file.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	books, _ := store.GetBooks()
+	if len(books) != 1 || books[0].Title != "MyBook" {
+		t.Errorf("Expected the torn record to be discarded, got %+v", books)
+	}
+
+	// the next record should land cleanly after the last good one
+	otherBook := model.NewBook()
+	store.AddBook(otherBook)
+	store.file.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	if count := countBooks(t, store); count != 2 {
+		t.Errorf("Expected 2 books after writing past a torn record, got %v", count)
+	}
+}
+
+func TestFileStoreCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+
+	file, _ := os.Create(path)
+	file.WriteString("garbage\n")
+	file.WriteString(`{"op":"delete","id":"00000000-0000-0000-0000-000000000000"}` + "\n")
+	file.Close()
+
+	_, err := OpenFileStore(path)
+	if err == nil {
+		t.Errorf("Expected an error opening a log that's corrupt before its last record")
+	}
+}
+
+func TestFileStoreRecordWithoutBook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+
+	file, _ := os.Create(path)
+	file.WriteString(`{"op":"batch","records":[{"op":"put","id":"00000000-0000-0000-0000-000000000000"}]}` + "\n")
+	file.Close()
+
+	_, err := OpenFileStore(path)
+	if !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("Expected ErrCorruptLog opening a log with a put record without a book, got %v", err)
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+	store.CompactThreshold = 10
+
+	book := model.NewBook()
+	book.Title = "MyBook"
+	store.AddBook(book)
+
+	// rewrite the same book enough times to trigger a compaction
+	for i := 0; i < 20; i++ {
+		store.AddBook(book)
+	}
+
+	if store.records > store.CompactThreshold {
+		t.Errorf("Expected the log to be compacted, still had %v records", store.records)
+	}
+
+	store.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	if store.records != 1 || countBooks(t, store) != 1 {
+		t.Errorf("Expected 1 record for 1 book after closing the store, got %v records", store.records)
+	}
+}
+
+func TestFileStoreRecordsRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+	store.CompactThreshold = 10
+
+	id, _ := uuid.NewV4()
+	for i := 0; i < 20; i++ {
+		store.SaveRecords([]Record{{Kind: "loan", ID: id, Value: json.RawMessage(fmt.Sprintf(`{"renewals":%v}`, i))}})
+	}
+
+	// compacting has to keep the records as well as the books
+	if store.records > store.CompactThreshold {
+		t.Errorf("Expected the log to be compacted, still had %v records", store.records)
+	}
+
+	store.file.Close()
+
+	store = openTestFileStore(t, path)
+	defer store.Close()
+
+	records, _ := store.GetRecords("loan")
+	if len(records) != 1 || string(records[0].Value) != `{"renewals":19}` {
+		t.Errorf("Expected the last loan record after replaying the log, got %+v", records)
+	}
+}
+
+func TestFileStorePing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.log")
+	store := openTestFileStore(t, path)
+
+	err := store.Ping()
+	if err != nil {
+		t.Errorf("Expected an open file store to be available, got %v", err)
+	}
+
+	os.Remove(path)
+	if store.Ping() == nil {
+		t.Errorf("Expected the file store to be unavailable once its log is gone")
+	}
+
+	store.Close()
+	if store.Ping() == nil {
+		t.Errorf("Expected a closed file store to be unavailable")
+	}
+}
@@ -0,0 +1,303 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// the defaults for FinePolicy, all of the amounts are in cents
+const (
+	DefaultDailyFine      = 25
+	DefaultMaxFine        = 1000
+	DefaultLostItemCharge = 2500
+	DefaultMaxBalance     = 1000
+)
+
+var (
+	// ErrInvalidAmount is returned when a payment or waiver isn't for a
+	// positive amount
+	ErrInvalidAmount = model.ErrInvalidAmount
+
+	// ErrAmountOverBalance is returned when a payment or waiver is for more
+	// than the patron owes
+	ErrAmountOverBalance = model.ErrAmountOverBalance
+
+	// ErrPatronBlocked is returned when a patron who owes more than the
+	// policy allows tries to check out a book
+	ErrPatronBlocked = model.ErrPatronBlocked
+
+	// ErrBookLost is returned when trying to check out or hold a lost book
+	ErrBookLost = model.ErrBookLost
+
+	// ErrBookNotLost is returned when a book that isn't lost is found
+	ErrBookNotLost = model.ErrBookNotLost
+)
+
+// FinePolicy holds the rules for charging patrons, all of the amounts are
+// in cents
+type FinePolicy struct {
+	// DailyFine is charged for each day, or part of a day, a loan is overdue
+	DailyFine int64
+
+	// MaxFine caps the overdue fine for a single loan, 0 leaves it uncapped
+	MaxFine int64
+
+	// LostItemCharge is charged when a patron loses a book
+	LostItemCharge int64
+
+	// MaxBalance is the most a patron can owe, including fines still
+	// accruing, and still check out books
+	MaxBalance int64
+}
+
+// DefaultFinePolicy returns the FinePolicy that new circulations start with
+func DefaultFinePolicy() FinePolicy {
+	return FinePolicy{
+		DailyFine:      DefaultDailyFine,
+		MaxFine:        DefaultMaxFine,
+		LostItemCharge: DefaultLostItemCharge,
+		MaxBalance:     DefaultMaxBalance,
+	}
+}
+
+// overdueFine returns the fine for a loan that ends at the given time
+func (p FinePolicy) overdueFine(loan model.Loan, end time.Time) int64 {
+	if !end.After(loan.DueAt) {
+		return 0
+	}
+
+	// every started day counts as a full day
+	overdue := end.Sub(loan.DueAt)
+	days := int64((overdue + 24*time.Hour - 1) / (24 * time.Hour))
+
+	fine := days * p.DailyFine
+	if p.MaxFine > 0 && fine > p.MaxFine {
+		fine = p.MaxFine
+	}
+
+	return fine
+}
+
+// GetLedger returns every charge and credit for a patron along with what
+// they owe
+func (c *Circulation) GetLedger(patronID uuid.UUID) (model.Ledger, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, found := c.patrons[patronID]; !found {
+		return model.Ledger{}, ErrNoPatronWithThatID
+	}
+
+	entries := make([]model.LedgerEntry, len(c.ledgers[patronID]))
+	copy(entries, c.ledgers[patronID])
+
+	return model.Ledger{
+		PatronID: patronID,
+		Entries:  entries,
+		Balance:  c.balance(patronID),
+		Accruing: c.accruing(patronID, c.Clock.Now()),
+	}, nil
+}
+
+// Pay credits a payment to a patron's ledger
+func (c *Circulation) Pay(patronID uuid.UUID, amount int64, note string) (model.LedgerEntry, error) {
+	return c.credit(patronID, model.Payment, amount, note)
+}
+
+// Waive credits a waiver to a patron's ledger, forgiving part of what they owe
+func (c *Circulation) Waive(patronID uuid.UUID, amount int64, note string) (model.LedgerEntry, error) {
+	return c.credit(patronID, model.Waiver, amount, note)
+}
+
+// credit takes an amount off of what a patron owes
+func (c *Circulation) credit(patronID uuid.UUID, entryType model.LedgerEntryType, amount int64, note string) (model.LedgerEntry, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	if _, found := c.patrons[patronID]; !found {
+		return model.LedgerEntry{}, ErrNoPatronWithThatID
+	}
+
+	if amount <= 0 {
+		return model.LedgerEntry{}, ErrInvalidAmount
+	}
+
+	if amount > c.balance(patronID) {
+		return model.LedgerEntry{}, ErrAmountOverBalance
+	}
+
+	return c.post(patronID, nil, entryType, -amount, note), c.save()
+}
+
+// ReportLost ends the active loan for a book because the patron lost it,
+// charging the patron for the lost book along with any overdue fine, and
+// marks the book as Lost. Anyone waiting for the book has their hold dropped
+func (c *Circulation) ReportLost(bookID uuid.UUID) (model.Loan, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	loanID, found := c.active[bookID]
+	if !found {
+		return model.Loan{}, ErrBookNotCheckedOut
+	}
+
+	err := setStatus(bookID, model.Lost)
+	if err != nil && err != ErrNoBookWithThatID {
+		return model.Loan{}, err
+	}
+
+	loan := c.loans[loanID]
+	loan.LostAt = &now
+
+	c.loans[loanID] = loan
+	delete(c.active, bookID)
+	c.putRecord(loanRecord, loan.ID, loan)
+
+	for _, hold := range c.holds[bookID] {
+		c.deleteRecord(holdRecord, hold.ID)
+	}
+	delete(c.holds, bookID)
+
+	c.chargeOverdueFine(loan, now)
+	if c.FinePolicy.LostItemCharge > 0 {
+		c.post(loan.PatronID, &loan.ID, model.LostItem, c.FinePolicy.LostItemCharge, "")
+	}
+
+	return loan, c.save()
+}
+
+// Found takes a lost book out of Lost and checks it back in, and marks the
+// loan it was lost on as found. If credit is true the patron who lost it is
+// refunded whatever they were charged for losing it, but not the overdue
+// fine
+func (c *Circulation) Found(bookID uuid.UUID, credit bool) (model.Book, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	// nobody can be waiting for a lost book, since the holds on it were
+	// dropped when it was lost
+	book, err := UpdateBook(bookID, func(book *model.Book) error {
+		if book.Status != model.Lost {
+			return ErrBookNotLost
+		}
+
+		book.Status = model.CheckedIn
+		return nil
+	})
+	if err != nil {
+		return model.Book{}, err
+	}
+
+	// ReportLost marks the book as Lost before its loan is saved, so if
+	// saving the loan failed and the process restarted there's no loan to
+	// find, just the book to check back in
+	loan, found := c.lostLoan(bookID)
+	if !found {
+		return book, nil
+	}
+
+	loan.FoundAt = &now
+	c.loans[loan.ID] = loan
+	c.putRecord(loanRecord, loan.ID, loan)
+
+	if charged := c.lostItemCharge(loan); credit && charged > 0 {
+		c.post(loan.PatronID, &loan.ID, model.LostItemRefund, -charged, "")
+	}
+
+	return book, c.save()
+}
+
+// lostLoan returns the loan a book was last lost on, and false if it was
+// never lost on a loan or has already been found
+func (c *Circulation) lostLoan(bookID uuid.UUID) (model.Loan, bool) {
+	var lost model.Loan
+	found := false
+
+	for _, loan := range c.loans {
+		if loan.BookID != bookID || loan.LostAt == nil || loan.FoundAt != nil {
+			continue
+		}
+
+		if !found || loan.LostAt.After(*lost.LostAt) {
+			lost, found = loan, true
+		}
+	}
+
+	return lost, found
+}
+
+// lostItemCharge returns what the patron was charged for losing the book on
+// the loan
+func (c *Circulation) lostItemCharge(loan model.Loan) int64 {
+	var charged int64
+	for _, entry := range c.ledgers[loan.PatronID] {
+		if entry.Type == model.LostItem && entry.LoanID != nil && *entry.LoanID == loan.ID {
+			charged += entry.Amount
+		}
+	}
+
+	return charged
+}
+
+// chargeOverdueFine posts the overdue fine for a loan that's ending
+func (c *Circulation) chargeOverdueFine(loan model.Loan, end time.Time) {
+	fine := c.FinePolicy.overdueFine(loan, end)
+	if fine > 0 {
+		c.post(loan.PatronID, &loan.ID, model.OverdueFine, fine, "")
+	}
+}
+
+// blocked returns true if the patron owes more than the policy allows,
+// counting the fines still accruing on their overdue loans
+func (c *Circulation) blocked(patronID uuid.UUID, now time.Time) bool {
+	return c.balance(patronID)+c.accruing(patronID, now) > c.FinePolicy.MaxBalance
+}
+
+// post adds an entry to a patron's ledger
+func (c *Circulation) post(patronID uuid.UUID, loanID *uuid.UUID, entryType model.LedgerEntryType, amount int64, note string) model.LedgerEntry {
+	entry := model.NewLedgerEntry()
+	entry.PatronID = patronID
+	entry.LoanID = loanID
+	entry.Type = entryType
+	entry.Amount = amount
+	entry.Note = note
+	entry.CreatedAt = c.Clock.Now()
+
+	c.ledgers[patronID] = append(c.ledgers[patronID], entry)
+	c.putRecord(ledgerEntryRecord, entry.ID, entry)
+
+	return entry
+}
+
+// balance returns what the patron owes from the entries on their ledger
+func (c *Circulation) balance(patronID uuid.UUID) int64 {
+	var balance int64
+	for _, entry := range c.ledgers[patronID] {
+		balance += entry.Amount
+	}
+
+	return balance
+}
+
+// accruing returns the fines the patron's overdue loans would be charged if
+// they were returned at the given time
+func (c *Circulation) accruing(patronID uuid.UUID, now time.Time) int64 {
+	var accruing int64
+	for _, loanID := range c.active {
+		loan := c.loans[loanID]
+		if loan.PatronID == patronID {
+			accruing += c.FinePolicy.overdueFine(loan, now)
+		}
+	}
+
+	return accruing
+}
@@ -0,0 +1,185 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// ledger returns the patron's ledger, failing the test if it can't
+func ledger(t *testing.T, c *Circulation, patron model.Patron) model.Ledger {
+	ledger, err := c.GetLedger(patron.ID)
+	if err != nil {
+		t.Errorf("Error getting the ledger: %v", err)
+		t.FailNow()
+	}
+
+	return ledger
+}
+
+func TestOverdueFine(t *testing.T) {
+	policy := FinePolicy{DailyFine: 25, MaxFine: 100}
+	due := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	loan := model.Loan{DueAt: due}
+
+	tests := []struct {
+		late time.Duration
+		fine int64
+	}{
+		{-time.Hour, 0},
+		{0, 0},
+		{time.Minute, 25},
+		{24 * time.Hour, 25},
+		{25 * time.Hour, 50},
+		{30 * 24 * time.Hour, 100},
+	}
+
+	for _, test := range tests {
+		if fine := policy.overdueFine(loan, due.Add(test.late)); fine != test.fine {
+			t.Errorf("Expected a fine of %v for a loan %v late, got %v", test.fine, test.late, fine)
+		}
+	}
+}
+
+func TestReturnChargesOverdueFine(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	circulation.Checkout(book.ID, patron.ID)
+	testClock(circulation).Advance(DefaultLoanPeriod + 2*24*time.Hour)
+
+	if accruing := ledger(t, circulation, patron).Accruing; accruing != 2*DefaultDailyFine {
+		t.Errorf("Expected %v accruing on the overdue loan, got %v", 2*DefaultDailyFine, accruing)
+	}
+
+	circulation.Return(book.ID)
+
+	l := ledger(t, circulation, patron)
+	if l.Balance != 2*DefaultDailyFine || l.Accruing != 0 || len(l.Entries) != 1 || l.Entries[0].Type != model.OverdueFine {
+		t.Errorf("Expected the overdue fine to be charged on return, got %+v", l)
+	}
+}
+
+func TestReportLost(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	waiting := addTestPatron(circulation, "Waiting")
+
+	circulation.Checkout(book.ID, patron.ID)
+	circulation.PlaceHold(book.ID, waiting.ID)
+
+	loan, err := circulation.ReportLost(book.ID)
+	if err != nil {
+		t.Errorf("Error reporting a book lost: %v", err)
+		t.FailNow()
+	}
+
+	if loan.Active() || loan.LostAt == nil {
+		t.Errorf("Expected reporting a book lost to end the loan, got %+v", loan)
+	}
+
+	if bookStatus(t, book.ID) != model.Lost {
+		t.Errorf("Expected the book to be marked as Lost")
+	}
+
+	if holds, _ := circulation.GetHolds(book.ID); len(holds) != 0 {
+		t.Errorf("Expected the holds on a lost book to be dropped, got %+v", holds)
+	}
+
+	if l := ledger(t, circulation, patron); l.Balance != DefaultLostItemCharge {
+		t.Errorf("Expected the patron to be charged %v for the lost book, got %+v", DefaultLostItemCharge, l)
+	}
+
+	if _, err = circulation.Checkout(book.ID, waiting.ID); err != ErrBookLost {
+		t.Errorf("Expected %v checking out a lost book, got %v", ErrBookLost, err)
+	}
+}
+
+func TestFound(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	circulation.Checkout(book.ID, patron.ID)
+	circulation.ReportLost(book.ID)
+
+	found, err := circulation.Found(book.ID, true)
+	if err != nil {
+		t.Errorf("Error finding a lost book: %v", err)
+		t.FailNow()
+	}
+
+	if found.Status != model.CheckedIn || bookStatus(t, book.ID) != model.CheckedIn {
+		t.Errorf("Expected a found book to be checked in, got %+v", found)
+	}
+
+	l := ledger(t, circulation, patron)
+	if l.Balance != 0 || len(l.Entries) != 2 || l.Entries[1].Type != model.LostItemRefund {
+		t.Errorf("Expected the lost item charge to be refunded, got %+v", l)
+	}
+
+	loans, _ := circulation.PatronLoans(patron.ID)
+	if len(loans) != 1 || loans[0].FoundAt == nil {
+		t.Errorf("Expected the loan the book was lost on to be found, got %+v", loans)
+	}
+
+	if _, err = circulation.Found(book.ID, true); err != ErrBookNotLost {
+		t.Errorf("Expected %v finding a book that isn't lost, got %v", ErrBookNotLost, err)
+	}
+
+	if _, err = circulation.Checkout(book.ID, patron.ID); err != nil {
+		t.Errorf("Error checking out a found book: %v", err)
+	}
+}
+
+func TestFoundWithoutCredit(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	circulation.Checkout(book.ID, patron.ID)
+	circulation.ReportLost(book.ID)
+
+	if _, err := circulation.Found(book.ID, false); err != nil {
+		t.Errorf("Error finding a lost book: %v", err)
+		t.FailNow()
+	}
+
+	if l := ledger(t, circulation, patron); l.Balance != DefaultLostItemCharge {
+		t.Errorf("Expected the lost item charge to be kept without a credit, got %+v", l)
+	}
+}
+
+func TestBlockedPatron(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	other := model.NewBook()
+	GetLibrary().AddBook(other)
+
+	circulation.Checkout(other.ID, patron.ID)
+	circulation.ReportLost(other.ID)
+
+	if _, err := circulation.Checkout(book.ID, patron.ID); err != ErrPatronBlocked {
+		t.Errorf("Expected %v checking out with a balance over the maximum, got %v", ErrPatronBlocked, err)
+	}
+
+	// paying off part of the balance and waiving the rest unblocks the patron
+	if _, err := circulation.Pay(patron.ID, DefaultLostItemCharge+1, ""); err != ErrAmountOverBalance {
+		t.Errorf("Expected %v paying more than the balance, got %v", ErrAmountOverBalance, err)
+	}
+
+	if _, err := circulation.Pay(patron.ID, 0, ""); err != ErrInvalidAmount {
+		t.Errorf("Expected %v paying nothing, got %v", ErrInvalidAmount, err)
+	}
+
+	if _, err := circulation.Pay(patron.ID, 1000, "cash"); err != nil {
+		t.Errorf("Error paying: %v", err)
+	}
+
+	if _, err := circulation.Waive(patron.ID, DefaultLostItemCharge-1000, "found it"); err != nil {
+		t.Errorf("Error waiving: %v", err)
+	}
+
+	if l := ledger(t, circulation, patron); l.Balance != 0 || len(l.Entries) != 3 {
+		t.Errorf("Expected a zero balance after paying and waiving, got %+v", l)
+	}
+
+	if _, err := circulation.Checkout(book.ID, patron.ID); err != nil {
+		t.Errorf("Error checking out after paying off the balance: %v", err)
+	}
+}
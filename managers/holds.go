@@ -0,0 +1,272 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrNoHoldWithThatID is the error returned whenever someone tries to
+	// use a hold with an id that isn't found on the book
+	ErrNoHoldWithThatID = model.ErrNoHoldWithThatID
+
+	// ErrBookAvailable is returned when trying to place a hold on a book
+	// that can just be checked out
+	ErrBookAvailable = model.ErrBookAvailable
+
+	// ErrAlreadyOnHold is returned when a patron tries to place a second hold
+	// on the same book
+	ErrAlreadyOnHold = model.ErrAlreadyOnHold
+
+	// ErrPatronHasBook is returned when a patron tries to place a hold on a
+	// book they have checked out
+	ErrPatronHasBook = model.ErrPatronHasBook
+
+	// ErrBookReserved is returned when trying to check out a book that's
+	// reserved for another patron
+	ErrBookReserved = model.ErrBookReserved
+
+	// ErrBookHasHolds is returned when trying to remove a book that patrons
+	// are waiting for
+	ErrBookHasHolds = model.ErrBookHasHolds
+)
+
+// PlaceHold adds a patron to the end of the holds queue for a book, holds
+// can only be placed on books that are checked out or reserved
+func (c *Circulation) PlaceHold(bookID, patronID uuid.UUID) (model.Hold, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	if _, found := c.patrons[patronID]; !found {
+		return model.Hold{}, ErrNoPatronWithThatID
+	}
+
+	book, err := GetLibrary().GetBookByID(bookID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	if book.Status == model.Lost {
+		return model.Hold{}, ErrBookLost
+	}
+
+	err = c.expireHold(bookID, now)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	loanID, checkedOut := c.active[bookID]
+	if !checkedOut && len(c.holds[bookID]) == 0 {
+		return model.Hold{}, ErrBookAvailable
+	}
+
+	if checkedOut && c.loans[loanID].PatronID == patronID {
+		return model.Hold{}, ErrPatronHasBook
+	}
+
+	for _, hold := range c.holds[bookID] {
+		if hold.PatronID == patronID {
+			return model.Hold{}, ErrAlreadyOnHold
+		}
+	}
+
+	hold := model.NewHold()
+	hold.BookID = bookID
+	hold.PatronID = patronID
+	hold.PlacedAt = now
+
+	c.holds[bookID] = append(c.holds[bookID], hold)
+	c.putRecord(holdRecord, hold.ID, hold)
+
+	hold.Position = len(c.holds[bookID])
+	return hold, c.save()
+}
+
+// HasHolds returns true if there are patrons waiting for the book
+func (c *Circulation) HasHolds(bookID uuid.UUID) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.holds[bookID]) > 0
+}
+
+// RemoveBook deletes a book from the global book store like RemoveBook,
+// but only if it isn't checked out and nobody is waiting for it. The
+// circulation stays locked until the book is gone, so it can't be checked
+// out in between
+func (c *Circulation) RemoveBook(id uuid.UUID, check func(book model.Book) error) error {
+	c.Lock()
+	defer c.Unlock()
+
+	return RemoveBook(id, func(book model.Book) error {
+		err := c.removable(id)
+		if err != nil {
+			return err
+		}
+
+		return check(book)
+	})
+}
+
+// removable returns why a book can't be removed from the library, books
+// that are checked out can't be removed until they're returned, and books
+// that patrons are waiting for can't be removed at all
+func (c *Circulation) removable(bookID uuid.UUID) error {
+	if _, found := c.active[bookID]; found {
+		return ErrBookCheckedOut
+	}
+
+	if len(c.holds[bookID]) > 0 {
+		return ErrBookHasHolds
+	}
+
+	return nil
+}
+
+// GetHolds returns the holds queue for a book in order
+func (c *Circulation) GetHolds(bookID uuid.UUID) ([]model.Hold, error) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	err := c.expireHold(bookID, c.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	holds := make([]model.Hold, len(c.holds[bookID]))
+	for i, hold := range c.holds[bookID] {
+		hold.Position = i + 1
+		holds[i] = hold
+	}
+
+	return holds, nil
+}
+
+// GetHold returns a single hold on a book along with its place in the queue
+func (c *Circulation) GetHold(bookID, holdID uuid.UUID) (model.Hold, error) {
+	holds, err := c.GetHolds(bookID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	for _, hold := range holds {
+		if hold.ID == holdID {
+			return hold, nil
+		}
+	}
+
+	return model.Hold{}, ErrNoHoldWithThatID
+}
+
+// CancelHold takes a hold out of the queue, if the book was reserved for the
+// hold then it moves on to the next patron in line
+func (c *Circulation) CancelHold(bookID, holdID uuid.UUID) error {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	queue := c.holds[bookID]
+	for i, hold := range queue {
+		if hold.ID != holdID {
+			continue
+		}
+
+		if !hold.Ready() {
+			c.holds[bookID] = append(queue[:i:i], queue[i+1:]...)
+			c.deleteRecord(holdRecord, hold.ID)
+			return c.save()
+		}
+
+		c.popHold(bookID)
+
+		err := c.reserveNext(bookID, now)
+		if err != nil {
+			return err
+		}
+
+		return c.save()
+	}
+
+	return ErrNoHoldWithThatID
+}
+
+// ExpireHolds moves every reserved book whose pickup window has passed on to
+// the next patron in line, it's meant to be called periodically so that the
+// statuses of unclaimed books don't go stale
+func (c *Circulation) ExpireHolds() error {
+	c.Lock()
+	defer c.Unlock()
+	defer c.flush()
+
+	now := c.Clock.Now()
+
+	for bookID := range c.holds {
+		err := c.expireHold(bookID, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.save()
+}
+
+// expireHold moves a reserved book on to the next patron in line for as long
+// as the hold at the front of the queue has expired
+func (c *Circulation) expireHold(bookID uuid.UUID, now time.Time) error {
+	for {
+		queue := c.holds[bookID]
+		if len(queue) == 0 || !queue[0].Ready() || now.Before(*queue[0].ExpiresAt) {
+			return nil
+		}
+
+		c.popHold(bookID)
+
+		err := c.reserveNext(bookID, now)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reserveNext reserves a book that isn't checked out for the first patron in
+// its holds queue for the pickup window, or marks it as CheckedIn if there
+// aren't any holds
+func (c *Circulation) reserveNext(bookID uuid.UUID, now time.Time) error {
+	queue := c.holds[bookID]
+	if len(queue) == 0 {
+		return setStatus(bookID, model.CheckedIn)
+	}
+
+	err := setStatus(bookID, model.Reserved)
+	if err != nil {
+		return err
+	}
+
+	expires := now.Add(c.Policy.PickupWindow)
+	queue[0].ReadyAt = &now
+	queue[0].ExpiresAt = &expires
+	c.putRecord(holdRecord, queue[0].ID, queue[0])
+
+	return nil
+}
+
+// popHold takes the hold at the front of a book's queue out of the queue
+func (c *Circulation) popHold(bookID uuid.UUID) {
+	queue := c.holds[bookID]
+	c.deleteRecord(holdRecord, queue[0].ID)
+
+	if len(queue) <= 1 {
+		delete(c.holds, bookID)
+		return
+	}
+
+	c.holds[bookID] = queue[1:]
+}
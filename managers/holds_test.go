@@ -0,0 +1,170 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// addTestPatron adds a new patron with the given name to the circulation
+func addTestPatron(c *Circulation, name string) model.Patron {
+	patron := model.NewPatron()
+	patron.Name = name
+	c.AddPatron(patron)
+
+	return patron
+}
+
+func TestHoldsQueue(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	first := addTestPatron(circulation, "First")
+	second := addTestPatron(circulation, "Second")
+
+	// holds can't be placed on a book that's available
+	if _, err := circulation.PlaceHold(book.ID, first.ID); err != ErrBookAvailable {
+		t.Errorf("Expected %v placing a hold on an available book, got %v", ErrBookAvailable, err)
+	}
+
+	circulation.Checkout(book.ID, patron.ID)
+
+	if _, err := circulation.PlaceHold(book.ID, patron.ID); err != ErrPatronHasBook {
+		t.Errorf("Expected %v placing a hold on a book the patron has, got %v", ErrPatronHasBook, err)
+	}
+
+	firstHold, err := circulation.PlaceHold(book.ID, first.ID)
+	if err != nil || firstHold.Position != 1 {
+		t.Errorf("Expected the first hold to be at position 1, got %+v (%v)", firstHold, err)
+	}
+
+	secondHold, err := circulation.PlaceHold(book.ID, second.ID)
+	if err != nil || secondHold.Position != 2 {
+		t.Errorf("Expected the second hold to be at position 2, got %+v (%v)", secondHold, err)
+	}
+
+	if _, err = circulation.PlaceHold(book.ID, first.ID); err != ErrAlreadyOnHold {
+		t.Errorf("Expected %v placing a second hold, got %v", ErrAlreadyOnHold, err)
+	}
+
+	// returning the book should reserve it for the first patron in line
+	circulation.Return(book.ID)
+
+	if bookStatus(t, book.ID) != model.Reserved {
+		t.Errorf("Expected a returned book with holds to be Reserved")
+	}
+
+	hold, _ := circulation.GetHold(book.ID, firstHold.ID)
+	if !hold.Ready() || hold.ExpiresAt.Sub(*hold.ReadyAt) != DefaultPickupWindow {
+		t.Errorf("Expected the first hold to be ready for the pickup window, got %+v", hold)
+	}
+
+	if _, err = circulation.Checkout(book.ID, second.ID); err != ErrBookReserved {
+		t.Errorf("Expected %v checking out a book reserved for someone else, got %v", ErrBookReserved, err)
+	}
+
+	// checking the book out fulfills the hold
+	if _, err = circulation.Checkout(book.ID, first.ID); err != nil {
+		t.Errorf("Error checking out a book reserved for the patron: %v", err)
+	}
+
+	holds, _ := circulation.GetHolds(book.ID)
+	if len(holds) != 1 || holds[0].ID != secondHold.ID || holds[0].Position != 1 {
+		t.Errorf("Expected the second hold to move to the front of the queue, got %+v", holds)
+	}
+}
+
+func TestHoldsExpire(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	first := addTestPatron(circulation, "First")
+	second := addTestPatron(circulation, "Second")
+
+	circulation.Checkout(book.ID, patron.ID)
+	circulation.PlaceHold(book.ID, first.ID)
+	secondHold, _ := circulation.PlaceHold(book.ID, second.ID)
+	circulation.Return(book.ID)
+
+	// let the first hold's pickup window pass
+	testClock(circulation).Advance(DefaultPickupWindow + time.Minute)
+
+	err := circulation.ExpireHolds()
+	if err != nil {
+		t.Errorf("Error expiring holds: %v", err)
+	}
+
+	holds, _ := circulation.GetHolds(book.ID)
+	if len(holds) != 1 || holds[0].ID != secondHold.ID || !holds[0].Ready() {
+		t.Errorf("Expected the book to be reserved for the second patron, got %+v", holds)
+	}
+
+	// once the last hold expires the book is available again
+	testClock(circulation).Advance(DefaultPickupWindow + time.Minute)
+	circulation.ExpireHolds()
+
+	if bookStatus(t, book.ID) != model.CheckedIn {
+		t.Errorf("Expected the book to be CheckedIn once every hold expired")
+	}
+}
+
+func TestCancelReadyHold(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	first := addTestPatron(circulation, "First")
+
+	circulation.Checkout(book.ID, patron.ID)
+	hold, _ := circulation.PlaceHold(book.ID, first.ID)
+	circulation.Return(book.ID)
+
+	err := circulation.CancelHold(book.ID, hold.ID)
+	if err != nil {
+		t.Errorf("Error cancelling a hold: %v", err)
+	}
+
+	if bookStatus(t, book.ID) != model.CheckedIn {
+		t.Errorf("Expected the book to be CheckedIn after its only hold was cancelled")
+	}
+
+	if err = circulation.CancelHold(book.ID, hold.ID); err != ErrNoHoldWithThatID {
+		t.Errorf("Expected %v cancelling a hold twice, got %v", ErrNoHoldWithThatID, err)
+	}
+}
+
+func TestRemoveBookInCirculation(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	circulation.Checkout(book.ID, patron.ID)
+
+	allowed := func(model.Book) error { return nil }
+	if err := circulation.RemoveBook(book.ID, allowed); err != ErrBookCheckedOut {
+		t.Errorf("Expected %v removing a checked out book, got %v", ErrBookCheckedOut, err)
+	}
+
+	circulation.Return(book.ID)
+	if err := circulation.RemoveBook(book.ID, allowed); err != nil {
+		t.Errorf("Error removing a returned book: %v", err)
+	}
+}
+
+func TestRemoveBookRacingCheckout(t *testing.T) {
+	circulation, patron, _ := newTestCirculation(t)
+
+	// whichever of the checkout and the delete goes first, a book is never
+	// deleted while it's checked out
+	for i := 0; i < 100; i++ {
+		book := model.NewBook()
+		book.Title = "MyRacingBook"
+		GetLibrary().AddBook(book)
+
+		removed := make(chan error)
+		go func() {
+			removed <- circulation.RemoveBook(book.ID, func(model.Book) error { return nil })
+		}()
+		_, checkoutErr := circulation.Checkout(book.ID, patron.ID)
+		removeErr := <-removed
+
+		if checkoutErr == nil && removeErr == nil {
+			t.Errorf("A book was both checked out and deleted")
+			t.FailNow()
+		}
+
+		circulation.Return(book.ID)
+	}
+}
@@ -0,0 +1,117 @@
+package managers
+
+import (
+	"sort"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ImportOptions changes how a catalog is read and applied by ImportCSV and
+// ImportMARC
+type ImportOptions = model.ImportOptions
+
+// ImportRowError is why a single row of a catalog couldn't be imported
+type ImportRowError = model.ImportRowError
+
+// ImportReport is the outcome of importing a catalog
+type ImportReport = model.ImportReport
+
+// importer collects the books read from each row of a catalog and then
+// applies them to the library as a batch. Rows with the id of a book that's
+// already in the library, or earlier in the catalog, replace that book and
+// every other row creates a new book. Every row is validated and the rows
+// that can't be imported are listed in the report
+type importer struct {
+	options    ImportOptions
+	report     ImportReport
+	operations []BatchOperation
+	rows       []int
+	seen       map[uuid.UUID]bool
+}
+
+// newImporter returns an importer with nothing read yet
+func newImporter(options ImportOptions) *importer {
+	return &importer{
+		options: options,
+		report:  ImportReport{DryRun: options.DryRun, Errors: []ImportRowError{}},
+		seen:    make(map[uuid.UUID]bool),
+	}
+}
+
+// add queues the book read from a row to be imported, the batch gives the
+// book an id if it doesn't have one
+func (im *importer) add(row int, book model.Book, hasID bool) {
+	im.report.Rows++
+
+	if book.Rating == 0 {
+		book.Rating = im.options.DefaultRating
+	}
+
+	operation := BatchOperation{Op: BatchCreate, Book: book}
+	if hasID {
+		exists := im.seen[book.ID]
+		if !exists {
+			_, err := GetLibrary().GetBookByID(book.ID)
+			exists = err == nil
+		}
+		im.seen[book.ID] = true
+
+		if exists {
+			operation = BatchOperation{Op: BatchUpdate, ID: book.ID, Book: book}
+		}
+	}
+
+	im.operations = append(im.operations, operation)
+	im.rows = append(im.rows, row)
+}
+
+// fail records a row that couldn't be read
+func (im *importer) fail(row int, err error) {
+	im.report.Rows++
+	im.report.Failed++
+	im.report.Errors = append(im.report.Errors, ImportRowError{Row: row, Error: err.Error()})
+}
+
+// apply imports the books from every row that was added and returns the
+// report for the whole import
+func (im *importer) apply() (ImportReport, error) {
+	report := im.report
+	atomic := im.options.Atomic
+
+	// the books in a dry run aren't really being sent to the library, so
+	// they aren't counted in ValidationFailures
+	validate := ValidateBook
+	if im.options.DryRun {
+		validate = model.Book.Validate
+	}
+
+	results, err := applyBatch(im.operations, atomic, im.options.DryRun || (atomic && report.Failed > 0), validate)
+	if err != nil {
+		return report, err
+	}
+
+	for i, result := range results {
+		switch {
+		case result.Error == nil || result.Error == ErrBatchAborted:
+			if result.Op == BatchCreate {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+		default:
+			report.Failed++
+			report.Errors = append(report.Errors, ImportRowError{Row: im.rows[i], Error: result.Error.Error()})
+		}
+	}
+
+	// the rows that couldn't be read were found before the ones that
+	// failed to apply
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	report.Applied = !im.options.DryRun && report.Created+report.Updated > 0 && !(atomic && report.Failed > 0)
+
+	return report, nil
+}
@@ -1,7 +1,7 @@
 package managers
 
 import (
-	"errors"
+	"encoding/json"
 	"sort"
 	"sync"
 
@@ -10,42 +10,27 @@ import (
 )
 
 var (
-	// The global libary instance
-	library Library
-
-	// sync.Once for the singleton
-	once = sync.Once{}
-
 	// ErrNoBookWithThatID is the error returned whenever someone tried to
 	// GET, PUT, or DELETE a book with an id that isn't found in the manager
-	ErrNoBookWithThatID = errors.New("The given book uuid wasn't found")
+	ErrNoBookWithThatID = model.ErrNoBookWithThatID
 )
 
-// Library is the struct that holds all of the books
+// Library is the in memory BookStore, it holds all of the books in a map
+// and loses them when the process exits
 type Library struct {
 	sync.Mutex `json:"-"`
 	Books      map[uuid.UUID]model.Book `json:"books"`
-}
-
-// GetLibrary is a thread safe singleton model which will, on the first
-// time being called, initalize a new libary, and on subsequent calls
-// return that same instance of the library struct
-func GetLibrary() Library {
-
-	// if this is the first time this function has been called
-	// then create a new libary
-	once.Do(func() {
-		library = newLibrary()
-	})
 
-	// return the global library instance
-	return library
+	// records holds the records saved with SaveRecords by kind and id
+	records map[string]map[uuid.UUID]json.RawMessage
 }
 
-// newLibrary will return a newly initalized library struct, it is only
-// to be used by GetLibrary
-func newLibrary() Library {
-	return Library{Books: make(map[uuid.UUID]model.Book)}
+// NewLibrary will return a newly initalized, empty, in memory library
+func NewLibrary() *Library {
+	return &Library{
+		Books:   make(map[uuid.UUID]model.Book),
+		records: make(map[string]map[uuid.UUID]json.RawMessage),
+	}
 }
 
 // sortBooks will just sort a slice of books in place by title
@@ -57,7 +42,7 @@ func sortBooks(books []model.Book) {
 
 // GetBooks returns a sorted slice of all of the books in the
 // library
-func (l *Library) GetBooks() []model.Book {
+func (l *Library) GetBooks() ([]model.Book, error) {
 	l.Lock()
 	defer l.Unlock()
 
@@ -72,7 +57,7 @@ func (l *Library) GetBooks() []model.Book {
 	// sort the books before returning them
 	sortBooks(books)
 
-	return books
+	return books, nil
 }
 
 // AddBook is a thread safe putter for a key in the library's
@@ -100,64 +85,80 @@ func (l *Library) GetBookByID(id uuid.UUID) (model.Book, error) {
 	return book, nil
 }
 
-// ModifyBook will take an a book and update the given book with the same
-// uuid with all of the fields populated
-func (l *Library) ModifyBook(newBook model.Book) error {
+// ModifyBook will replace the book with the same uuid as the given book
+func (l *Library) ModifyBook(book model.Book) error {
 	l.Lock()
 	defer l.Unlock()
 
 	// first see if the book is in the map
-	book, found := l.Books[newBook.ID]
-	if !found {
+	if _, found := l.Books[book.ID]; !found {
 		return ErrNoBookWithThatID
 	}
 
-	// if the book was found then go through each of the
-	// book attributes, and if the newBook's field isn't the default,
-	// then overwrite the book's attribute
+	l.Books[book.ID] = book
 
-	defaultBook := model.NewDefaultBook()
+	return nil
+}
 
-	if newBook.Title != defaultBook.Title {
-		book.Title = newBook.Title
-	}
+// DeleteBook will remove a book from the library if it exists
+func (l *Library) DeleteBook(id uuid.UUID) error {
+	l.Lock()
+	defer l.Unlock()
 
-	if newBook.Author != defaultBook.Author {
-		book.Author = newBook.Author
+	if _, found := l.Books[id]; !found {
+		return ErrNoBookWithThatID
 	}
 
-	if newBook.Publisher != defaultBook.Publisher {
-		book.Publisher = newBook.Publisher
-	}
+	delete(l.Books, id)
+	return nil
+}
 
-	if newBook.PublishDate != defaultBook.PublishDate {
-		book.PublishDate = newBook.PublishDate
-	}
+// ApplyChanges makes all of the changes while holding the library's lock, so
+// no one sees the library part way through them
+func (l *Library) ApplyChanges(changes []Change) error {
+	l.Lock()
+	defer l.Unlock()
 
-	if newBook.Rating != defaultBook.Rating {
-		book.Rating = newBook.Rating
+	for _, change := range changes {
+		if change.Type == BookDeleted {
+			delete(l.Books, change.Book.ID)
+		} else {
+			l.Books[change.Book.ID] = change.Book
+		}
 	}
 
-	if newBook.Status != defaultBook.Status {
-		book.Status = newBook.Status
-	}
+	return nil
+}
 
-	// overwrite the book in the map with the modified book
-	// to get the new parameters
-	l.Books[book.ID] = book
+// SaveRecords stores or deletes every record while holding the library's lock
+func (l *Library) SaveRecords(records []Record) error {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, record := range records {
+		if record.Value == nil {
+			delete(l.records[record.Kind], record.ID)
+			continue
+		}
+
+		if l.records[record.Kind] == nil {
+			l.records[record.Kind] = make(map[uuid.UUID]json.RawMessage)
+		}
+		l.records[record.Kind][record.ID] = record.Value
+	}
 
 	return nil
 }
 
-// DeleteBook will remove a book from the library if it exists
-func (l *Library) DeleteBook(id uuid.UUID) error {
+// GetRecords returns every record of the kind
+func (l *Library) GetRecords(kind string) ([]Record, error) {
 	l.Lock()
 	defer l.Unlock()
 
-	if _, found := l.Books[id]; !found {
-		return ErrNoBookWithThatID
+	records := make([]Record, 0, len(l.records[kind]))
+	for id, value := range l.records[kind] {
+		records = append(records, Record{Kind: kind, ID: id, Value: value})
 	}
 
-	delete(l.Books, id)
-	return nil
+	return records, nil
 }
@@ -0,0 +1,526 @@
+package managers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// MARCXMLNamespace is the namespace of MARCXML documents
+const MARCXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+// the bytes that split up an ISO 2709 record
+const (
+	marcSubfieldDelimiter = 0x1F
+	marcFieldTerminator   = 0x1E
+	marcRecordTerminator  = 0x1D
+)
+
+// marcLeader is the leader written for every book, a new (n) record for
+// language material (a), a monograph (m), in unicode (a), cataloged with
+// ISBD punctuation (i). The record length and the base address of the data,
+// the two runs of zeros, are filled in when the record is written
+const marcLeader = "00000nam a2200000 i 4500"
+
+var (
+	// ErrInvalidMARC is returned when a MARC21 file is broken in a way that
+	// means none of the records after the break can be found
+	ErrInvalidMARC = model.ErrInvalidMARC
+
+	// ErrMARCTooLong is returned when a book has more in it than fits into
+	// an ISO 2709 record
+	ErrMARCTooLong = errors.New("The book is too long to write as a MARC21 record")
+
+	// marcYear finds the year in a free text date like "c1965."
+	marcYear = regexp.MustCompile(`[0-9]{4}`)
+
+	// marcISBNQualifier finds the qualifier that can follow an isbn, like
+	// " (pbk.)"
+	marcISBNQualifier = regexp.MustCompile(`\s*\([^()]*\)\s*$`)
+)
+
+// marcRecord is a MARC21 bibliographic record, it's also the layout of a
+// record in MARCXML
+type marcRecord struct {
+	XMLName       xml.Name           `xml:"record"`
+	Leader        string             `xml:"leader"`
+	ControlFields []marcControlField `xml:"controlfield"`
+	DataFields    []marcDataField    `xml:"datafield"`
+}
+
+// marcControlField is one of the 00X fields, which hold a single value
+type marcControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+// marcDataField is a field with indicators and subfields
+type marcDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+// marcSubfield is a single coded value in a data field
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// dataField returns the first data field with the given tag, and if ind2
+// isn't empty, the given second indicator
+func (r marcRecord) dataField(tag, ind2 string) (marcDataField, bool) {
+	for _, field := range r.DataFields {
+		if field.Tag == tag && (ind2 == "" || field.Ind2 == ind2) {
+			return field, true
+		}
+	}
+
+	return marcDataField{}, false
+}
+
+// subfield returns the value of the first subfield with the given code
+func (f marcDataField) subfield(code string) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+
+	return ""
+}
+
+// bookMARC returns the MARC21 record for a book. The fields that are mapped
+// are 001 (id), 008 (the year published), 020 $a (isbn), 100 $a (author),
+// 245 $a (title), 264 $b and $c (publisher and publish date), and the rating,
+// which MARC has no field for, goes in the local field 999 $r
+func bookMARC(book model.Book) marcRecord {
+	record := marcRecord{
+		Leader: marcLeader,
+		ControlFields: []marcControlField{
+			{Tag: "001", Value: book.ID.String()},
+			{Tag: "008", Value: marc008(book)},
+		},
+	}
+
+	addField := func(tag, ind1, ind2 string, subfields ...marcSubfield) {
+		record.DataFields = append(record.DataFields, marcDataField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: subfields})
+	}
+
+	if book.ISBN != "" {
+		addField("020", " ", " ", marcSubfield{"a", book.ISBN})
+	}
+
+	// the first indicator of the title says whether there's a main entry,
+	// the author, that the title is an added entry after
+	titleInd1 := "0"
+	if book.Author != "" {
+		addField("100", "1", " ", marcSubfield{"a", book.Author})
+		titleInd1 = "1"
+	}
+
+	addField("245", titleInd1, "0", marcSubfield{"a", book.Title})
+
+	var publication []marcSubfield
+	if book.Publisher != "" {
+		publication = append(publication, marcSubfield{"b", book.Publisher})
+	}
+	if book.PublishDate != nil {
+		publication = append(publication, marcSubfield{"c", marcDate(*book.PublishDate)})
+	}
+	if len(publication) > 0 {
+		addField("264", " ", "1", publication...)
+	}
+
+	if book.Rating != 0 {
+		addField("999", " ", " ", marcSubfield{"r", strconv.Itoa(int(book.Rating))})
+	}
+
+	return record
+}
+
+// marc008 returns the fixed length data elements for a book, everything but
+// the date is filled with | which means no attempt was made to code it
+func marc008(book model.Book) string {
+	dateType, year := "n", "uuuu"
+	if book.PublishDate != nil {
+		dateType, year = "s", fmt.Sprintf("%04d", book.PublishDate.UTC().Year())
+	}
+
+	return "||||||" + dateType + year + "    " + "xx " + strings.Repeat("|", 17) + "und" + "|" + "d"
+}
+
+// marcDate formats a publish date for 264 $c, just the date when there's no
+// time of day so it reads the way other systems expect
+func marcDate(date time.Time) string {
+	date = date.UTC()
+	if date.Equal(date.Truncate(24 * time.Hour)) {
+		return date.Format("2006-01-02")
+	}
+
+	return date.Format(time.RFC3339)
+}
+
+// marcBook reads a book out of a MARC21 record, along with whether the
+// record's control number is one of our ids. Our own records are read back
+// exactly as they were written. Records from other systems are read as well
+// as can be, ISBD punctuation is stripped from the ends of fields, a title's
+// subtitle is joined onto it, 260 is used when there's no 264, and a free
+// text publish date becomes the first of January of the year in it
+func marcBook(record marcRecord) (model.Book, bool, error) {
+	var book model.Book
+	hasID := false
+
+	for _, field := range record.ControlFields {
+		if field.Tag != "001" {
+			continue
+		}
+		if id, err := uuid.FromString(strings.TrimSpace(field.Value)); err == nil {
+			book.ID = id
+			hasID = true
+		}
+	}
+
+	// the punctuation at the end of our own fields is part of the value
+	clean := trimISBD
+	if hasID {
+		clean = func(value string) string { return value }
+	}
+
+	if field, found := record.dataField("020", ""); found {
+		// the isbn can be followed by a qualifier like "(pbk.)", and other
+		// systems sometimes leave out the brackets, but the isbn itself
+		// can have spaces in it
+		isbn := marcISBNQualifier.ReplaceAllString(field.subfield("a"), "")
+		if words := strings.Fields(isbn); !hasID && len(words) > 0 {
+			isbn = words[0]
+		}
+		book.ISBN = isbn
+	}
+
+	if field, found := record.dataField("100", ""); found {
+		book.Author = clean(field.subfield("a"))
+	}
+
+	if field, found := record.dataField("245", ""); found {
+		book.Title = clean(field.subfield("a"))
+		if subtitle := clean(field.subfield("b")); subtitle != "" {
+			book.Title += ": " + subtitle
+		}
+	}
+
+	publication, found := record.dataField("264", "1")
+	if !found {
+		publication, found = record.dataField("260", "")
+	}
+	if found {
+		book.Publisher = clean(publication.subfield("b"))
+
+		if date := trimISBD(publication.subfield("c")); date != "" {
+			publishDate, err := parseMARCDate(date)
+			if err != nil {
+				return book, hasID, err
+			}
+			book.PublishDate = &publishDate
+		}
+	}
+
+	if field, found := record.dataField("999", ""); found {
+		if r := field.subfield("r"); r != "" {
+			rating, err := strconv.ParseUint(r, 10, 8)
+			if err != nil {
+				return book, hasID, model.ErrInvalidRating
+			}
+			book.Rating = uint8(rating)
+		}
+	}
+
+	return book, hasID, nil
+}
+
+// trimISBD strips the punctuation that ISBD puts between fields, like the
+// " /" after a title, off of the end of a value
+func trimISBD(value string) string {
+	value = strings.TrimSpace(value)
+	for _, suffix := range []string{" /", " :", " ;", " =", ","} {
+		value = strings.TrimSpace(strings.TrimSuffix(value, suffix))
+	}
+
+	return value
+}
+
+// parseMARCDate reads a publish date written by marcDate, or the year out of
+// a date written by another system
+func parseMARCDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+
+	year := marcYear.FindString(s)
+	if year == "" {
+		return time.Time{}, fmt.Errorf("The publish date %q doesn't have a year in it", s)
+	}
+
+	y, _ := strconv.Atoi(year)
+	return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// WriteMARC writes a MARC21 (ISO 2709) record for each book
+func WriteMARC(w io.Writer, books []model.Book) error {
+	for _, book := range books {
+		b, err := encodeMARC(bookMARC(book))
+		if err != nil {
+			return fmt.Errorf("Unable to write the book %v: %v", book.ID, err)
+		}
+
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeMARC returns the ISO 2709 encoding of a record
+func encodeMARC(record marcRecord) ([]byte, error) {
+	var directory, data bytes.Buffer
+
+	addField := func(tag string, value []byte) error {
+		length := len(value) + 1
+		if length > 9999 {
+			return ErrMARCTooLong
+		}
+
+		fmt.Fprintf(&directory, "%3s%04d%05d", tag, length, data.Len())
+		data.Write(value)
+		data.WriteByte(marcFieldTerminator)
+		return nil
+	}
+
+	for _, field := range record.ControlFields {
+		if err := addField(field.Tag, []byte(field.Value)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range record.DataFields {
+		value := []byte(field.Ind1 + field.Ind2)
+		for _, subfield := range field.Subfields {
+			value = append(value, marcSubfieldDelimiter)
+			value = append(value, subfield.Code...)
+			value = append(value, subfield.Value...)
+		}
+
+		if err := addField(field.Tag, value); err != nil {
+			return nil, err
+		}
+	}
+	directory.WriteByte(marcFieldTerminator)
+
+	base := len(marcLeader) + directory.Len()
+	length := base + data.Len() + 1
+	if length > 99999 {
+		return nil, ErrMARCTooLong
+	}
+
+	leader := fmt.Sprintf("%05d%s%05d%s", length, record.Leader[5:12], base, record.Leader[17:])
+
+	b := make([]byte, 0, length)
+	b = append(b, leader...)
+	b = append(b, directory.Bytes()...)
+	b = append(b, data.Bytes()...)
+	return append(b, marcRecordTerminator), nil
+}
+
+// decodeMARC reads a single ISO 2709 record
+func decodeMARC(b []byte) (marcRecord, error) {
+	var record marcRecord
+
+	if len(b) < len(marcLeader)+1 || b[len(b)-1] != marcRecordTerminator {
+		return record, errors.New("The record doesn't end with a record terminator")
+	}
+	record.Leader = string(b[:len(marcLeader)])
+
+	base, err := strconv.Atoi(string(b[12:17]))
+	if err != nil || base <= len(marcLeader) || base > len(b) || b[base-1] != marcFieldTerminator {
+		return record, errors.New("The record's base address of data is wrong")
+	}
+
+	directory := b[len(marcLeader) : base-1]
+	if len(directory)%12 != 0 {
+		return record, errors.New("The record's directory is the wrong length")
+	}
+
+	for i := 0; i < len(directory); i += 12 {
+		entry := string(directory[i : i+12])
+		tag := entry[:3]
+		length, lengthErr := strconv.Atoi(entry[3:7])
+		start, startErr := strconv.Atoi(entry[7:12])
+		if lengthErr != nil || startErr != nil || length < 1 || base+start+length > len(b)-1 {
+			return record, fmt.Errorf("The directory entry for field %v is wrong", tag)
+		}
+
+		// drop the field terminator
+		value := b[base+start : base+start+length-1]
+
+		if strings.HasPrefix(tag, "00") {
+			record.ControlFields = append(record.ControlFields, marcControlField{Tag: tag, Value: string(value)})
+			continue
+		}
+
+		if len(value) < 2 {
+			return record, fmt.Errorf("Field %v is missing its indicators", tag)
+		}
+
+		field := marcDataField{Tag: tag, Ind1: string(value[0]), Ind2: string(value[1])}
+		for _, subfield := range bytes.Split(value[2:], []byte{marcSubfieldDelimiter}) {
+			if len(subfield) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, marcSubfield{Code: string(subfield[0]), Value: string(subfield[1:])})
+		}
+		record.DataFields = append(record.DataFields, field)
+	}
+
+	return record, nil
+}
+
+// readMARC reads the bytes of the next ISO 2709 record, skipping any line
+// breaks between records, it returns io.EOF once there are no more records
+func readMARC(r *bufio.Reader) ([]byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c != '\n' && c != '\r' {
+			r.UnreadByte()
+			break
+		}
+	}
+
+	prefix, err := r.Peek(5)
+	if err != nil {
+		return nil, ErrInvalidMARC
+	}
+
+	length, err := strconv.Atoi(string(prefix))
+	if err != nil || length < len(marcLeader)+1 {
+		return nil, ErrInvalidMARC
+	}
+
+	b := make([]byte, length)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, ErrInvalidMARC
+	}
+
+	return b, nil
+}
+
+// ImportMARC reads books from MARC21 (ISO 2709) records into the global book
+// store, each record is a row in the report. The records have to be in
+// unicode, MARC-8 isn't supported
+func ImportMARC(r io.Reader, options ImportOptions) (ImportReport, error) {
+	reader := bufio.NewReader(r)
+
+	im := newImporter(options)
+	for row := 1; ; row++ {
+		b, err := readMARC(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return im.report, err
+		}
+
+		record, err := decodeMARC(b)
+		if err != nil {
+			im.fail(row, err)
+			continue
+		}
+
+		im.addMARC(row, record)
+	}
+
+	return im.apply()
+}
+
+// WriteMARCXML writes a MARCXML collection with a record for each book
+func WriteMARCXML(w io.Writer, books []model.Book) error {
+	_, err := io.WriteString(w, xml.Header+`<collection xmlns="`+MARCXMLNamespace+`">`+"\n")
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("  ", "  ")
+	for _, book := range books {
+		if err = encoder.Encode(bookMARC(book)); err != nil {
+			return err
+		}
+	}
+	if err = encoder.Flush(); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n</collection>\n")
+	return err
+}
+
+// ImportMARCXML reads books from MARCXML into the global book store, either
+// a collection of records or a single record. Each record is a row in the
+// report
+func ImportMARCXML(r io.Reader, options ImportOptions) (ImportReport, error) {
+	decoder := xml.NewDecoder(r)
+
+	im := newImporter(options)
+	row := 0
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return im.report, err
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		row++
+		var record marcRecord
+		if err = decoder.DecodeElement(&record, &start); err != nil {
+			return im.report, err
+		}
+
+		im.addMARC(row, record)
+	}
+
+	return im.apply()
+}
+
+// addMARC queues the book in a MARC21 record to be imported
+func (im *importer) addMARC(row int, record marcRecord) {
+	book, hasID, err := marcBook(record)
+	if err != nil {
+		im.fail(row, err)
+		return
+	}
+
+	im.add(row, book, hasID)
+}
@@ -0,0 +1,216 @@
+package managers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// marcTestBook returns a book with every field that's written to MARC set
+func marcTestBook() model.Book {
+	date := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+	book := model.NewBook()
+	book.Title = "Dune"
+	book.Author = "Herbert, Frank"
+	book.Publisher = "Chilton Books"
+	book.PublishDate = &date
+	book.ISBN = "978-0-441-17271-9"
+	book.Rating = 3
+	return book
+}
+
+func TestMARCRoundTrip(t *testing.T) {
+	formats := []struct {
+		name  string
+		write func(*bytes.Buffer, []model.Book) error
+		read  func(*bytes.Buffer) (ImportReport, error)
+	}{
+		{
+			"marc",
+			func(buf *bytes.Buffer, books []model.Book) error { return WriteMARC(buf, books) },
+			func(buf *bytes.Buffer) (ImportReport, error) { return ImportMARC(buf, ImportOptions{}) },
+		},
+		{
+			"marcxml",
+			func(buf *bytes.Buffer, books []model.Book) error { return WriteMARCXML(buf, books) },
+			func(buf *bytes.Buffer) (ImportReport, error) { return ImportMARCXML(buf, ImportOptions{}) },
+		},
+	}
+
+	for _, format := range formats {
+		book := marcTestBook()
+		untitled := model.NewBook()
+		untitled.Title = "Untitled"
+		untitled.Rating = 1
+
+		// what looks like ISBD punctuation is part of our own values, and so
+		// are tabs and newlines, the only control characters a book can have
+		punctuated := model.NewBook()
+		punctuated.Title = "Who Goes\tThere?\r\n /"
+		punctuated.Author = "Campbell, John W.,"
+		punctuated.Publisher = "Shasta ;"
+		punctuated.ISBN = "0 441 17271 7"
+		punctuated.Rating = 2
+
+		var buf bytes.Buffer
+		err := format.write(&buf, []model.Book{book, untitled, punctuated})
+		if err != nil {
+			t.Errorf("%v: Error writing the books: %v", format.name, err)
+			t.FailNow()
+		}
+
+		SetLibrary(NewLibrary())
+		report, err := format.read(&buf)
+		if err != nil {
+			t.Errorf("%v: Error importing the books: %v", format.name, err)
+			t.FailNow()
+		}
+
+		if report.Rows != 3 || report.Created != 3 || report.Failed != 0 || !report.Applied {
+			t.Errorf("%v: Expected 3 books to be created, got %+v", format.name, report)
+		}
+
+		imported, err := GetLibrary().GetBookByID(book.ID)
+		if err != nil || imported.Title != book.Title || imported.Author != book.Author ||
+			imported.Publisher != book.Publisher || !imported.PublishDate.Equal(*book.PublishDate) ||
+			imported.ISBN != book.ISBN || imported.Rating != book.Rating {
+			t.Errorf("%v: Expected %+v to be imported, got %+v (%v)", format.name, book, imported, err)
+		}
+
+		imported, err = GetLibrary().GetBookByID(untitled.ID)
+		if err != nil || imported.Title != "Untitled" || imported.PublishDate != nil || imported.Author != "" {
+			t.Errorf("%v: Expected %+v to be imported, got %+v (%v)", format.name, untitled, imported, err)
+		}
+
+		imported, err = GetLibrary().GetBookByID(punctuated.ID)
+		if err != nil || imported.Title != punctuated.Title || imported.Author != punctuated.Author ||
+			imported.Publisher != punctuated.Publisher || imported.ISBN != punctuated.ISBN {
+			t.Errorf("%v: Expected %+v to be imported, got %+v (%v)", format.name, punctuated, imported, err)
+		}
+	}
+
+	SetLibrary(NewLibrary())
+}
+
+func TestEncodeMARC(t *testing.T) {
+	b, err := encodeMARC(bookMARC(marcTestBook()))
+	if err != nil {
+		t.Errorf("Error encoding the record: %v", err)
+		t.FailNow()
+	}
+
+	if string(b[:5]) != fmt.Sprintf("%05d", len(b)) {
+		t.Errorf("Expected the leader to start with the record length %v, got %q", len(b), b[:5])
+	}
+
+	if b[len(b)-1] != marcRecordTerminator {
+		t.Errorf("Expected the record to end with a record terminator")
+	}
+
+	record, err := decodeMARC(b)
+	if err != nil {
+		t.Errorf("Error decoding the record: %v", err)
+		t.FailNow()
+	}
+
+	field, found := record.dataField("245", "")
+	if !found || field.Ind1 != "1" || field.subfield("a") != "Dune" {
+		t.Errorf("Expected a 245 field with the title, got %+v", record.DataFields)
+	}
+
+	for _, control := range record.ControlFields {
+		if control.Tag == "008" && (len(control.Value) != 40 || control.Value[7:11] != "1965") {
+			t.Errorf("Expected the 008 field to be 40 characters with the year 1965, got %q", control.Value)
+		}
+	}
+}
+
+func TestImportMARCXMLFromAnotherSystem(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	// a record like one from a library catalog, with ISBD punctuation, a
+	// subtitle, a 260 instead of a 264 and a control number that isn't ours
+	xml := `<?xml version="1.0" encoding="UTF-8"?>
+<marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:leader>01142cam  2200301 a 4500</marc:leader>
+  <marc:controlfield tag="001">   92005291 </marc:controlfield>
+  <marc:datafield tag="020" ind1=" " ind2=" ">
+    <marc:subfield code="a">0441172717 (pbk.)</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="100" ind1="1" ind2=" ">
+    <marc:subfield code="a">Herbert, Frank,</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="245" ind1="1" ind2="0">
+    <marc:subfield code="a">Dune :</marc:subfield>
+    <marc:subfield code="b">a novel /</marc:subfield>
+    <marc:subfield code="c">Frank Herbert.</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="260" ind1=" " ind2=" ">
+    <marc:subfield code="a">Philadelphia :</marc:subfield>
+    <marc:subfield code="b">Chilton Books,</marc:subfield>
+    <marc:subfield code="c">c1965.</marc:subfield>
+  </marc:datafield>
+</marc:record>`
+
+	report, err := ImportMARCXML(strings.NewReader(xml), ImportOptions{DefaultRating: 2})
+	if err != nil {
+		t.Errorf("Error importing the record: %v", err)
+		t.FailNow()
+	}
+
+	if report.Created != 1 || report.Failed != 0 {
+		t.Errorf("Expected 1 book to be created, got %+v", report)
+		t.FailNow()
+	}
+
+	books, _ := GetLibrary().GetBooks()
+	book := books[0]
+	if book.Title != "Dune: a novel" || book.Author != "Herbert, Frank" || book.Publisher != "Chilton Books" ||
+		book.ISBN != "0441172717" || book.PublishDate == nil || book.PublishDate.Year() != 1965 || book.Rating != 2 {
+		t.Errorf("Expected the record to be read, got %+v", book)
+	}
+}
+
+func TestImportMARCErrors(t *testing.T) {
+	SetLibrary(NewLibrary())
+	defer SetLibrary(NewLibrary())
+
+	good := marcTestBook()
+	badISBN := marcTestBook()
+	badISBN.ISBN = "978-0-441-17271-0"
+
+	var buf bytes.Buffer
+	WriteMARC(&buf, []model.Book{good, badISBN})
+
+	// a record with a broken directory can be skipped since its length is
+	// still right
+	broken, _ := encodeMARC(bookMARC(good))
+	broken[len(marcLeader)+3] = 'x'
+	buf.Write(broken)
+
+	report, err := ImportMARC(&buf, ImportOptions{})
+	if err != nil {
+		t.Errorf("Error importing the records: %v", err)
+		t.FailNow()
+	}
+
+	if report.Rows != 3 || report.Created != 1 || report.Failed != 2 {
+		t.Errorf("Expected 1 book to be created and 2 to fail, got %+v", report)
+	}
+
+	if len(report.Errors) != 2 || report.Errors[0].Row != 2 || report.Errors[0].Error != model.ErrInvalidISBN.Error() || report.Errors[1].Row != 3 {
+		t.Errorf("Expected rows 2 and 3 to fail, got %+v", report.Errors)
+	}
+
+	// a record length that isn't a number means the rest of the file can't
+	// be read
+	_, err = ImportMARC(strings.NewReader("hello world"), ImportOptions{})
+	if err != ErrInvalidMARC {
+		t.Errorf("Expected %v, got %v", ErrInvalidMARC, err)
+	}
+}
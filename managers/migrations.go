@@ -0,0 +1,156 @@
+package managers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrSchemaOutOfDate is returned when opening a SQLStore whose database
+	// hasn't had all of the migrations applied to it
+	ErrSchemaOutOfDate = errors.New("The database schema is out of date, run the migrate command")
+)
+
+// migration is a single versioned change to the sql schema, once a migration
+// has been released it must never be changed, instead add a new one to the
+// end of the migrations slice
+type migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// migrations holds every change to the schema in the order they're applied
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "create the books table",
+		Statements: []string{
+			`CREATE TABLE books (
+				id           TEXT PRIMARY KEY,
+				title        TEXT NOT NULL DEFAULT '',
+				author       TEXT NOT NULL DEFAULT '',
+				publisher    TEXT NOT NULL DEFAULT '',
+				publish_date TEXT NULL,
+				rating       INTEGER NOT NULL DEFAULT 0,
+				status       INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "index the books by title",
+		Statements: []string{
+			`CREATE INDEX books_title ON books (title)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add a revision to the books",
+		Statements: []string{
+			`ALTER TABLE books ADD COLUMN revision INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add an isbn to the books",
+		Statements: []string{
+			`ALTER TABLE books ADD COLUMN isbn TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "create the records table",
+		Statements: []string{
+			`CREATE TABLE records (
+				kind  TEXT NOT NULL,
+				id    TEXT NOT NULL,
+				value TEXT NOT NULL,
+				PRIMARY KEY (kind, id)
+			)`,
+		},
+	},
+}
+
+// latestVersion is the version the schema will be at once every migration
+// has been applied
+func latestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database, or 0 if none have been
+func SchemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at  TEXT NOT NULL
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to create the schema_migrations table: %v", err)
+	}
+
+	var version sql.NullInt64
+	err = db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read the schema version: %v", err)
+	}
+
+	return int(version.Int64), nil
+}
+
+// Migrate applies every migration that hasn't been applied to the database
+// yet, each one in its own transaction, and returns the migrations that were
+// applied
+func Migrate(db *sql.DB) ([]string, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+
+		err = applyMigration(db, m)
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, fmt.Sprintf("%v: %v", m.Version, m.Description))
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs the statements for a single migration and records it
+// in the schema_migrations table
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Unable to start migration %v: %v", m.Version, err)
+	}
+
+	for _, statement := range m.Statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error applying migration %v: %v", m.Version, err)
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Description, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error recording migration %v: %v", m.Version, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+package managers
+
+import (
+	"sort"
+
+	"github.com/askewseth/kubernetes/models"
+)
+
+// BookQuery describes which books to pick out of the library, the order to
+// put them in, and which page of them to return. It's kept with the models so
+// that the client can send one without importing the managers
+type BookQuery = model.BookQuery
+
+var (
+	// ErrInvalidSortField is returned when a BookQuery is sorted by a field
+	// that books can't be sorted by
+	ErrInvalidSortField = model.ErrInvalidSortField
+)
+
+// QueryBooks filters, sorts and pages the given books, returning the page of
+// books along with the total number of books that matched the filters
+func QueryBooks(books []model.Book, q BookQuery) ([]model.Book, int, error) {
+	err := q.Validate()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matched := []model.Book{}
+	for _, book := range books {
+		if q.Matches(book) {
+			matched = append(matched, book)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.Less(matched[i], matched[j])
+	})
+
+	total := len(matched)
+
+	// cut out the requested page
+	start := q.Offset
+	if start > total {
+		start = total
+	}
+
+	// the limit is compared with what's left, since adding it to the start
+	// can overflow
+	end := total
+	if q.Limit > 0 && q.Limit < total-start {
+		end = start + q.Limit
+	}
+
+	return matched[start:end], total, nil
+}
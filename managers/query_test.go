@@ -0,0 +1,78 @@
+package managers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+// queryTestBooks returns a small set of books to run queries against
+func queryTestBooks() []model.Book {
+	date := func(year int) *time.Time {
+		d := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	return []model.Book{
+		{Title: "C", Author: "Tolkien", Publisher: "Allen", Rating: 3, PublishDate: date(1954)},
+		{Title: "A", Author: "tolkien", Publisher: "Allen", Rating: 1, PublishDate: date(1937), Status: model.CheckedOut},
+		{Title: "B", Author: "Lewis", Publisher: "Bles", Rating: 2, PublishDate: date(1950)},
+		{Title: "D", Author: "Lewis", Publisher: "Bles", Rating: 2},
+	}
+}
+
+// titles returns just the titles of the books
+func titles(books []model.Book) string {
+	s := ""
+	for _, book := range books {
+		s += book.Title
+	}
+	return s
+}
+
+func TestQueryBooks(t *testing.T) {
+	checkedOut := model.CheckedOut
+	after := time.Date(1940, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		query  BookQuery
+		titles string
+		total  int
+	}{
+		{"everything sorted by title", BookQuery{}, "ABCD", 4},
+		{"author ignores case", BookQuery{Author: "TOLKIEN"}, "AC", 2},
+		{"publisher", BookQuery{Publisher: "Bles"}, "BD", 2},
+		{"status", BookQuery{Status: &checkedOut}, "A", 1},
+		{"rating range", BookQuery{MinRating: 2, MaxRating: 2}, "BD", 2},
+		{"published after", BookQuery{PublishedAfter: &after}, "BC", 2},
+		{"sort descending", BookQuery{Descending: true}, "DCBA", 4},
+		{"sort by rating", BookQuery{SortBy: "rating"}, "ABDC", 4},
+		{"undated books sort last", BookQuery{SortBy: "publish_date"}, "ABCD", 4},
+		{"first page", BookQuery{Limit: 3}, "ABC", 4},
+		{"last page", BookQuery{Limit: 3, Offset: 3}, "D", 4},
+		{"past the end", BookQuery{Limit: 3, Offset: 10}, "", 4},
+		{"limit too big to add to the offset", BookQuery{Limit: math.MaxInt, Offset: 1}, "BCD", 4},
+	}
+
+	for _, test := range tests {
+		books, total, err := QueryBooks(queryTestBooks(), test.query)
+		if err != nil {
+			t.Errorf("%v: got error %v", test.name, err)
+			continue
+		}
+
+		if titles(books) != test.titles || total != test.total {
+			t.Errorf("%v: expected %v of %v books, got %v of %v", test.name, test.titles, test.total, titles(books), total)
+		}
+	}
+}
+
+func TestQueryBooksBadSort(t *testing.T) {
+	_, _, err := QueryBooks(queryTestBooks(), BookQuery{SortBy: "id"})
+	if err != ErrInvalidSortField {
+		t.Errorf("Expected %v sorting by an unknown field, got %v", ErrInvalidSortField, err)
+	}
+}
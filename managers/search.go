@@ -0,0 +1,293 @@
+package managers
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+// the global search index, kept up to date with the global book store
+var searchIndex = NewSearchIndex()
+
+func init() {
+	OnChange(searchIndex.apply)
+}
+
+// GetSearchIndex returns the search index over the books in the global
+// book store
+func GetSearchIndex() *SearchIndex {
+	return searchIndex
+}
+
+// searchField is one of the book fields that's searched, the weight is how
+// much a match in the field counts towards a book's score
+type searchField struct {
+	Name   string
+	Weight float64
+	Value  func(book model.Book) string
+}
+
+// searchFields are the fields that are indexed, matches in the title count
+// for more than matches in the author, which count for more than the publisher
+var searchFields = []searchField{
+	{"title", 3, func(book model.Book) string { return book.Title }},
+	{"author", 2, func(book model.Book) string { return book.Author }},
+	{"publisher", 1, func(book model.Book) string { return book.Publisher }},
+}
+
+// SearchHit is a single book that matched a search
+type SearchHit = model.SearchHit
+
+// SearchIndex is an inverted index from search terms to the books with those
+// terms in their title, author or publisher
+type SearchIndex struct {
+	sync.RWMutex
+
+	books map[uuid.UUID]model.Book
+
+	// postings maps each term to the books it's in, along with the
+	// weighted number of times it's in each book
+	postings map[string]map[uuid.UUID]float64
+}
+
+// NewSearchIndex returns a new empty search index
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		books:    make(map[uuid.UUID]model.Book),
+		postings: make(map[string]map[uuid.UUID]float64),
+	}
+}
+
+// apply updates the index with a change made to the book store
+func (s *SearchIndex) apply(change Change) {
+	if change.Type == BookDeleted {
+		s.Remove(change.Book.ID)
+		return
+	}
+
+	s.Index(change.Book)
+}
+
+// Reset throws away everything in the index and indexes the given books
+func (s *SearchIndex) Reset(books []model.Book) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.books = make(map[uuid.UUID]model.Book)
+	s.postings = make(map[string]map[uuid.UUID]float64)
+
+	for _, book := range books {
+		s.index(book)
+	}
+}
+
+// Index adds a book to the index, replacing it if it's already indexed
+func (s *SearchIndex) Index(book model.Book) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.remove(book.ID)
+	s.index(book)
+}
+
+// Remove takes a book out of the index
+func (s *SearchIndex) Remove(id uuid.UUID) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.remove(id)
+}
+
+// index adds the terms in a book to the postings, the book must not already
+// be indexed
+func (s *SearchIndex) index(book model.Book) {
+	s.books[book.ID] = book
+
+	for _, field := range searchFields {
+		for _, token := range tokenize(field.Value(book)) {
+			books, found := s.postings[token.Term]
+			if !found {
+				books = make(map[uuid.UUID]float64)
+				s.postings[token.Term] = books
+			}
+			books[book.ID] += field.Weight
+		}
+	}
+}
+
+// remove takes the terms in a book out of the postings
+func (s *SearchIndex) remove(id uuid.UUID) {
+	book, found := s.books[id]
+	if !found {
+		return
+	}
+	delete(s.books, id)
+
+	for _, field := range searchFields {
+		for _, token := range tokenize(field.Value(book)) {
+			delete(s.postings[token.Term], id)
+			if len(s.postings[token.Term]) == 0 {
+				delete(s.postings, token.Term)
+			}
+		}
+	}
+}
+
+// Search returns every book matching any of the terms in the query, ranked
+// so the books that match the most, and the rarest, terms come first
+func (s *SearchIndex) Search(query string) []SearchHit {
+	s.RLock()
+	defer s.RUnlock()
+
+	terms := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		terms[token.Term] = true
+	}
+
+	// score each book with tf-idf, summed across the query terms
+	scores := make(map[uuid.UUID]float64)
+	for term := range terms {
+		books := s.postings[term]
+		if len(books) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + float64(len(s.books))/float64(len(books)))
+		for id, frequency := range books {
+			scores[id] += frequency * idf
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for id, score := range scores {
+		book := s.books[id]
+		hits = append(hits, SearchHit{
+			Book:       book,
+			Score:      score,
+			Highlights: highlight(book, terms),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Book.Title < hits[j].Book.Title
+	})
+
+	return hits
+}
+
+// highlight returns each field of the book that has one of the terms in it,
+// html escaped, with the matching words wrapped in <em> tags
+func highlight(book model.Book, terms map[string]bool) map[string]string {
+	highlights := make(map[string]string)
+
+	for _, field := range searchFields {
+		value := field.Value(book)
+
+		var b strings.Builder
+		last := 0
+		for _, token := range tokenize(value) {
+			if !terms[token.Term] {
+				continue
+			}
+
+			b.WriteString(html.EscapeString(value[last:token.Start]))
+			b.WriteString("<em>")
+			b.WriteString(html.EscapeString(value[token.Start:token.End]))
+			b.WriteString("</em>")
+			last = token.End
+		}
+
+		if last > 0 {
+			b.WriteString(html.EscapeString(value[last:]))
+			highlights[field.Name] = b.String()
+		}
+	}
+
+	return highlights
+}
+
+// token is a single word from a piece of text, Term is the normalized form
+// of the word that's indexed, and Start and End are the byte offsets of the
+// word in the original text
+type token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// tokenize splits text into words on anything that isn't a letter or a
+// number, and normalizes each word into a search term
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+
+	return tokens
+}
+
+// appendToken normalizes the word between start and end and appends it to
+// the tokens, unless there's nothing left of it once it's normalized
+func appendToken(tokens []token, text string, start, end int) []token {
+	term := stem(fold(text[start:end]))
+	if term == "" {
+		return tokens
+	}
+
+	return append(tokens, token{Term: term, Start: start, End: end})
+}
+
+// fold lower cases a word and strips accents from it by decomposing it and
+// dropping the combining marks, so that "Café" and "cafe" are the same term
+func fold(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// stem strips the common english suffixes off of a word so that different
+// forms of the same word, like "dragons" and "dragon", are the same term
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	case len(word) > 5 && strings.HasSuffix(word, "ing"):
+		return word[:len(word)-3]
+	case len(word) > 4 && strings.HasSuffix(word, "ed"):
+		return word[:len(word)-2]
+	}
+
+	return word
+}
@@ -0,0 +1,116 @@
+package managers
+
+import (
+	"testing"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("The Café's DRAGONS, flying!")
+
+	expected := []token{
+		{"the", 0, 3},
+		{"cafe", 4, 9},
+		{"s", 10, 11},
+		{"dragon", 12, 19},
+		{"fly", 21, 27},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Errorf("Expected tokens %+v, got %+v", expected, tokens)
+		t.FailNow()
+	}
+
+	for i := range tokens {
+		if tokens[i] != expected[i] {
+			t.Errorf("Expected token %+v, got %+v", expected[i], tokens[i])
+		}
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	index := NewSearchIndex()
+
+	hobbit := model.NewBook()
+	hobbit.Title = "The Hobbit"
+	hobbit.Author = "J.R.R. Tolkien"
+	index.Index(hobbit)
+
+	silmarillion := model.NewBook()
+	silmarillion.Title = "The Silmarillion"
+	silmarillion.Author = "Christopher Tolkien"
+	silmarillion.Publisher = "Hobbit Press"
+	index.Index(silmarillion)
+
+	hits := index.Search("hobbits")
+	if len(hits) != 2 {
+		t.Errorf("Expected 2 hits for hobbits, got %+v", hits)
+		t.FailNow()
+	}
+
+	// a match in the title should beat a match in the publisher
+	if hits[0].Book.ID != hobbit.ID {
+		t.Errorf("Expected the title match to be ranked first, got %+v", hits)
+	}
+
+	if hits[0].Highlights["title"] != "The <em>Hobbit</em>" {
+		t.Errorf("Expected the title to be highlighted, got %+v", hits[0].Highlights)
+	}
+
+	if _, found := hits[0].Highlights["author"]; found {
+		t.Errorf("Expected only matching fields to be highlighted, got %+v", hits[0].Highlights)
+	}
+
+	// changing the book should take the old terms out of the index
+	hobbit.Title = "There and Back Again"
+	index.Index(hobbit)
+
+	hits = index.Search("hobbit")
+	if len(hits) != 1 || hits[0].Book.ID != silmarillion.ID {
+		t.Errorf("Expected only the publisher match after retitling, got %+v", hits)
+	}
+
+	index.Remove(silmarillion.ID)
+	if hits = index.Search("hobbit"); len(hits) != 0 {
+		t.Errorf("Expected no hits after removing the book, got %+v", hits)
+	}
+}
+
+func TestSearchIndexFollowsLibrary(t *testing.T) {
+	defer SetLibrary(NewLibrary())
+
+	existing := model.NewBook()
+	existing.Title = "Existing Book"
+	library := NewLibrary()
+	library.AddBook(existing)
+
+	// the books already in the store should be indexed
+	SetLibrary(library)
+	if hits := GetSearchIndex().Search("existing"); len(hits) != 1 {
+		t.Errorf("Expected the existing book to be indexed, got %+v", hits)
+	}
+
+	book := model.NewBook()
+	book.Title = "Added Book"
+	GetLibrary().AddBook(book)
+	if hits := GetSearchIndex().Search("added"); len(hits) != 1 {
+		t.Errorf("Expected the added book to be indexed, got %+v", hits)
+	}
+
+	UpdateBook(book.ID, func(modBook *model.Book) error {
+		modBook.Title = "Modified Book"
+		return nil
+	})
+	if hits := GetSearchIndex().Search("added"); len(hits) != 0 {
+		t.Errorf("Expected the old title to be out of the index, got %+v", hits)
+	}
+	if hits := GetSearchIndex().Search("modified"); len(hits) != 1 {
+		t.Errorf("Expected the modified book to be indexed, got %+v", hits)
+	}
+
+	GetLibrary().DeleteBook(book.ID)
+	if hits := GetSearchIndex().Search("book"); len(hits) != 1 {
+		t.Errorf("Expected the deleted book to be out of the index, got %+v", hits)
+	}
+}
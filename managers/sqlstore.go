@@ -0,0 +1,294 @@
+package managers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// the columns of the books table in the order they're scanned by scanBook
+const bookColumns = `id, title, author, publisher, publish_date, rating, status, revision, isbn`
+
+// insertBook inserts, or replaces, a book with the values from bookValues
+const insertBook = `INSERT OR REPLACE INTO books (` + bookColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SQLStore is a BookStore that keeps the books in a relational database, the
+// schema is created and updated by Migrate
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore opens the database with the given driver and data source name,
+// the sql driver has to already be registered by the caller. The schema has
+// to be up to date or ErrSchemaOutOfDate is returned
+func OpenSQLStore(driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open the database: %v", err)
+	}
+
+	store, err := NewSQLStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// NewSQLStore returns a SQLStore using an already open database, the schema
+// has to be up to date or ErrSchemaOutOfDate is returned
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != latestVersion() {
+		return nil, ErrSchemaOutOfDate
+	}
+
+	return &SQLStore{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping returns an error if the database can't be reached
+func (s *SQLStore) Ping() error {
+	err := s.db.Ping()
+	if err != nil {
+		return fmt.Errorf("The database is unavailable: %v", err)
+	}
+
+	return nil
+}
+
+// scanner is the part of sql.Row and sql.Rows that scanBook needs
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads a book out of a row selected with bookColumns
+func scanBook(row scanner) (model.Book, error) {
+	var (
+		book        model.Book
+		id          string
+		publishDate sql.NullString
+		rating      int
+		status      int
+	)
+
+	err := row.Scan(&id, &book.Title, &book.Author, &book.Publisher, &publishDate, &rating, &status, &book.Revision, &book.ISBN)
+	if err != nil {
+		return book, err
+	}
+
+	book.ID, err = uuid.FromString(id)
+	if err != nil {
+		return book, fmt.Errorf("The book id %q in the database isn't a valid UUID: %v", id, err)
+	}
+
+	if publishDate.Valid {
+		date, err := time.Parse(time.RFC3339Nano, publishDate.String)
+		if err != nil {
+			return book, fmt.Errorf("The publish date %q in the database isn't valid: %v", publishDate.String, err)
+		}
+		book.PublishDate = &date
+	}
+
+	book.Rating = uint8(rating)
+	book.Status = model.Status(status)
+
+	return book, nil
+}
+
+// bookValues returns the values of a book in the order of bookColumns
+func bookValues(book model.Book) []interface{} {
+	return []interface{}{
+		book.ID.String(), book.Title, book.Author, book.Publisher,
+		publishDateValue(book), int(book.Rating), int(book.Status), book.Revision, book.ISBN,
+	}
+}
+
+// publishDateValue returns the value stored in the nullable publish_date column
+func publishDateValue(book model.Book) sql.NullString {
+	if book.PublishDate == nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: book.PublishDate.UTC().Format(time.RFC3339Nano), Valid: true}
+}
+
+// GetBooks returns all of the books in the database sorted by title
+func (s *SQLStore) GetBooks() ([]model.Book, error) {
+	rows, err := s.db.Query(`SELECT ` + bookColumns + ` FROM books ORDER BY title`)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to query the books: %v", err)
+	}
+	defer rows.Close()
+
+	books := []model.Book{}
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read a book: %v", err)
+		}
+		books = append(books, book)
+	}
+
+	return books, rows.Err()
+}
+
+// GetBookByID returns the book with the given id
+func (s *SQLStore) GetBookByID(id uuid.UUID) (model.Book, error) {
+	return getBookByID(s.db, id)
+}
+
+// getBookByID selects a single book using either the database or a transaction
+func getBookByID(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, id uuid.UUID) (model.Book, error) {
+	row := q.QueryRow(`SELECT `+bookColumns+` FROM books WHERE id = ?`, id.String())
+
+	book, err := scanBook(row)
+	if err == sql.ErrNoRows {
+		return book, ErrNoBookWithThatID
+	}
+	if err != nil {
+		return book, fmt.Errorf("Unable to read the book: %v", err)
+	}
+
+	return book, nil
+}
+
+// AddBook inserts the book, overwriting any book with the same id
+func (s *SQLStore) AddBook(book model.Book) error {
+	_, err := s.db.Exec(insertBook, bookValues(book)...)
+	if err != nil {
+		return fmt.Errorf("Unable to insert the book: %v", err)
+	}
+
+	return nil
+}
+
+// ModifyBook replaces the book with the same id
+func (s *SQLStore) ModifyBook(book model.Book) error {
+	result, err := s.db.Exec(
+		`UPDATE books SET title = ?, author = ?, publisher = ?, publish_date = ?, rating = ?, status = ?, revision = ?, isbn = ? WHERE id = ?`,
+		book.Title, book.Author, book.Publisher, publishDateValue(book),
+		int(book.Rating), int(book.Status), book.Revision, book.ISBN, book.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("Unable to update the book: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Unable to update the book: %v", err)
+	}
+
+	if updated == 0 {
+		return ErrNoBookWithThatID
+	}
+
+	return nil
+}
+
+// DeleteBook removes the book with the given id
+func (s *SQLStore) DeleteBook(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM books WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("Unable to delete the book: %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Unable to delete the book: %v", err)
+	}
+
+	if deleted == 0 {
+		return ErrNoBookWithThatID
+	}
+
+	return nil
+}
+
+// ApplyChanges makes all of the changes in a single transaction
+func (s *SQLStore) ApplyChanges(changes []Change) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Unable to start a transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		book := change.Book
+		if change.Type == BookDeleted {
+			_, err = tx.Exec(`DELETE FROM books WHERE id = ?`, book.ID.String())
+		} else {
+			_, err = tx.Exec(insertBook, bookValues(book)...)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to apply the changes: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveRecords stores or deletes every record in a single transaction
+func (s *SQLStore) SaveRecords(records []Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Unable to start a transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		if record.Value == nil {
+			_, err = tx.Exec(`DELETE FROM records WHERE kind = ? AND id = ?`, record.Kind, record.ID.String())
+		} else {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO records (kind, id, value) VALUES (?, ?, ?)`, record.Kind, record.ID.String(), string(record.Value))
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to save the records: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRecords returns every record of the kind
+func (s *SQLStore) GetRecords(kind string) ([]Record, error) {
+	rows, err := s.db.Query(`SELECT id, value FROM records WHERE kind = ?`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to query the records: %v", err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var id, value string
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read a record: %v", err)
+		}
+
+		record := Record{Kind: kind, Value: json.RawMessage(value)}
+		record.ID, err = uuid.FromString(id)
+		if err != nil {
+			return nil, fmt.Errorf("The record id %q in the database isn't a valid UUID: %v", id, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
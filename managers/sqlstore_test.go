@@ -0,0 +1,102 @@
+package managers
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB opens a new sqlite database in a temp directory, the database is
+// closed when the test finishes
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "books.db"))
+	if err != nil {
+		t.Errorf("Error opening the database: %v", err)
+		t.FailNow()
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// openTestSQLStore opens a new, fully migrated SQLStore
+func openTestSQLStore(t *testing.T) *SQLStore {
+	db := openTestDB(t)
+
+	_, err := Migrate(db)
+	if err != nil {
+		t.Errorf("Error migrating the database: %v", err)
+		t.FailNow()
+	}
+
+	store, err := NewSQLStore(db)
+	if err != nil {
+		t.Errorf("Error creating the sql store: %v", err)
+		t.FailNow()
+	}
+
+	return store
+}
+
+func TestSQLStore(t *testing.T) {
+	testBookStore(t, func(t *testing.T) BookStore {
+		return openTestSQLStore(t)
+	})
+}
+
+func TestSQLStorePublishDate(t *testing.T) {
+	store := openTestSQLStore(t)
+
+	date := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	book := model.NewBook()
+	book.PublishDate = &date
+	store.AddBook(book)
+
+	noDate := model.NewBook()
+	store.AddBook(noDate)
+
+	found, err := store.GetBookByID(book.ID)
+	if err != nil || found.PublishDate == nil || !found.PublishDate.Equal(date) {
+		t.Errorf("Expected the publish date %v to be stored, got %v (%v)", date, found.PublishDate, err)
+	}
+
+	found, err = store.GetBookByID(noDate.ID)
+	if err != nil || found.PublishDate != nil {
+		t.Errorf("Expected a null publish date to stay null, got %v (%v)", found.PublishDate, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := openTestDB(t)
+
+	// the store shouldn't open against an unmigrated database
+	_, err := NewSQLStore(db)
+	if err != ErrSchemaOutOfDate {
+		t.Errorf("Expected %v opening an unmigrated database, got %v", ErrSchemaOutOfDate, err)
+	}
+
+	applied, err := Migrate(db)
+	if err != nil {
+		t.Errorf("Error migrating the database: %v", err)
+		t.FailNow()
+	}
+
+	if len(applied) != len(migrations) {
+		t.Errorf("Expected %v migrations to be applied, got %v", len(migrations), applied)
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil || version != latestVersion() {
+		t.Errorf("Expected the schema to be at version %v, got %v (%v)", latestVersion(), version, err)
+	}
+
+	// running the migrations again shouldn't do anything
+	applied, err = Migrate(db)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Expected migrating twice to apply nothing, got %v (%v)", applied, err)
+	}
+}
@@ -0,0 +1,159 @@
+package managers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BookCheckouts is the number of times a book has been checked out
+type BookCheckouts = model.BookCheckouts
+
+// AuthorCheckouts is the number of times books by an author have been
+// checked out
+type AuthorCheckouts = model.AuthorCheckouts
+
+// MonthCheckouts is the number of checkouts in a month
+type MonthCheckouts = model.MonthCheckouts
+
+// CirculationStats counts checkouts by book, by author and by month
+type CirculationStats = model.CirculationStats
+
+// BookLoans returns every loan of a book, current and past, in the order they
+// were checked out
+func (c *Circulation) BookLoans(bookID uuid.UUID) []model.Loan {
+	return c.loanHistory(func(loan model.Loan) bool {
+		return loan.BookID == bookID
+	})
+}
+
+// PatronLoans returns every loan to a patron, current and past, in the order
+// they were checked out
+func (c *Circulation) PatronLoans(patronID uuid.UUID) ([]model.Loan, error) {
+	c.Lock()
+	_, found := c.patrons[patronID]
+	c.Unlock()
+
+	if !found {
+		return nil, ErrNoPatronWithThatID
+	}
+
+	return c.loanHistory(func(loan model.Loan) bool {
+		return loan.PatronID == patronID
+	}), nil
+}
+
+// loanHistory returns copies of the loans that match, in the order they
+// were checked out
+func (c *Circulation) loanHistory(matches func(loan model.Loan) bool) []model.Loan {
+	c.Lock()
+	defer c.Unlock()
+
+	loans := []model.Loan{}
+	for _, loan := range c.loans {
+		if matches(loan) {
+			loans = append(loans, loan)
+		}
+	}
+
+	sort.Slice(loans, func(i, j int) bool {
+		return loans[i].CheckedOutAt.Before(loans[j].CheckedOutAt)
+	})
+
+	return loans
+}
+
+// CirculationStats counts the checkouts made between since and until, either
+// of which can be nil to leave that end of the range open
+func (c *Circulation) CirculationStats(since, until *time.Time) (CirculationStats, error) {
+	loans := c.loanHistory(func(loan model.Loan) bool {
+		if since != nil && loan.CheckedOutAt.Before(*since) {
+			return false
+		}
+		return until == nil || !loan.CheckedOutAt.After(*until)
+	})
+
+	books, err := GetLibrary().GetBooks()
+	if err != nil {
+		return CirculationStats{}, err
+	}
+
+	byID := make(map[uuid.UUID]model.Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+
+	bookCounts := make(map[uuid.UUID]int)
+	authorCounts := make(map[string]int)
+	monthCounts := make(map[string]int)
+
+	// each book is listed with the title and author it had when it was last
+	// checked out, the loans are in order so the last one wins
+	titles := make(map[uuid.UUID]model.Book)
+
+	for _, loan := range loans {
+		monthCounts[loan.CheckedOutAt.Format("2006-01")]++
+
+		// loans from before the title and author were kept on them get
+		// them from the book, if it's still in the library
+		book := model.Book{ID: loan.BookID, Title: loan.Title, Author: loan.Author}
+		if loan.Title == "" {
+			live, found := byID[loan.BookID]
+			if !found {
+				continue
+			}
+			book = live
+		}
+
+		bookCounts[book.ID]++
+		titles[book.ID] = book
+		if book.Author != "" {
+			authorCounts[book.Author]++
+		}
+	}
+
+	stats := CirculationStats{
+		ByBook:   []BookCheckouts{},
+		ByAuthor: []AuthorCheckouts{},
+		ByMonth:  []MonthCheckouts{},
+	}
+
+	for id, checkouts := range bookCounts {
+		book := titles[id]
+		stats.ByBook = append(stats.ByBook, BookCheckouts{
+			BookID:    id,
+			Title:     book.Title,
+			Author:    book.Author,
+			Checkouts: checkouts,
+		})
+	}
+	sort.Slice(stats.ByBook, func(i, j int) bool {
+		a, b := stats.ByBook[i], stats.ByBook[j]
+		if a.Checkouts != b.Checkouts {
+			return a.Checkouts > b.Checkouts
+		}
+		return a.Title < b.Title
+	})
+
+	for author, checkouts := range authorCounts {
+		stats.ByAuthor = append(stats.ByAuthor, AuthorCheckouts{Author: author, Checkouts: checkouts})
+	}
+	sort.Slice(stats.ByAuthor, func(i, j int) bool {
+		a, b := stats.ByAuthor[i], stats.ByAuthor[j]
+		if a.Checkouts != b.Checkouts {
+			return a.Checkouts > b.Checkouts
+		}
+		return a.Author < b.Author
+	})
+
+	for month, checkouts := range monthCounts {
+		stats.ByMonth = append(stats.ByMonth, MonthCheckouts{Month: month, Checkouts: checkouts})
+	}
+	sort.Slice(stats.ByMonth, func(i, j int) bool {
+		return stats.ByMonth[i].Month < stats.ByMonth[j].Month
+	})
+
+	return stats, nil
+}
@@ -0,0 +1,133 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+)
+
+func TestLoanHistory(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+	other := addTestPatron(circulation, "Other")
+
+	for _, p := range []model.Patron{patron, other, patron} {
+		if _, err := circulation.Checkout(book.ID, p.ID); err != nil {
+			t.Errorf("Error checking out book: %v", err)
+			t.FailNow()
+		}
+		testClock(circulation).Advance(24 * time.Hour)
+		if _, err := circulation.Return(book.ID); err != nil {
+			t.Errorf("Error returning book: %v", err)
+			t.FailNow()
+		}
+	}
+
+	loans := circulation.BookLoans(book.ID)
+	if len(loans) != 3 {
+		t.Errorf("Expected 3 loans of the book, got %v", len(loans))
+		t.FailNow()
+	}
+
+	if loans[0].PatronID != patron.ID || loans[1].PatronID != other.ID || loans[2].PatronID != patron.ID {
+		t.Errorf("The book's loans aren't in the order they were checked out: %+v", loans)
+	}
+
+	for _, loan := range loans {
+		if loan.Active() {
+			t.Errorf("Expected every loan in the history to be returned, got %+v", loan)
+		}
+	}
+
+	patronLoans, err := circulation.PatronLoans(patron.ID)
+	if err != nil {
+		t.Errorf("Error getting the patron's loans: %v", err)
+		t.FailNow()
+	}
+
+	if len(patronLoans) != 2 || !patronLoans[0].CheckedOutAt.Before(patronLoans[1].CheckedOutAt) {
+		t.Errorf("Expected the patron's 2 loans oldest first, got %+v", patronLoans)
+	}
+
+	// the history is kept after the book is deleted
+	GetLibrary().DeleteBook(book.ID)
+	if len(circulation.BookLoans(book.ID)) != 3 {
+		t.Errorf("Deleting the book lost its loan history")
+	}
+
+	if _, err = circulation.PatronLoans(book.ID); err != ErrNoPatronWithThatID {
+		t.Errorf("Expected %v getting the loans of an unknown patron, got %v", ErrNoPatronWithThatID, err)
+	}
+}
+
+func TestCirculationStats(t *testing.T) {
+	circulation, patron, book := newTestCirculation(t)
+
+	other := model.NewBook()
+	other.Title = "OtherBook"
+	other.Author = "Someone"
+	GetLibrary().AddBook(other)
+
+	checkout := func(b model.Book) {
+		if _, err := circulation.Checkout(b.ID, patron.ID); err != nil {
+			t.Errorf("Error checking out book: %v", err)
+			t.FailNow()
+		}
+		circulation.Return(b.ID)
+	}
+
+	// one checkout of each book in January, then another of the other
+	// book in February
+	checkout(book)
+	checkout(other)
+	testClock(circulation).Advance(31 * 24 * time.Hour)
+	checkout(other)
+
+	stats, err := circulation.CirculationStats(nil, nil)
+	if err != nil {
+		t.Errorf("Error getting circulation stats: %v", err)
+		t.FailNow()
+	}
+
+	if len(stats.ByBook) != 2 || stats.ByBook[0].BookID != other.ID || stats.ByBook[0].Checkouts != 2 || stats.ByBook[1].Checkouts != 1 {
+		t.Errorf("Wrong checkouts by book, got %+v", stats.ByBook)
+	}
+
+	if len(stats.ByAuthor) != 1 || stats.ByAuthor[0].Author != "Someone" || stats.ByAuthor[0].Checkouts != 2 {
+		t.Errorf("Wrong checkouts by author, got %+v", stats.ByAuthor)
+	}
+
+	expected := []MonthCheckouts{{Month: "2018-01", Checkouts: 2}, {Month: "2018-02", Checkouts: 1}}
+	if len(stats.ByMonth) != len(expected) || stats.ByMonth[0] != expected[0] || stats.ByMonth[1] != expected[1] {
+		t.Errorf("Expected checkouts by month %+v, got %+v", expected, stats.ByMonth)
+	}
+
+	since := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	stats, err = circulation.CirculationStats(&since, nil)
+	if err != nil {
+		t.Errorf("Error getting circulation stats: %v", err)
+		t.FailNow()
+	}
+
+	if len(stats.ByMonth) != 1 || stats.ByMonth[0].Month != "2018-02" || len(stats.ByBook) != 1 {
+		t.Errorf("Expected only February's checkout since %v, got %+v", since, stats)
+	}
+
+	// deleted books are still counted, by the title and author they were
+	// checked out with
+	GetLibrary().DeleteBook(other.ID)
+
+	stats, err = circulation.CirculationStats(nil, nil)
+	if err != nil {
+		t.Errorf("Error getting circulation stats: %v", err)
+		t.FailNow()
+	}
+
+	if len(stats.ByBook) != 2 || stats.ByBook[0].BookID != other.ID || stats.ByBook[0].Title != "OtherBook" || stats.ByBook[0].Checkouts != 2 {
+		t.Errorf("Expected the deleted book to still be counted, got %+v", stats.ByBook)
+	}
+
+	if len(stats.ByAuthor) != 1 || stats.ByAuthor[0].Author != "Someone" || stats.ByAuthor[0].Checkouts != 2 {
+		t.Errorf("Expected the deleted book's author to still be counted, got %+v", stats.ByAuthor)
+	}
+}
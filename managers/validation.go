@@ -0,0 +1,63 @@
+package managers
+
+import (
+	"sync"
+
+	"github.com/askewseth/kubernetes/models"
+)
+
+var (
+	// validationFailures counts the books that failed validation, by the
+	// field that was invalid. Every field starts at 0 so that its series is
+	// there before the first failure
+	validationFailures = map[string]uint64{
+		"rating": 0,
+		"status": 0,
+		"isbn":   0,
+		"text":   0,
+		"other":  0,
+	}
+	validationFailuresMu sync.Mutex
+)
+
+// validationFields holds the field each of Book.Validate's errors is for
+var validationFields = map[error]string{
+	model.ErrInvalidRating: "rating",
+	model.ErrInvalidStatus: "status",
+	model.ErrInvalidISBN:   "isbn",
+	model.ErrInvalidText:   "text",
+}
+
+// ValidateBook returns the error from the book's Validate, and counts the
+// failure so that it shows up in ValidationFailures. Everything that creates
+// or changes a book validates it through here, apart from dry runs which
+// call the book's Validate directly
+func ValidateBook(book model.Book) error {
+	err := book.Validate()
+	if err != nil {
+		field, found := validationFields[err]
+		if !found {
+			field = "other"
+		}
+
+		validationFailuresMu.Lock()
+		validationFailures[field]++
+		validationFailuresMu.Unlock()
+	}
+
+	return err
+}
+
+// ValidationFailures returns how many books have failed ValidateBook since
+// the process started, by the field that was invalid
+func ValidationFailures() map[string]uint64 {
+	validationFailuresMu.Lock()
+	defer validationFailuresMu.Unlock()
+
+	failures := make(map[string]uint64, len(validationFailures))
+	for field, count := range validationFailures {
+		failures[field] = count
+	}
+
+	return failures
+}
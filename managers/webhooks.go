@@ -0,0 +1,697 @@
+package managers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// the headers sent with every webhook delivery
+const (
+	// WebhookSignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a ".", and the body, keyed with the webhook's secret
+	WebhookSignatureHeader = "X-Books-Signature"
+
+	// WebhookTimestampHeader holds the unix time the delivery was sent at,
+	// receivers should reject old ones so deliveries can't be replayed
+	WebhookTimestampHeader = "X-Books-Timestamp"
+
+	// WebhookEventHeader holds the type of the event
+	WebhookEventHeader = "X-Books-Event"
+
+	// WebhookDeliveryHeader holds the id of the delivery, which is the same
+	// for every attempt so receivers can ignore duplicates
+	WebhookDeliveryHeader = "X-Books-Delivery"
+)
+
+// this const block holds the defaults of a WebhookPolicy
+const (
+	DefaultWebhookMaxAttempts = 6
+	DefaultWebhookBackoff     = 10 * time.Second
+	DefaultWebhookMaxBackoff  = 10 * time.Minute
+	DefaultWebhookTimeout     = 10 * time.Second
+)
+
+// maxDeliveryLog is how many deliveries are kept in the delivery log, the
+// oldest ones that have finished are forgotten first
+const maxDeliveryLog = 10000
+
+var (
+	// ErrNoWebhookWithThatID is returned when there isn't a webhook with the
+	// given id
+	ErrNoWebhookWithThatID = model.ErrNoWebhookWithThatID
+
+	// ErrNoDeliveryWithThatID is returned when there isn't a delivery with
+	// the given id
+	ErrNoDeliveryWithThatID = model.ErrNoDeliveryWithThatID
+
+	// ErrDeliveryNotDead is returned when retrying a delivery that isn't in
+	// the dead letters
+	ErrDeliveryNotDead = model.ErrDeliveryNotDead
+
+	// ErrInvalidWebhookURL is returned when a webhook's url isn't an absolute
+	// http or https url
+	ErrInvalidWebhookURL = model.ErrInvalidWebhookURL
+
+	// ErrInvalidWebhookEvent is returned when a webhook subscribes to an
+	// event type that doesn't exist
+	ErrInvalidWebhookEvent = model.ErrInvalidWebhookEvent
+)
+
+// EventTypes holds every EventType
+var EventTypes = model.EventTypes
+
+// Webhook is a url that the events are POSTed to
+type Webhook = model.Webhook
+
+// DeliveryStatus is where a delivery is up to
+type DeliveryStatus = model.DeliveryStatus
+
+// this const block holds the DeliveryStatus values
+const (
+	DeliveryPending   = model.DeliveryPending
+	DeliverySucceeded = model.DeliverySucceeded
+	DeliveryDead      = model.DeliveryDead
+)
+
+// DeliveryAttempt is a single try at sending a delivery
+type DeliveryAttempt = model.DeliveryAttempt
+
+// Delivery is an event being sent to a webhook
+type Delivery = model.Delivery
+
+// WebhookPolicy holds the rules for sending deliveries
+type WebhookPolicy struct {
+	// MaxAttempts is how many times a delivery is tried before it's dead
+	MaxAttempts int
+
+	// Backoff is how long after the first failed attempt the delivery is
+	// tried again, the wait doubles after each failed attempt up to
+	// MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout is how long the webhook gets to respond to each attempt
+	Timeout time.Duration
+}
+
+// DefaultWebhookPolicy returns the WebhookPolicy that new Webhooks start with
+func DefaultWebhookPolicy() WebhookPolicy {
+	return WebhookPolicy{
+		MaxAttempts: DefaultWebhookMaxAttempts,
+		Backoff:     DefaultWebhookBackoff,
+		MaxBackoff:  DefaultWebhookMaxBackoff,
+		Timeout:     DefaultWebhookTimeout,
+	}
+}
+
+// backoff returns how long to wait after the given number of failed attempts
+func (p WebhookPolicy) backoff(attempts int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// SignWebhook returns the value of the WebhookSignatureHeader for a body sent
+// at the timestamp, which is how receivers check that a delivery came from
+// the api and wasn't changed
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// blockedNetworks holds the reserved networks that webhooks can't be sent
+// to on top of the loopback, link-local, private, unspecified and multicast
+// addresses, carrier grade NAT is in here because some clusters use it for
+// their pod and service networks
+var blockedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+)
+
+// parseNetworks parses the CIDRs, which have to be valid
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+
+	return networks
+}
+
+// publicIP returns false for the addresses that aren't on the public
+// internet, like the loopback, the 169.254.169.254 metadata service, and
+// the pods and services inside the cluster
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dialPublic is the dialer's Control, it's called with the address that's
+// actually being connected to, after the host has been resolved, so a name
+// that resolves to an internal address is refused as well
+func dialPublic(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("Webhooks can't be sent to %v, it isn't a public address", host)
+	}
+
+	return nil
+}
+
+// NewWebhookClient returns the http.Client that NewWebhooks sends the
+// deliveries with. The urls come from whoever registered the webhook, so it
+// only connects to public addresses, otherwise a webhook could be used to
+// POST to the metadata service or to anything else inside the cluster. It
+// doesn't use a proxy or follow redirects either, since both would get
+// around that check
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialPublic,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+var (
+	// the global webhooks, started on the global event stream
+	webhooks   *Webhooks
+	webhooksMu sync.RWMutex
+)
+
+// GetWebhooks is a thread safe singleton which will, on the first time being
+// called, initalize new webhooks sending the global book store's events, and
+// on subsequent calls return that same instance (or whichever was given to
+// SetWebhooks)
+func GetWebhooks() *Webhooks {
+	webhooksMu.RLock()
+	w := webhooks
+	webhooksMu.RUnlock()
+
+	if w != nil {
+		return w
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	if webhooks == nil {
+		webhooks = NewWebhooks()
+		webhooks.Start(GetEvents())
+	}
+
+	return webhooks
+}
+
+// SetWebhooks replaces the global webhooks, w has to have already been
+// started. The webhooks it replaces are left running, so they should be
+// stopped by the caller
+func SetWebhooks(w *Webhooks) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	webhooks = w
+}
+
+// Webhooks holds the webhooks and sends each of them the events they're
+// subscribed to. Deliveries that fail are retried with exponential backoff
+// and the ones that keep failing are put in the dead letters. Every
+// delivery is kept in the delivery log, up to maxDeliveryLog of them. Once
+// they've been loaded from a RecordStore the webhooks and deliveries are
+// saved there too
+type Webhooks struct {
+	sync.Mutex
+
+	webhooks   map[uuid.UUID]Webhook
+	deliveries map[uuid.UUID]*Delivery
+
+	// log holds the id of every delivery in the order they were made
+	log []uuid.UUID
+
+	// timers holds the timer of each delivery waiting for its next attempt
+	timers map[uuid.UUID]*time.Timer
+
+	stop     chan struct{}
+	stopped  bool
+	inFlight sync.WaitGroup
+
+	// records is where the webhooks and deliveries are saved, nil until
+	// they've been loaded
+	records RecordStore
+
+	// Policy holds the rules for sending deliveries
+	Policy WebhookPolicy
+
+	// Client sends the deliveries, it only reaches public addresses unless
+	// it's replaced
+	Client *http.Client
+
+	// Clock is where the current time comes from
+	Clock Clock
+}
+
+// NewWebhooks returns webhooks without any webhooks, they don't send
+// anything until they're started
+func NewWebhooks() *Webhooks {
+	return &Webhooks{
+		webhooks:   make(map[uuid.UUID]Webhook),
+		deliveries: make(map[uuid.UUID]*Delivery),
+		timers:     make(map[uuid.UUID]*time.Timer),
+		stop:       make(chan struct{}),
+
+		Policy: DefaultWebhookPolicy(),
+		Client: NewWebhookClient(),
+		Clock:  realClock{},
+	}
+}
+
+// Start sends the events published on the stream from now on to the
+// webhooks. If the webhooks fall behind the stream they catch up on what
+// they missed, as long as it's still in the stream's history
+func (w *Webhooks) Start(events *EventStream) {
+	sub := events.Subscribe()
+	lastID := events.LastID()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-sub.Events:
+				if ok {
+					w.enqueue(event)
+					lastID = event.ID
+					continue
+				}
+
+				var missed []Event
+				var resumed bool
+				sub, missed, resumed = events.Resume(lastID)
+				if !resumed {
+					log.Errorf("Webhooks fell too far behind the events, some events after %v weren't sent", lastID)
+				}
+				for _, event := range missed {
+					w.enqueue(event)
+					lastID = event.ID
+				}
+			case <-w.stop:
+				events.Unsubscribe(sub)
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending events, deliveries waiting for their next attempt are
+// left pending and the attempts being sent get until the context is done to
+// finish
+func (w *Webhooks) Stop(ctx context.Context) error {
+	w.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+	}
+	for id, timer := range w.timers {
+		if timer.Stop() {
+			w.inFlight.Done()
+		}
+		delete(w.timers, id)
+	}
+	w.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue makes a delivery of the event for each webhook that wants it
+func (w *Webhooks) enqueue(event Event) {
+	w.Lock()
+	defer w.Unlock()
+
+	var changed []Record
+	for _, hook := range w.webhooks {
+		if !hook.Wants(event.Type) {
+			continue
+		}
+
+		id, _ := uuid.NewV4()
+		delivery := &Delivery{
+			ID:        id,
+			WebhookID: hook.ID,
+			Event:     event,
+			Status:    DeliveryPending,
+			Attempts:  []DeliveryAttempt{},
+		}
+
+		w.deliveries[id] = delivery
+		w.log = append(w.log, id)
+		w.schedule(delivery, 0)
+		changed = append(changed, deliveryRecordOf(delivery))
+	}
+
+	for _, id := range w.trimLog() {
+		changed = append(changed, Record{Kind: deliveryRecord, ID: id})
+	}
+
+	w.saveLogged(changed...)
+}
+
+// trimLog forgets the oldest deliveries that have finished once there are
+// more than maxDeliveryLog of them, and returns their ids
+func (w *Webhooks) trimLog() []uuid.UUID {
+	var forgotten []uuid.UUID
+	for len(w.log) > maxDeliveryLog {
+		kept := w.log[:0]
+		excess := len(w.log) - maxDeliveryLog
+		for _, id := range w.log {
+			if excess > 0 && w.deliveries[id].Status != DeliveryPending {
+				delete(w.deliveries, id)
+				forgotten = append(forgotten, id)
+				excess--
+				continue
+			}
+			kept = append(kept, id)
+		}
+
+		// everything left is pending, so it can't be forgotten yet
+		if len(kept) == len(w.log) {
+			break
+		}
+		w.log = kept
+	}
+
+	return forgotten
+}
+
+// schedule sends the delivery's next attempt after the wait, the caller has
+// to hold the lock
+func (w *Webhooks) schedule(delivery *Delivery, wait time.Duration) {
+	if w.stopped {
+		return
+	}
+
+	next := w.Clock.Now().Add(wait)
+	delivery.NextAttemptAt = &next
+
+	w.inFlight.Add(1)
+	w.timers[delivery.ID] = time.AfterFunc(wait, func() {
+		defer w.inFlight.Done()
+		w.attempt(delivery.ID)
+	})
+}
+
+// attempt tries to send a delivery, and schedules the next attempt if it
+// fails
+func (w *Webhooks) attempt(id uuid.UUID) {
+	w.Lock()
+	delete(w.timers, id)
+	delivery, found := w.deliveries[id]
+	if !found || delivery.Status != DeliveryPending {
+		w.Unlock()
+		return
+	}
+	hook, found := w.webhooks[delivery.WebhookID]
+	event := delivery.Event
+	policy := w.Policy
+	w.Unlock()
+
+	attempt := DeliveryAttempt{At: w.Clock.Now()}
+	if found {
+		attempt.StatusCode, attempt.Error = w.send(hook, id, event, policy.Timeout)
+	} else {
+		attempt.Error = ErrNoWebhookWithThatID.Error()
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = DeliverySucceeded
+	case !found || len(delivery.Attempts) >= policy.MaxAttempts:
+		delivery.Status = DeliveryDead
+		log.Warnf("Delivery %v of event %v to webhook %v is dead after %v attempts: %v", id, event.ID, delivery.WebhookID, len(delivery.Attempts), attempt.Error)
+	default:
+		w.schedule(delivery, policy.backoff(len(delivery.Attempts)))
+	}
+
+	w.saveLogged(deliveryRecordOf(delivery))
+}
+
+// send POSTs the event to the webhook and returns the status code it
+// responded with, and what went wrong if it didn't respond with a 2xx
+func (w *Webhooks) send(hook Webhook, deliveryID uuid.UUID, event Event, timeout time.Duration) (int, string) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Sprintf("Unable to marshal the event: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	timestamp := strconv.FormatInt(w.Clock.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, string(event.Type))
+	request.Header.Set(WebhookDeliveryHeader, deliveryID.String())
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, timestamp, body))
+
+	res, err := w.Client.Do(request)
+	if err != nil {
+		return 0, err.Error()
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Sprintf("The webhook responded with %v", res.Status)
+	}
+
+	return res.StatusCode, ""
+}
+
+// newWebhookSecret returns a random secret for a webhook that wasn't given
+// one
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Add stores a new webhook with a new id, and a new secret if it wasn't
+// given one, and returns it with its secret
+func (w *Webhooks) Add(hook Webhook) (Webhook, error) {
+	err := hook.Validate()
+	if err != nil {
+		return hook, err
+	}
+
+	hook.ID, _ = uuid.NewV4()
+	hook.CreatedAt = w.Clock.Now()
+	if hook.Events == nil {
+		hook.Events = []EventType{}
+	}
+	if hook.Secret == "" {
+		hook.Secret = newWebhookSecret()
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	err = w.save(webhookRecordOf(hook))
+	if err != nil {
+		return hook, err
+	}
+
+	w.webhooks[hook.ID] = hook
+	return hook, nil
+}
+
+// List returns every webhook, without their secrets, oldest first
+func (w *Webhooks) List() []Webhook {
+	w.Lock()
+	defer w.Unlock()
+
+	hooks := make([]Webhook, 0, len(w.webhooks))
+	for _, hook := range w.webhooks {
+		hook.Secret = ""
+		hooks = append(hooks, hook)
+	}
+
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+
+	return hooks
+}
+
+// Get returns the webhook with the given id, without its secret
+func (w *Webhooks) Get(id uuid.UUID) (Webhook, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	hook, found := w.webhooks[id]
+	if !found {
+		return hook, ErrNoWebhookWithThatID
+	}
+
+	hook.Secret = ""
+	return hook, nil
+}
+
+// Remove deletes the webhook, its pending deliveries become dead on their
+// next attempt
+func (w *Webhooks) Remove(id uuid.UUID) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if _, found := w.webhooks[id]; !found {
+		return ErrNoWebhookWithThatID
+	}
+
+	err := w.save(Record{Kind: webhookRecord, ID: id})
+	if err != nil {
+		return err
+	}
+
+	delete(w.webhooks, id)
+	return nil
+}
+
+// matchingDeliveries returns a copy of each delivery that matches, newest first
+func (w *Webhooks) matchingDeliveries(matches func(delivery *Delivery) bool) []Delivery {
+	deliveries := []Delivery{}
+	for i := len(w.log) - 1; i >= 0; i-- {
+		delivery := w.deliveries[w.log[i]]
+		if matches(delivery) {
+			copied := *delivery
+			copied.Attempts = append([]DeliveryAttempt{}, delivery.Attempts...)
+			deliveries = append(deliveries, copied)
+		}
+	}
+
+	return deliveries
+}
+
+// Deliveries returns the delivery log of the webhook, newest first
+func (w *Webhooks) Deliveries(webhookID uuid.UUID) ([]Delivery, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if _, found := w.webhooks[webhookID]; !found {
+		return nil, ErrNoWebhookWithThatID
+	}
+
+	return w.matchingDeliveries(func(delivery *Delivery) bool {
+		return delivery.WebhookID == webhookID
+	}), nil
+}
+
+// DeadLetters returns the deliveries that ran out of attempts, newest first
+func (w *Webhooks) DeadLetters() []Delivery {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.matchingDeliveries(func(delivery *Delivery) bool {
+		return delivery.Status == DeliveryDead
+	})
+}
+
+// Retry takes a delivery out of the dead letters and sends it again, with
+// all of its attempts
+func (w *Webhooks) Retry(deliveryID uuid.UUID) (Delivery, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	delivery, found := w.deliveries[deliveryID]
+	if !found {
+		return Delivery{}, ErrNoDeliveryWithThatID
+	}
+	if delivery.Status != DeliveryDead {
+		return Delivery{}, ErrDeliveryNotDead
+	}
+	if _, found := w.webhooks[delivery.WebhookID]; !found {
+		return Delivery{}, ErrNoWebhookWithThatID
+	}
+
+	delivery.Status = DeliveryPending
+	delivery.Attempts = []DeliveryAttempt{}
+	w.schedule(delivery, 0)
+
+	copied := *delivery
+	return copied, w.save(deliveryRecordOf(delivery))
+}
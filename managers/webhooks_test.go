@@ -0,0 +1,350 @@
+package managers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "github.com/askewseth/kubernetes/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// newTestWebhooks returns started webhooks with a backoff short enough for
+// the tests
+func newTestWebhooks(stream *EventStream) *Webhooks {
+	w := NewWebhooks()
+	// the receivers are httptest servers on the loopback, which the default
+	// client refuses to send to
+	w.Client = &http.Client{}
+	w.Policy = WebhookPolicy{MaxAttempts: 3, Backoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Timeout: time.Second}
+	w.Start(stream)
+
+	return w
+}
+
+// waitForDeliveries waits until every delivery to the webhook has finished
+// and returns them
+func waitForDeliveries(t *testing.T, w *Webhooks, hook Webhook, count int) []Delivery {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := w.Deliveries(hook.ID)
+		if err != nil {
+			t.Errorf("Error getting the deliveries: %v", err)
+			t.FailNow()
+		}
+
+		finished := 0
+		for _, delivery := range deliveries {
+			if delivery.Status != DeliveryPending {
+				finished++
+			}
+		}
+		if len(deliveries) == count && finished == count {
+			return deliveries
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Errorf("Timed out waiting for %v deliveries to finish", count)
+	t.FailNow()
+	return nil
+}
+
+func TestWebhookSignedDelivery(t *testing.T) {
+	var got int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signature := SignWebhook("shh", r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != signature {
+			t.Errorf("Expected the signature %v, got %v", signature, r.Header.Get(WebhookSignatureHeader))
+		}
+		if r.Header.Get(WebhookEventHeader) != string(EventCreated) {
+			t.Errorf("Expected only created events, got %v", r.Header.Get(WebhookEventHeader))
+		}
+
+		atomic.AddInt32(&got, 1)
+	}))
+	defer receiver.Close()
+
+	stream := NewEventStream(10)
+	w := newTestWebhooks(stream)
+	defer w.Stop(context.Background())
+
+	hook, err := w.Add(Webhook{URL: receiver.URL, Events: []EventType{EventCreated}, Secret: "shh"})
+	if err != nil {
+		t.Errorf("Error adding the webhook: %v", err)
+		t.FailNow()
+	}
+
+	book := model.NewBook()
+	stream.Publish(Change{Type: BookCreated, Book: book})
+	stream.Publish(Change{Type: BookDeleted, Book: book})
+
+	deliveries := waitForDeliveries(t, w, hook, 1)
+	if deliveries[0].Status != DeliverySucceeded || len(deliveries[0].Attempts) != 1 {
+		t.Errorf("Expected the delivery to succeed on the first attempt, got %+v", deliveries[0])
+	}
+	if atomic.LoadInt32(&got) != 1 {
+		t.Errorf("Expected the webhook to get 1 delivery, got %v", got)
+	}
+
+	listed, _ := w.Get(hook.ID)
+	if listed.Secret != "" {
+		t.Errorf("Expected the secret not to be returned by Get")
+	}
+}
+
+func TestWebhookRetriesAndDeadLetters(t *testing.T) {
+	var failures int32 = 4
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	stream := NewEventStream(10)
+	w := newTestWebhooks(stream)
+	defer w.Stop(context.Background())
+
+	hook, _ := w.Add(Webhook{URL: receiver.URL})
+
+	// the first delivery fails 3 times, running out of attempts, and the
+	// second fails once and then succeeds
+	stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+	waitForDeliveries(t, w, hook, 1)
+	stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+	deliveries := waitForDeliveries(t, w, hook, 2)
+
+	if deliveries[0].Status != DeliverySucceeded || len(deliveries[0].Attempts) != 2 {
+		t.Errorf("Expected the second delivery to succeed on its second attempt, got %+v", deliveries[0])
+	}
+	if deliveries[1].Status != DeliveryDead || len(deliveries[1].Attempts) != 3 {
+		t.Errorf("Expected the first delivery to be dead after 3 attempts, got %+v", deliveries[1])
+	}
+	if deliveries[1].Attempts[0].StatusCode != 500 || deliveries[1].Attempts[0].Error == "" {
+		t.Errorf("Expected the failed attempts to be logged, got %+v", deliveries[1].Attempts[0])
+	}
+
+	dead := w.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != deliveries[1].ID {
+		t.Errorf("Expected the first delivery to be the only dead letter, got %+v", dead)
+		t.FailNow()
+	}
+
+	_, err := w.Retry(deliveries[0].ID)
+	if err != ErrDeliveryNotDead {
+		t.Errorf("Expected ErrDeliveryNotDead retrying a delivery that succeeded, got %v", err)
+	}
+
+	_, err = w.Retry(dead[0].ID)
+	if err != nil {
+		t.Errorf("Error retrying the dead letter: %v", err)
+	}
+
+	deliveries = waitForDeliveries(t, w, hook, 2)
+	if deliveries[1].Status != DeliverySucceeded {
+		t.Errorf("Expected the retried delivery to succeed, got %+v", deliveries[1])
+	}
+	if len(w.DeadLetters()) != 0 {
+		t.Errorf("Expected no dead letters after the retry succeeded")
+	}
+}
+
+func TestWebhooksLoad(t *testing.T) {
+	var up int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhook("shh", r.Header.Get(WebhookTimestampHeader), body) {
+			t.Errorf("Expected the delivery to be signed with the saved secret")
+		}
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	library := NewLibrary()
+	stream := NewEventStream(10)
+
+	w := NewWebhooks()
+	w.Client = &http.Client{}
+	w.Policy = WebhookPolicy{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: time.Hour, Timeout: time.Second}
+	if err := w.Load(library); err != nil {
+		t.Errorf("Error loading the webhooks: %v", err)
+		t.FailNow()
+	}
+	w.Start(stream)
+
+	hook, _ := w.Add(Webhook{URL: receiver.URL, Secret: "shh"})
+	stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+
+	// the first attempt fails, leaving the delivery waiting an hour for its
+	// next one when the process stops
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, _ := w.Deliveries(hook.ID)
+		if len(deliveries) == 1 && len(deliveries[0].Attempts) == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	w.Stop(context.Background())
+
+	atomic.StoreInt32(&up, 1)
+
+	restarted := NewWebhooks()
+	restarted.Client = &http.Client{}
+	restarted.Clock = NewFakeClock(time.Now().Add(2 * time.Hour))
+	if err := restarted.Load(library); err != nil {
+		t.Errorf("Error loading the webhooks: %v", err)
+		t.FailNow()
+	}
+	defer restarted.Stop(context.Background())
+
+	if _, err := restarted.Get(hook.ID); err != nil {
+		t.Errorf("Expected the webhook to be loaded, got %v", err)
+		t.FailNow()
+	}
+
+	// the overdue attempt is sent straight away
+	deliveries := waitForDeliveries(t, restarted, hook, 1)
+	if deliveries[0].Status != DeliverySucceeded || len(deliveries[0].Attempts) != 2 {
+		t.Errorf("Expected the loaded delivery to succeed on its second attempt, got %+v", deliveries[0])
+	}
+
+	if err := restarted.Remove(hook.ID); err != nil {
+		t.Errorf("Error removing the webhook: %v", err)
+	}
+	if saved, _ := library.GetRecords(webhookRecord); len(saved) != 0 {
+		t.Errorf("Expected the removed webhook to be deleted from the records, got %+v", saved)
+	}
+}
+
+func TestWebhooksLoadCorruptRecord(t *testing.T) {
+	library := NewLibrary()
+	id, _ := uuid.NewV4()
+	library.SaveRecords([]Record{{Kind: deliveryRecord, ID: id, Value: json.RawMessage(`{"id": 7}`)}})
+
+	w := NewWebhooks()
+	if err := w.Load(library); err == nil {
+		t.Errorf("Expected an error loading a corrupt delivery")
+	}
+
+	if len(w.deliveries) != 0 || len(w.log) != 0 {
+		t.Errorf("Expected the corrupt delivery not to be loaded, got %+v", w.log)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.96.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if public := publicIP(net.ParseIP(test.ip)); public != test.public {
+			t.Errorf("Expected %v to be public %v, got %v", test.ip, test.public, public)
+		}
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	var got int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&got, 1)
+	}))
+	defer receiver.Close()
+
+	stream := NewEventStream(10)
+	w := newTestWebhooks(stream)
+	w.Client = NewWebhookClient()
+	defer w.Stop(context.Background())
+
+	hook, _ := w.Add(Webhook{URL: receiver.URL})
+	stream.Publish(Change{Type: BookCreated, Book: model.NewBook()})
+
+	deliveries := waitForDeliveries(t, w, hook, 1)
+	if deliveries[0].Status != DeliveryDead || !strings.Contains(deliveries[0].Attempts[0].Error, "isn't a public address") {
+		t.Errorf("Expected the delivery to the loopback to be refused, got %+v", deliveries[0])
+	}
+	if atomic.LoadInt32(&got) != 0 {
+		t.Errorf("Expected the webhook on the loopback not to get anything, got %v deliveries", got)
+	}
+}
+
+func TestWebhookClientDoesntFollowRedirects(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	// the loopback is let through so the redirect can be seen
+	client := NewWebhookClient()
+	client.Transport = http.DefaultTransport
+
+	res, err := client.Post(receiver.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Errorf("Error sending to the webhook: %v", err)
+		t.FailNow()
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("Expected the redirect to be returned rather than followed, got %v", res.Status)
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	tests := []struct {
+		hook Webhook
+		err  error
+	}{
+		{Webhook{URL: "https://example.com/hook"}, nil},
+		{Webhook{URL: "http://example.com", Events: []EventType{EventDeleted, EventStatusChanged}}, nil},
+		{Webhook{URL: "example.com/hook"}, ErrInvalidWebhookURL},
+		{Webhook{URL: "ftp://example.com"}, ErrInvalidWebhookURL},
+		{Webhook{URL: "https://example.com", Events: []EventType{"borrowed"}}, ErrInvalidWebhookEvent},
+	}
+
+	for _, test := range tests {
+		err := test.hook.Validate()
+		if err != test.err {
+			t.Errorf("Expected %v validating %+v, got %v", test.err, test.hook, err)
+		}
+	}
+}
+
+func TestWebhookPolicyBackoff(t *testing.T) {
+	policy := WebhookPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, wait := range want {
+		if got := policy.backoff(i + 1); got != wait {
+			t.Errorf("Expected to wait %v after %v attempts, got %v", wait, i+1, got)
+		}
+	}
+}
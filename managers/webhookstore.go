@@ -0,0 +1,125 @@
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the kinds of record the webhooks save in their RecordStore
+const (
+	webhookRecord  = "webhook"
+	deliveryRecord = "delivery"
+)
+
+// Load reads the webhooks, along with their secrets, and the delivery log
+// saved in the RecordStore, and from then on saves every change to them
+// there. It's meant to be called once at startup, before the webhooks are
+// started.
+//
+// Deliveries that were still pending are sent again when their next attempt
+// is due, or straight away if it's overdue. A delivery that was being sent
+// when the process stopped is sent again too, receivers can tell it's a
+// duplicate from its id
+func (w *Webhooks) Load(records RecordStore) error {
+	w.Lock()
+	defer w.Unlock()
+
+	err := loadRecords(records, webhookRecord, func(value []byte) error {
+		var hook Webhook
+		err := json.Unmarshal(value, &hook)
+		if err != nil {
+			return err
+		}
+
+		w.webhooks[hook.ID] = hook
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadRecords(records, deliveryRecord, func(value []byte) error {
+		var delivery Delivery
+		err := json.Unmarshal(value, &delivery)
+		if err != nil {
+			return err
+		}
+
+		w.deliveries[delivery.ID] = &delivery
+		w.log = append(w.log, delivery.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the log is in the order the events happened
+	sort.SliceStable(w.log, func(i, j int) bool {
+		a, b := w.deliveries[w.log[i]].Event, w.deliveries[w.log[j]].Event
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.ID < b.ID
+	})
+
+	w.records = records
+
+	now := w.Clock.Now()
+	for _, id := range w.log {
+		delivery := w.deliveries[id]
+		if delivery.Status != DeliveryPending {
+			continue
+		}
+
+		var wait time.Duration
+		if delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(now) {
+			wait = delivery.NextAttemptAt.Sub(now)
+		}
+		w.schedule(delivery, wait)
+	}
+
+	return nil
+}
+
+// webhookRecordOf returns the record a webhook is saved as
+func webhookRecordOf(hook Webhook) Record {
+	// webhooks are plain structs, which can't fail to marshal
+	b, _ := json.Marshal(hook)
+	return Record{Kind: webhookRecord, ID: hook.ID, Value: b}
+}
+
+// deliveryRecordOf returns the record a delivery is saved as
+func deliveryRecordOf(delivery *Delivery) Record {
+	// deliveries are plain structs too
+	b, _ := json.Marshal(delivery)
+	return Record{Kind: deliveryRecord, ID: delivery.ID, Value: b}
+}
+
+// save writes the records to the RecordStore, all at once, if the webhooks
+// have been loaded from one. The caller has to hold the lock
+func (w *Webhooks) save(records ...Record) error {
+	if w.records == nil || len(records) == 0 {
+		return nil
+	}
+
+	err := w.records.SaveRecords(records)
+	if err != nil {
+		return fmt.Errorf("Unable to save the webhooks: %v", err)
+	}
+
+	return nil
+}
+
+// saveLogged saves the records for the changes made while sending the
+// deliveries, which don't have anyone to return an error to so it's logged
+// instead. The delivery is still sent, it just won't survive a restart
+func (w *Webhooks) saveLogged(records ...Record) {
+	err := w.save(records...)
+	if err != nil {
+		log.Errorf("%v", err)
+	}
+}
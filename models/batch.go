@@ -0,0 +1,25 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+// BatchOp is the kind of change a batch operation makes
+type BatchOp string
+
+// this const block holds the BatchOp values
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation is a single change in a batch. Creates and updates carry the
+// whole book, like POST and PUT, while updates and deletes name the book by
+// ID. If Revision is given the book has to still be at that revision
+type BatchOperation struct {
+	Op       BatchOp   `json:"op"`
+	ID       uuid.UUID `json:"id"`
+	Revision uint64    `json:"revision,omitempty"`
+	Book     Book      `json:"book"`
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -11,25 +12,36 @@ import (
 var (
 	// ErrInvalidStatus is returned whenever someone tried to create or modify a book to have an
 	// invalid status
-	ErrInvalidStatus = errors.New("The status must either be CheckedIn(0) or CheckedOut(1)")
+	ErrInvalidStatus = errors.New("The status must be CheckedIn(0), CheckedOut(1), Reserved(2) or Lost(3)")
 
 	// ErrInvalidRating is returned whenever someone tried to create or modify a book to have an
 	// invalid rating
 	ErrInvalidRating = errors.New("The rating must be 1-3")
+
+	// ErrInvalidISBN is returned whenever someone tried to create or modify a book to have an
+	// ISBN that isn't a valid ISBN-10 or ISBN-13
+	ErrInvalidISBN = errors.New("The isbn must be a valid ISBN-10 or ISBN-13")
+
+	// ErrInvalidText is returned whenever someone tried to create or modify a book to have a
+	// title, author or publisher with a control character in it
+	ErrInvalidText = errors.New("The title, author and publisher can't contain control characters other than tabs and newlines")
 )
 
 // Status is an enum that will cover the two different status for books
 type Status uint8
 
-// this const block holds the Status enum values
+// this const block holds the Status enum values, a book is Reserved when it's
+// been returned but is being held for the next patron in its holds queue, and
+// Lost when the patron who had it checked out lost it
 const (
 	CheckedIn Status = iota
 	CheckedOut
+	Reserved
+	Lost
 )
 
-// NullUInt8 is the null value that will be used for uint8 fields
-// since uint8 doesn't support -1, the null value is 255
-const NullUInt8 = 255
+// Statuses holds every valid Status
+var Statuses = []Status{CheckedIn, CheckedOut, Reserved, Lost}
 
 // Book is the struct that holds all of the attributes for a book
 type Book struct {
@@ -38,8 +50,13 @@ type Book struct {
 	Author      string     `json:"author,omitempty"`
 	Publisher   string     `json:"publisher,omitempty"`
 	PublishDate *time.Time `json:"publish_date,omitempty"`
+	ISBN        string     `json:"isbn,omitempty"`
 	Rating      uint8      `json:"rating,omitempty"`
 	Status      Status     `json:"status,omitempty"`
+
+	// Revision goes up by one every time the book is changed, it's what
+	// the book's ETag is made from
+	Revision uint64 `json:"revision"`
 }
 
 // NewBook returns an initalized Book struct
@@ -49,20 +66,6 @@ func NewBook() Book {
 	return Book{ID: id, Status: CheckedIn}
 }
 
-// NewDefaultBook returns a book with all of the
-// fields with a negative value so that manager.ModifyBook
-// can tell whether or not a field was given by a user
-func NewDefaultBook() Book {
-	return Book{
-		Title:       "-1",
-		Author:      "-1",
-		Publisher:   "-1",
-		PublishDate: nil,
-		Rating:      NullUInt8,
-		Status:      Status(NullUInt8),
-	}
-}
-
 // Validate will return an error if any of the feilds of the book are outside of what they should be
 func (b Book) Validate() error {
 	// check if the rating is between 1 and 3
@@ -70,36 +73,153 @@ func (b Book) Validate() error {
 		return ErrInvalidRating
 	}
 
-	// check if the status is one of the two valid statuses
-	status := int(b.Status)
-	if status < 0 || status > 1 {
+	// check if the status is one of the valid statuses
+	if b.Status.String() == "" {
 		return ErrInvalidStatus
 	}
 
+	// the isbn is optional, but if it's given its check digit has to be right
+	if b.ISBN != "" && !validISBN(b.ISBN) {
+		return ErrInvalidISBN
+	}
+
+	// control characters would split the fields of a MARC export, and XML
+	// can't hold them at all
+	for _, s := range []string{b.Title, b.Author, b.Publisher} {
+		if !validText(s) {
+			return ErrInvalidText
+		}
+	}
+
 	return nil
 }
 
+// validText returns false if the string has a C0 control character in it,
+// apart from tabs, newlines and carriage returns
+func validText(s string) bool {
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validISBN returns true if the isbn, ignoring hyphens and spaces, is an
+// ISBN-10 or ISBN-13 with the right check digit
+func validISBN(isbn string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(isbn)
+
+	sum := 0
+	switch len(digits) {
+	case 10:
+		for i, r := range digits {
+			digit := int(r - '0')
+			if r == 'X' || r == 'x' {
+				if i != 9 {
+					return false
+				}
+				digit = 10
+			} else if r < '0' || r > '9' {
+				return false
+			}
+			sum += (10 - i) * digit
+		}
+		return sum%11 == 0
+
+	case 13:
+		for i, r := range digits {
+			if r < '0' || r > '9' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(r-'0')
+		}
+		return sum%10 == 0
+	}
+
+	return false
+}
+
+// String returns the name of the status, the same name that's used in the
+// json version of a book
+func (s Status) String() string {
+	switch s {
+	case CheckedIn:
+		return "CheckedIn"
+	case CheckedOut:
+		return "CheckedOut"
+	case Reserved:
+		return "Reserved"
+	case Lost:
+		return "Lost"
+	}
+
+	return ""
+}
+
 // MarshalJSON returns a byte array of the json version of a book,
 // the only difference between this and the model version of a book is that
 // status is a string instead of a uint8
 func (b Book) MarshalJSON() ([]byte, error) {
 	type Alias Book
 
-	var stringStatus string
-	switch b.Status {
-	case CheckedIn:
-		stringStatus = "CheckedIn"
-	case CheckedOut:
-		stringStatus = "CheckedOut"
-	}
-
 	return json.Marshal(&struct {
 		Status       Status `json:"-"`
 		StringStatus string `json:"status"`
 		Alias
 	}{
 		Status:       b.Status,
-		StringStatus: stringStatus,
+		StringStatus: b.Status.String(),
 		Alias:        (Alias)(b),
 	})
 }
+
+// UnmarshalJSON reads a book from json, the status can either be given as an
+// int or as the string that MarshalJSON writes, so that a marshaled book can
+// be read back in. Fields that aren't in the json are left untouched
+func (b *Book) UnmarshalJSON(data []byte) error {
+	type Alias Book
+
+	aux := struct {
+		Status json.RawMessage `json:"status"`
+		*Alias
+	}{
+		Alias: (*Alias)(b),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if len(aux.Status) == 0 || string(aux.Status) == "null" {
+		return nil
+	}
+
+	// the status was given as an int
+	var status uint8
+	if json.Unmarshal(aux.Status, &status) == nil {
+		b.Status = Status(status)
+		return nil
+	}
+
+	// the status was given as a string
+	var stringStatus string
+	if json.Unmarshal(aux.Status, &stringStatus) != nil {
+		return ErrInvalidStatus
+	}
+
+	for _, s := range Statuses {
+		if s.String() == stringStatus {
+			b.Status = s
+			return nil
+		}
+	}
+
+	return ErrInvalidStatus
+}
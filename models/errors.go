@@ -0,0 +1,130 @@
+package model
+
+import "errors"
+
+// the errors the managers return, they're kept here rather than with the
+// managers so that the client can match the api's errors without importing
+// the server
+var (
+	// ErrNoBookWithThatID is the error returned whenever someone tried to
+	// GET, PUT, or DELETE a book with an id that isn't found in the manager
+	ErrNoBookWithThatID = errors.New("The given book uuid wasn't found")
+
+	// ErrNoPatronWithThatID is the error returned whenever someone tries to
+	// use a patron with an id that isn't found
+	ErrNoPatronWithThatID = errors.New("The given patron uuid wasn't found")
+
+	// ErrBookCheckedOut is returned when trying to check out a book that
+	// already has an active loan
+	ErrBookCheckedOut = errors.New("The book is already checked out")
+
+	// ErrBookNotCheckedOut is returned when trying to return or renew a book
+	// that doesn't have an active loan
+	ErrBookNotCheckedOut = errors.New("The book isn't checked out")
+
+	// ErrMaxRenewals is returned when trying to renew a loan that's already
+	// been renewed as many times as the policy allows
+	ErrMaxRenewals = errors.New("The loan has already been renewed the maximum number of times")
+
+	// ErrRenewalBlockedByHolds is returned when trying to renew a loan on a
+	// book that other patrons are waiting for
+	ErrRenewalBlockedByHolds = errors.New("The loan can't be renewed because other patrons have holds on the book")
+
+	// ErrNoHoldWithThatID is the error returned whenever someone tries to
+	// use a hold with an id that isn't found on the book
+	ErrNoHoldWithThatID = errors.New("The given hold uuid wasn't found")
+
+	// ErrBookAvailable is returned when trying to place a hold on a book
+	// that can just be checked out
+	ErrBookAvailable = errors.New("The book is available, it can be checked out instead of held")
+
+	// ErrAlreadyOnHold is returned when a patron tries to place a second hold
+	// on the same book
+	ErrAlreadyOnHold = errors.New("The patron already has a hold on the book")
+
+	// ErrPatronHasBook is returned when a patron tries to place a hold on a
+	// book they have checked out
+	ErrPatronHasBook = errors.New("The patron already has the book checked out")
+
+	// ErrBookReserved is returned when trying to check out a book that's
+	// reserved for another patron
+	ErrBookReserved = errors.New("The book is reserved for another patron")
+
+	// ErrBookHasHolds is returned when trying to remove a book that patrons
+	// are waiting for
+	ErrBookHasHolds = errors.New("The book has holds on it")
+
+	// ErrInvalidAmount is returned when a payment or waiver isn't for a
+	// positive amount
+	ErrInvalidAmount = errors.New("The amount must be a positive number of cents")
+
+	// ErrAmountOverBalance is returned when a payment or waiver is for more
+	// than the patron owes
+	ErrAmountOverBalance = errors.New("The amount is more than the patron's balance")
+
+	// ErrPatronBlocked is returned when a patron who owes more than the
+	// policy allows tries to check out a book
+	ErrPatronBlocked = errors.New("The patron owes too much in fines to check out books")
+
+	// ErrBookLost is returned when trying to check out or hold a lost book
+	ErrBookLost = errors.New("The book has been lost")
+
+	// ErrBookNotLost is returned when a book that isn't lost is found
+	ErrBookNotLost = errors.New("The book isn't lost")
+
+	// ErrBatchTooLarge is returned for a batch with more than MaxBatchSize
+	// operations
+	ErrBatchTooLarge = errors.New("The batch has too many operations")
+
+	// ErrUnknownBatchOp is returned for an operation that isn't a create,
+	// update or delete
+	ErrUnknownBatchOp = errors.New("The op must be create, update or delete")
+
+	// ErrBookExists is returned when creating a book with the id of a book
+	// that's already in the library
+	ErrBookExists = errors.New("A book with the given uuid already exists")
+
+	// ErrRevisionMismatch is returned for an operation whose revision isn't
+	// the book's current revision
+	ErrRevisionMismatch = errors.New("The book has been changed since the given revision")
+
+	// ErrBatchAborted is the result of every operation that would have
+	// succeeded in an atomic batch where another operation failed
+	ErrBatchAborted = errors.New("Not applied because another operation in the batch failed")
+
+	// ErrEmptyCSV is returned when importing a csv that doesn't even have a
+	// header row
+	ErrEmptyCSV = errors.New("The csv is empty, it needs a header row")
+
+	// ErrUnknownColumn is returned when an import's mapping maps a header to
+	// a column that isn't in CSVColumns
+	ErrUnknownColumn = errors.New("The mapping has to map headers to id, title, author, publisher, publish_date, rating, status, revision or isbn")
+
+	// ErrInvalidMARC is returned when a MARC21 file is broken in a way that
+	// means none of the records after the break can be found
+	ErrInvalidMARC = errors.New("The MARC21 file is malformed")
+
+	// ErrInvalidSortField is returned when a BookQuery is sorted by a field
+	// that books can't be sorted by
+	ErrInvalidSortField = errors.New("The sort field must be one of title, author, publisher, publish_date, rating or status")
+
+	// ErrNoWebhookWithThatID is returned when there isn't a webhook with the
+	// given id
+	ErrNoWebhookWithThatID = errors.New("The given webhook uuid wasn't found")
+
+	// ErrNoDeliveryWithThatID is returned when there isn't a delivery with
+	// the given id
+	ErrNoDeliveryWithThatID = errors.New("The given delivery uuid wasn't found")
+
+	// ErrDeliveryNotDead is returned when retrying a delivery that isn't in
+	// the dead letters
+	ErrDeliveryNotDead = errors.New("Only deliveries in the dead letters can be retried")
+
+	// ErrInvalidWebhookURL is returned when a webhook's url isn't an absolute
+	// http or https url
+	ErrInvalidWebhookURL = errors.New("The webhook url must be an absolute http or https url")
+
+	// ErrInvalidWebhookEvent is returned when a webhook subscribes to an
+	// event type that doesn't exist
+	ErrInvalidWebhookEvent = errors.New("The webhook events must be created, updated, deleted or status-changed")
+)
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// EventType is the kind of change an Event is for
+type EventType string
+
+// this const block holds the EventType values, an update that changes a
+// book's status is sent as an EventUpdated followed by an EventStatusChanged
+const (
+	EventCreated       EventType = "created"
+	EventUpdated       EventType = "updated"
+	EventDeleted       EventType = "deleted"
+	EventStatusChanged EventType = "status-changed"
+)
+
+// EventTypes holds every EventType
+var EventTypes = []EventType{EventCreated, EventUpdated, EventDeleted, EventStatusChanged}
+
+// Event is a change to a book in the global book store, events are numbered
+// in the order they happened, carrying on from the time the process started
+// so that ids from before a restart are always lower than the new ones
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Book Book      `json:"book"`
+
+	// PreviousStatus is the status the book had before an
+	// EventStatusChanged
+	PreviousStatus string `json:"previous_status,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Hold is a patron's place in the queue for a checked out book. Once the book
+// is returned it's reserved for the patron at the front of the queue, and the
+// hold becomes ready until it expires at the end of the pickup window
+type Hold struct {
+	ID        uuid.UUID  `json:"id"`
+	BookID    uuid.UUID  `json:"book_id"`
+	PatronID  uuid.UUID  `json:"patron_id"`
+	PlacedAt  time.Time  `json:"placed_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Position is the hold's place in the queue starting at 1, it isn't
+	// stored, it's filled in whenever holds are returned
+	Position int `json:"position"`
+}
+
+// NewHold returns an initalized Hold struct with a uuid
+func NewHold() Hold {
+	id, _ := uuid.NewV4()
+	return Hold{ID: id}
+}
+
+// Ready returns true if the book is being held for the patron
+func (h Hold) Ready() bool {
+	return h.ReadyAt != nil
+}
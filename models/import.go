@@ -0,0 +1,42 @@
+package model
+
+// ImportOptions changes how a catalog is read and applied by ImportCSV and
+// ImportMARC
+type ImportOptions struct {
+	// Mapping maps headers in a csv to the columns in CSVColumns, any
+	// header that isn't in the mapping is matched to the column with the
+	// same name, ignoring case, and headers that don't match a column are
+	// ignored
+	Mapping map[string]string
+
+	// DefaultRating is given to books that don't have a rating, 0 leaves
+	// them without one, which fails validation
+	DefaultRating uint8
+
+	// DryRun checks every row without changing the library
+	DryRun bool
+
+	// Atomic only imports the rows if every one of them can be imported
+	Atomic bool
+}
+
+// ImportRowError is why a single row of a catalog couldn't be imported, for
+// a csv the header is row 1, just like a spreadsheet, and for MARC the first
+// record is row 1
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the outcome of importing a catalog. Created and Updated
+// count the rows that were, or in a dry run or failed atomic import would
+// have been, imported, Applied is whether the library was changed at all
+type ImportReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Applied bool             `json:"applied"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
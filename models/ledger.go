@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// LedgerEntryType is the kind of charge or credit on a patron's ledger
+type LedgerEntryType string
+
+// this const block holds the LedgerEntryType values, fines and lost items
+// are charges, and payments, waivers and the refunds for lost items that
+// were found are credits
+const (
+	OverdueFine    LedgerEntryType = "overdue_fine"
+	LostItem       LedgerEntryType = "lost_item"
+	Payment        LedgerEntryType = "payment"
+	Waiver         LedgerEntryType = "waiver"
+	LostItemRefund LedgerEntryType = "lost_item_refund"
+)
+
+// LedgerEntry is a single charge or credit on a patron's ledger, amounts are
+// in cents with charges positive and credits negative
+type LedgerEntry struct {
+	ID        uuid.UUID       `json:"id"`
+	PatronID  uuid.UUID       `json:"patron_id"`
+	LoanID    *uuid.UUID      `json:"loan_id,omitempty"`
+	Type      LedgerEntryType `json:"type"`
+	Amount    int64           `json:"amount"`
+	Note      string          `json:"note,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewLedgerEntry returns an initalized LedgerEntry struct with a uuid
+func NewLedgerEntry() LedgerEntry {
+	id, _ := uuid.NewV4()
+	return LedgerEntry{ID: id}
+}
+
+// Ledger is every charge and credit for a patron, Balance is what the patron
+// owes, and Accruing is what the patron's overdue loans will be charged if
+// they were returned now
+type Ledger struct {
+	PatronID uuid.UUID     `json:"patron_id"`
+	Entries  []LedgerEntry `json:"entries"`
+	Balance  int64         `json:"balance"`
+	Accruing int64         `json:"accruing"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Loan is the record of a book being checked out by a patron, it's active
+// until the book is either returned or reported lost. Title and Author are
+// the book's when it was checked out, so the loan still says which book it
+// was after the book is deleted
+type Loan struct {
+	ID           uuid.UUID  `json:"id"`
+	BookID       uuid.UUID  `json:"book_id"`
+	Title        string     `json:"title,omitempty"`
+	Author       string     `json:"author,omitempty"`
+	PatronID     uuid.UUID  `json:"patron_id"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	Renewals     int        `json:"renewals"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	LostAt       *time.Time `json:"lost_at,omitempty"`
+
+	// FoundAt is when a lost book turned up again, the loan stays ended
+	FoundAt *time.Time `json:"found_at,omitempty"`
+}
+
+// NewLoan returns an initalized Loan struct with a uuid
+func NewLoan() Loan {
+	id, _ := uuid.NewV4()
+	return Loan{ID: id}
+}
+
+// Active returns true if the book hasn't been returned or lost yet
+func (l Loan) Active() bool {
+	return l.ReturnedAt == nil && l.LostAt == nil
+}
+
+// Overdue returns true if the book hasn't been returned and it's past its
+// due date at the given time
+func (l Loan) Overdue(now time.Time) bool {
+	return l.Active() && now.After(l.DueAt)
+}
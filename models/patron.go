@@ -0,0 +1,36 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrInvalidPatronName is returned whenever someone tries to create a
+	// patron without a name
+	ErrInvalidPatronName = errors.New("The patron must have a name")
+)
+
+// Patron is someone who can check books out of the library
+type Patron struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email,omitempty"`
+}
+
+// NewPatron returns an initalized Patron struct with a uuid
+func NewPatron() Patron {
+	id, _ := uuid.NewV4()
+	return Patron{ID: id}
+}
+
+// Validate will return an error if any of the fields of the patron are invalid
+func (p Patron) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return ErrInvalidPatronName
+	}
+
+	return nil
+}
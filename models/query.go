@@ -0,0 +1,138 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// BookQuery describes which books to pick out of the library, the order to
+// put them in, and which page of them to return. The zero value matches every
+// book sorted by title
+type BookQuery struct {
+	// Author and Publisher match books with the same value, ignoring case
+	// and surrounding whitespace
+	Author    string
+	Publisher string
+
+	// Status only matches books with the given status when it's set
+	Status *Status
+
+	// MinRating and MaxRating are inclusive bounds, 0 leaves them unbounded
+	MinRating uint8
+	MaxRating uint8
+
+	// PublishedAfter and PublishedBefore are inclusive bounds, books without
+	// a publish date never match when either one is set
+	PublishedAfter  *time.Time
+	PublishedBefore *time.Time
+
+	// SortBy is the json name of the field to sort by, title if it's empty
+	SortBy     string
+	Descending bool
+
+	// Limit is the most books to return, 0 returns all of them, and Offset
+	// is how many of the matching books to skip
+	Limit  int
+	Offset int
+}
+
+// bookLess holds a less function for each field books can be sorted by
+var bookLess = map[string]func(a, b Book) bool{
+	"title":     func(a, b Book) bool { return a.Title < b.Title },
+	"author":    func(a, b Book) bool { return a.Author < b.Author },
+	"publisher": func(a, b Book) bool { return a.Publisher < b.Publisher },
+	"rating":    func(a, b Book) bool { return a.Rating < b.Rating },
+	"status":    func(a, b Book) bool { return a.Status < b.Status },
+	"publish_date": func(a, b Book) bool {
+		// books without a date sort after the ones with a date
+		if a.PublishDate == nil || b.PublishDate == nil {
+			return a.PublishDate != nil && b.PublishDate == nil
+		}
+		return a.PublishDate.Before(*b.PublishDate)
+	},
+}
+
+// Validate returns an error if the query can't be run
+func (q BookQuery) Validate() error {
+	if _, found := bookLess[q.sortField()]; !found {
+		return ErrInvalidSortField
+	}
+
+	return nil
+}
+
+// sortField returns the field to sort by, defaulting to title
+func (q BookQuery) sortField() string {
+	if q.SortBy == "" {
+		return "title"
+	}
+
+	return q.SortBy
+}
+
+// Matches returns true if the book passes all of the query's filters
+func (q BookQuery) Matches(book Book) bool {
+	if q.Author != "" && !sameName(q.Author, book.Author) {
+		return false
+	}
+
+	if q.Publisher != "" && !sameName(q.Publisher, book.Publisher) {
+		return false
+	}
+
+	if q.Status != nil && *q.Status != book.Status {
+		return false
+	}
+
+	if q.MinRating != 0 && book.Rating < q.MinRating {
+		return false
+	}
+
+	if q.MaxRating != 0 && book.Rating > q.MaxRating {
+		return false
+	}
+
+	if q.PublishedAfter != nil || q.PublishedBefore != nil {
+		if book.PublishDate == nil {
+			return false
+		}
+
+		if q.PublishedAfter != nil && book.PublishDate.Before(*q.PublishedAfter) {
+			return false
+		}
+
+		if q.PublishedBefore != nil && book.PublishDate.After(*q.PublishedBefore) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Less returns true if book a comes before book b in the query's order.
+// Books that tie on the sort field are kept in title and then id order so
+// that pages are stable between requests
+func (q BookQuery) Less(a, b Book) bool {
+	if q.Descending {
+		a, b = b, a
+	}
+
+	less := bookLess[q.sortField()]
+	if less(a, b) {
+		return true
+	}
+	if less(b, a) {
+		return false
+	}
+
+	if a.Title != b.Title {
+		return a.Title < b.Title
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// sameName returns true if two names are the same, ignoring case and any
+// surrounding whitespace, the OPDS catalog groups books the same way
+func sameName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
@@ -0,0 +1,9 @@
+package model
+
+// SearchHit is a single book that matched a search, Highlights holds each of
+// the fields that matched with the matching words wrapped in <em> tags
+type SearchHit struct {
+	Book       Book              `json:"book"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+// BookCheckouts is the number of times a book has been checked out
+type BookCheckouts struct {
+	BookID    uuid.UUID `json:"book_id"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	Checkouts int       `json:"checkouts"`
+}
+
+// AuthorCheckouts is the number of times books by an author have been
+// checked out
+type AuthorCheckouts struct {
+	Author    string `json:"author"`
+	Checkouts int    `json:"checkouts"`
+}
+
+// MonthCheckouts is the number of checkouts in a month, formatted as 2018-01
+type MonthCheckouts struct {
+	Month     string `json:"month"`
+	Checkouts int    `json:"checkouts"`
+}
+
+// CirculationStats counts checkouts by book, by author and by month. The
+// book and author counts include books that have since been deleted, with
+// the most checked out first, and the months are in order
+type CirculationStats struct {
+	ByBook   []BookCheckouts   `json:"by_book"`
+	ByAuthor []AuthorCheckouts `json:"by_author"`
+	ByMonth  []MonthCheckouts  `json:"by_month"`
+}
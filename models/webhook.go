@@ -0,0 +1,88 @@
+package model
+
+import (
+	"net/url"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Webhook is a url that the events are POSTed to
+type Webhook struct {
+	ID  uuid.UUID `json:"id"`
+	URL string    `json:"url"`
+
+	// Events are the types of event sent to the webhook, all of them if
+	// it's empty
+	Events []EventType `json:"events"`
+
+	// Secret is what the deliveries are signed with, it's only ever shown
+	// when the webhook is created
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate returns an error if the webhook's url or events are invalid
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	for _, event := range w.Events {
+		valid := false
+		for _, eventType := range EventTypes {
+			valid = valid || event == eventType
+		}
+		if !valid {
+			return ErrInvalidWebhookEvent
+		}
+	}
+
+	return nil
+}
+
+// Wants returns true if the webhook is sent events of the type
+func (w Webhook) Wants(eventType EventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeliveryStatus is where a delivery is up to
+type DeliveryStatus string
+
+// this const block holds the DeliveryStatus values, a delivery is pending
+// until it succeeds or runs out of attempts, at which point it's dead and
+// put in the dead letters
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// DeliveryAttempt is a single try at sending a delivery
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Delivery is an event being sent to a webhook
+type Delivery struct {
+	ID            uuid.UUID         `json:"id"`
+	WebhookID     uuid.UUID         `json:"webhook_id"`
+	Event         Event             `json:"event"`
+	Status        DeliveryStatus    `json:"status"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+}